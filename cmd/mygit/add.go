@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/ignore"
+	"github.com/master-wayne7/go-git/internal/index"
+)
+
+// addCommand implements `add [-A | -u] [-f] [-n] [-v] [--] <pathspec>...`.
+// Matching tracked files are restaged, or removed from the index when they
+// are gone; unless -u is given, untracked files that are not ignored are
+// added too. -A and -u without a pathspec apply to the whole work tree.
+func addCommand(args []string) {
+	var all, update, force, dryRun, verbose bool
+	var paths []string
+	for i, arg := range args {
+		if arg == "--" {
+			paths = append(paths, args[i+1:]...)
+			break
+		}
+		switch arg {
+		case "-A", "--all":
+			all = true
+		case "-u", "--update":
+			update = true
+		case "-f", "--force":
+			force = true
+		case "-n", "--dry-run":
+			dryRun = true
+		case "-v", "--verbose":
+			verbose = true
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				fmt.Fprintf(os.Stderr, "usage: mygit add [-A | -u] [-f] [-n] [-v] [--] <pathspec>...\n")
+				os.Exit(129)
+			}
+			paths = append(paths, arg)
+		}
+	}
+	if all && update {
+		fmt.Fprintf(os.Stderr, "fatal: options '-A' and '-u' cannot be used together\n")
+		os.Exit(128)
+	}
+	if len(paths) == 0 && !all && !update {
+		fmt.Fprintf(os.Stderr, "Nothing specified, nothing added.\nhint: Maybe you wanted to say 'git add .'?\n")
+		return
+	}
+
+	repo := openWorkTree()
+	specs := parsePathspecs(repo, paths)
+	if len(specs) == 0 {
+		specs = []pathspec{{arg: ".", path: ""}}
+	}
+	var m *ignore.Matcher
+	if !force {
+		m = newIgnoreMatcher(repo)
+	}
+	idx := openIndex(repo)
+
+	// changes maps each path to its new entry, or to nil for a removal
+	changes := make(map[string]*index.Entry)
+	matched := make([]bool, len(specs))
+	updateTracked(repo, idx, specs, matched, changes, !dryRun)
+
+	// ignored paths are only reported when a pathspec names them explicitly
+	var ignored []string
+	reportIgnored := func(path string) {
+		for n, s := range specs {
+			if s.names(path) {
+				matched[n] = true
+				ignored = append(ignored, path)
+				return
+			}
+		}
+	}
+	if !update {
+		err := walkWorkTree(repo, m, "", func(path string, info os.FileInfo, isIgnored bool) bool {
+			full := filepath.Join(repo.WorkTree, filepath.FromSlash(path))
+			if index.ModeOf(full, info) == 0 {
+				if isIgnored {
+					reportIgnored(path)
+					return false
+				}
+				for _, s := range specs {
+					if s.mayContain(path) {
+						return true
+					}
+				}
+				return false
+			}
+			n := matchAny(specs, path)
+			if n < 0 || len(idx.Stages(path)) > 0 {
+				return false
+			}
+			if isIgnored {
+				reportIgnored(path)
+				return false
+			}
+			matched[n] = true
+			changes[path] = restageFile(repo, path, info, !dryRun)
+			return false
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+	}
+
+	// a pathspec naming an existing path that needed no change is fine
+	for n, s := range specs {
+		if matched[n] || s.path == "" {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(repo.WorkTree, filepath.FromSlash(s.path))); err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: pathspec '%s' did not match any files\n", s.arg)
+			os.Exit(128)
+		}
+	}
+
+	changed := make([]string, 0, len(changes))
+	for path := range changes {
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+	for _, path := range changed {
+		e := changes[path]
+		if verbose || dryRun {
+			if e == nil {
+				fmt.Printf("remove '%s'\n", path)
+			} else {
+				fmt.Printf("add '%s'\n", path)
+			}
+		}
+		if e == nil {
+			idx.Remove(path)
+		} else {
+			idx.Add(e)
+		}
+	}
+	if !dryRun && len(changed) > 0 {
+		writeIndex(idx)
+	}
+
+	if len(ignored) > 0 {
+		fmt.Fprintf(os.Stderr, "The following paths are ignored by one of your .gitignore files:\n%s\n", strings.Join(ignored, "\n"))
+		fmt.Fprintf(os.Stderr, "hint: Use -f if you really want to add them.\n")
+		os.Exit(1)
+	}
+}
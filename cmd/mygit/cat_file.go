@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// catFile implements `cat-file (-t | -s | -e | -p | <type>) <object>` and
+// the streaming `--batch` / `--batch-check` modes
+func catFile(args []string) {
+	if len(args) > 0 && strings.HasPrefix(args[0], "--batch") {
+		catFileBatch(args)
+		return
+	}
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: mygit cat-file (-t | -s | -e | -p | <type>) <object>\n")
+		os.Exit(129)
+	}
+	mode := args[0]
+	repo := openRepository()
+	hash := resolveRevision(repo, args[1])
+
+	// -e only reports existence through the exit status
+	if mode == "-e" {
+		if !repo.Objects.Has(hash) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if mode == "-p" {
+		if err := repo.CatFile(hash); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	obj, err := repo.ReadObject(hash)
+	if errors.Is(err, objects.ErrObjectNotFound) {
+		fmt.Fprintf(os.Stderr, "fatal: Not a valid object name %s\n", args[1])
+		os.Exit(128)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(128)
+	}
+
+	switch mode {
+	case "-t":
+		fmt.Println(obj.Type)
+	case "-s":
+		fmt.Println(obj.Size)
+	case "blob", "tree", "commit", "tag":
+		// like git, a tag or commit is dereferenced to the requested type
+		if obj.Type != mode {
+			peeled, err := repo.PeelTo(hash, mode)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "fatal: %s: bad file\n", args[1])
+				os.Exit(128)
+			}
+			if obj, err = repo.ReadObject(peeled); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(128)
+			}
+		}
+		os.Stdout.Write(obj.Content)
+	default:
+		fmt.Fprintf(os.Stderr, "usage: mygit cat-file (-t | -s | -e | -p | <type>) <object>\n")
+		os.Exit(129)
+	}
+}
+
+// catFileBatch serves object lookups from a single process. Object names are
+// read one per line from stdin (or taken from the whole object database with
+// --batch-all-objects) and each is answered with "<sha> <type> <size>",
+// followed by the content and a newline for --batch.
+func catFileBatch(args []string) {
+	var contents, check, allObjects bool
+	for _, arg := range args {
+		switch arg {
+		case "--batch":
+			contents = true
+		case "--batch-check":
+			check = true
+		case "--batch-all-objects":
+			allObjects = true
+		default:
+			fmt.Fprintf(os.Stderr, "usage: mygit cat-file (--batch | --batch-check) [--batch-all-objects]\n")
+			os.Exit(129)
+		}
+	}
+	if contents == check {
+		fmt.Fprintf(os.Stderr, "fatal: exactly one of --batch or --batch-check is required\n")
+		os.Exit(129)
+	}
+
+	repo := openRepository()
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	if allObjects {
+		var hashes []string
+		if err := repo.Objects.Iterate(func(hash string) error {
+			hashes = append(hashes, hash)
+			return nil
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(128)
+		}
+		sort.Strings(hashes)
+		for _, hash := range hashes {
+			if err := writeBatchRecord(out, repo, hash, contents); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(128)
+			}
+		}
+		return
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	for {
+		line, err := in.ReadString('\n')
+		if name := strings.TrimRight(line, "\r\n"); name != "" {
+			if err := writeBatchRecord(out, repo, name, contents); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(128)
+			}
+			// flush every record so callers can interleave requests and replies
+			if err := out.Flush(); err != nil {
+				os.Exit(128)
+			}
+		}
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(128)
+		}
+	}
+}
+
+// writeBatchRecord writes the batch output for one revision, reporting
+// "<name> missing" for names that do not resolve to an existing object
+func writeBatchRecord(out *bufio.Writer, repo *objects.Repository, name string, contents bool) error {
+	hash, err := repo.ResolveRevision(name)
+	if errors.Is(err, objects.ErrUnknownRevision) || errors.Is(err, objects.ErrAmbiguousRevision) {
+		_, err = fmt.Fprintf(out, "%s missing\n", name)
+		return err
+	} else if err != nil {
+		return err
+	}
+	obj, err := repo.ReadObject(hash)
+	if errors.Is(err, objects.ErrObjectNotFound) {
+		_, err = fmt.Fprintf(out, "%s missing\n", name)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%s %s %d\n", obj.Hash, obj.Type, obj.Size)
+	if contents {
+		out.Write(obj.Content)
+		out.WriteByte('\n')
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/ignore"
+	"github.com/master-wayne7/go-git/internal/index"
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// checkIgnore implements `check-ignore [-v] [-n] [-q] [-z] [--no-index]
+// (--stdin | <pathname>...)`. It prints the paths that are ignored, or with
+// -v the pattern deciding each one (including "!" patterns); -n also lists
+// paths no pattern matches. Tracked paths are never reported unless
+// --no-index is given. It exits 0 when some path was ignored and 1 otherwise.
+func checkIgnore(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "usage: mygit check-ignore [-v] [-n] [-q] [-z] [--no-index] (--stdin | <pathname>...)\n")
+		os.Exit(129)
+	}
+
+	var verbose, nonMatching, quiet, nulTerminated, noIndex, stdin bool
+	var paths []string
+	for i, arg := range args {
+		if arg == "--" {
+			paths = append(paths, args[i+1:]...)
+			break
+		}
+		switch arg {
+		case "-v", "--verbose":
+			verbose = true
+		case "-n", "--non-matching":
+			nonMatching = true
+		case "-q", "--quiet":
+			quiet = true
+		case "-z":
+			nulTerminated = true
+		case "--no-index":
+			noIndex = true
+		case "--stdin":
+			stdin = true
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				usage()
+			}
+			paths = append(paths, arg)
+		}
+	}
+	fatal := func(message string) {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", message)
+		os.Exit(128)
+	}
+	switch {
+	case stdin && len(paths) > 0:
+		fatal("cannot specify pathnames with --stdin")
+	case !stdin && len(paths) == 0:
+		fatal("no path specified")
+	case quiet && verbose:
+		fatal("cannot have both --quiet and --verbose")
+	case quiet && len(paths) != 1:
+		fatal("--quiet is only valid with a single pathname")
+	case nonMatching && !verbose:
+		fatal("--non-matching is only valid with --verbose")
+	}
+
+	repo := openWorkTree()
+	m := newIgnoreMatcher(repo)
+	var idx *index.Index
+	if !noIndex {
+		idx = openIndex(repo)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	ignored := false
+	check := func(arg string) {
+		pattern := matchIgnored(repo, m, idx, arg)
+		if pattern != nil && pattern.Negate && !verbose {
+			pattern = nil
+		}
+		if pattern != nil {
+			ignored = true
+		}
+		if !quiet && (pattern != nil || nonMatching) {
+			printIgnoreMatch(out, arg, pattern, verbose, nulTerminated)
+		}
+		// answer each line as soon as it is read, for interactive use
+		if stdin {
+			out.Flush()
+		}
+	}
+
+	if stdin {
+		reader := bufio.NewReader(os.Stdin)
+		delim := byte('\n')
+		if nulTerminated {
+			delim = 0
+		}
+		for {
+			line, err := reader.ReadString(delim)
+			if line = strings.TrimSuffix(line, string(delim)); line != "" {
+				check(line)
+			}
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				fatal(err.Error())
+			}
+		}
+	} else {
+		for _, arg := range paths {
+			check(arg)
+		}
+	}
+
+	if !ignored {
+		out.Flush()
+		os.Exit(1)
+	}
+}
+
+// matchIgnored returns the pattern deciding whether the path given on the
+// command line is ignored, or nil. Tracked paths, and directories holding
+// tracked files, are not subject to ignore rules.
+func matchIgnored(repo *objects.Repository, m *ignore.Matcher, idx *index.Index, arg string) *ignore.Pattern {
+	path, err := repoRelative(repo, arg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	if path == "" {
+		return nil
+	}
+	if idx != nil {
+		for _, e := range idx.Entries {
+			if e.Path == path || strings.HasPrefix(e.Path, path+"/") {
+				return nil
+			}
+		}
+	}
+	info, err := os.Lstat(filepath.Join(repo.WorkTree, filepath.FromSlash(path)))
+	isDir := strings.HasSuffix(arg, "/") || (err == nil && info.IsDir())
+	return m.Match(path, isDir)
+}
+
+// printIgnoreMatch prints one result: the path, preceded with -v by the
+// pattern's source, line and text ("::" when nothing matched)
+func printIgnoreMatch(out io.Writer, arg string, p *ignore.Pattern, verbose, nulTerminated bool) {
+	if nulTerminated {
+		if verbose && p != nil {
+			fmt.Fprintf(out, "%s\x00%d\x00%s\x00", p.Source, p.Line, p.Text)
+		} else if verbose {
+			fmt.Fprintf(out, "\x00\x00\x00")
+		}
+		fmt.Fprintf(out, "%s\x00", arg)
+		return
+	}
+	if verbose && p != nil {
+		fmt.Fprintf(out, "%s:%d:%s\t", p.Source, p.Line, p.Text)
+	} else if verbose {
+		fmt.Fprintf(out, "::\t")
+	}
+	fmt.Fprintf(out, "%s\n", quotePath(arg, false))
+}
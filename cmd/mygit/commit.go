@@ -0,0 +1,482 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/diff"
+	"github.com/master-wayne7/go-git/internal/index"
+	"github.com/master-wayne7/go-git/internal/objects"
+	"github.com/master-wayne7/go-git/internal/refs"
+)
+
+// emptyTreeHash is the hash of the tree with no entries
+const emptyTreeHash = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// commitCommand implements `commit [-a] [-q] [--amend] [--allow-empty]
+// [--allow-empty-message] [(-m <message>)... | -F <file>]`. The index, after
+// restaging changed and deleted tracked files with -a, is written as a tree
+// and committed on top of HEAD (or in place of HEAD with --amend), and the
+// branch HEAD points to is moved to the new commit. A commit that would not
+// change the tree is refused unless --allow-empty is given or a merge is
+// being concluded. Without -m or -F the message of the amended commit, or
+// the prepared merge message, is reused.
+func commitCommand(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "usage: mygit commit [-a] [-q] [--amend] [--allow-empty] [--allow-empty-message] [(-m <message>)... | -F <file>]\n")
+		os.Exit(129)
+	}
+
+	var all, quiet, amend, allowEmpty, allowEmptyMessage bool
+	var message strings.Builder
+	var messageFile string
+	haveMessage := false
+	addParagraph := func(text string) {
+		if message.Len() > 0 {
+			message.WriteByte('\n')
+		}
+		message.WriteString(text)
+		if !strings.HasSuffix(text, "\n") {
+			message.WriteByte('\n')
+		}
+		haveMessage = true
+	}
+	// value returns the argument of a switch, given inline or as the next
+	// command line argument
+	value := func(i *int, inline, name string) string {
+		if inline != "" {
+			return inline
+		}
+		if *i+1 >= len(args) {
+			fmt.Fprintf(os.Stderr, "error: switch `%s' requires a value\n", name)
+			usage()
+		}
+		*i++
+		return args[*i]
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-a" || arg == "--all":
+			all = true
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case arg == "--amend":
+			amend = true
+		case arg == "--allow-empty":
+			allowEmpty = true
+		case arg == "--allow-empty-message":
+			allowEmptyMessage = true
+		case arg == "--message" || strings.HasPrefix(arg, "--message="):
+			addParagraph(value(&i, strings.TrimPrefix(strings.TrimPrefix(arg, "--message"), "="), "m"))
+		case arg == "--file" || strings.HasPrefix(arg, "--file="):
+			messageFile = value(&i, strings.TrimPrefix(strings.TrimPrefix(arg, "--file"), "="), "F")
+		case len(arg) > 1 && arg[0] == '-' && arg[1] != '-':
+			// bundled short switches such as -am <message>
+			for j := 1; j < len(arg); j++ {
+				switch arg[j] {
+				case 'a':
+					all = true
+				case 'q':
+					quiet = true
+				case 'm':
+					addParagraph(value(&i, arg[j+1:], "m"))
+					j = len(arg)
+				case 'F':
+					messageFile = value(&i, arg[j+1:], "F")
+					j = len(arg)
+				default:
+					usage()
+				}
+			}
+		default:
+			usage()
+		}
+	}
+	if haveMessage && messageFile != "" {
+		fmt.Fprintf(os.Stderr, "fatal: options '-m' and '-F' cannot be used together\n")
+		os.Exit(128)
+	}
+
+	repo := openWorkTree()
+	idx := openIndex(repo)
+	head, err := repo.Refs.Resolve("HEAD")
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	mergeHeads := readMergeHeads(repo)
+	switch {
+	case amend && head == "":
+		fmt.Fprintf(os.Stderr, "fatal: You have nothing to amend.\n")
+		os.Exit(128)
+	case amend && len(mergeHeads) > 0:
+		fmt.Fprintf(os.Stderr, "fatal: You are in the middle of a merge -- cannot amend.\n")
+		os.Exit(128)
+	}
+	if all {
+		// this also resolves conflicts with the work tree files
+		changes := make(map[string]*index.Entry)
+		updateTracked(repo, idx, []pathspec{{arg: ".", path: ""}}, make([]bool, 1), changes, true)
+		for path, e := range changes {
+			if e == nil {
+				idx.Remove(path)
+			} else {
+				idx.Add(e)
+			}
+		}
+	}
+	if idx.Unmerged() {
+		for i, e := range idx.Entries {
+			if e.Stage != 0 && (i == 0 || idx.Entries[i-1].Path != e.Path) {
+				fmt.Printf("U\t%s\n", quotePath(e.Path, false))
+			}
+		}
+		fmt.Fprintf(os.Stderr, "error: Committing is not possible because you have unmerged files.\n")
+		fmt.Fprintf(os.Stderr, "hint: Fix them up in the work tree, and then use 'git add/rm <file>'\n")
+		fmt.Fprintf(os.Stderr, "hint: as appropriate to mark resolution and make a commit.\n")
+		fmt.Fprintf(os.Stderr, "fatal: Exiting because of an unresolved conflict.\n")
+		os.Exit(128)
+	}
+
+	var parents []string
+	var amended *objects.Commit
+	if amend {
+		if amended, err = repo.ReadCommit(head); err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		parents = amended.Parents
+	} else {
+		if head != "" {
+			parents = append(parents, head)
+		}
+		parents = append(parents, mergeHeads...)
+	}
+
+	tree, err := idx.WriteTree(repo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\nerror: Error building trees\n", err)
+		os.Exit(128)
+	}
+	parentTree := ""
+	if len(parents) > 0 {
+		parentTree = commitTreeOf(repo, parents[0])
+	}
+	unchanged := tree == parentTree || (parentTree == "" && tree == emptyTreeHash)
+	if unchanged && !allowEmpty && len(mergeHeads) == 0 && !(amend && len(parents) > 1) {
+		prefix, err := repoRelative(repo, ".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		r := collectStatus(repo, idx, readTreeIndex(repo, parentTree), nil, "normal", false)
+		r.commit, r.amend = true, amend
+		printLongStatus(repo, r, prefix, "normal")
+		if amend {
+			fmt.Fprintf(os.Stderr, "You asked to amend the most recent commit, but doing so would make\n"+
+				"it empty. You can repeat your command with --allow-empty, or you can\n"+
+				"remove the commit entirely with \"git reset HEAD^\".\n")
+		}
+		os.Exit(1)
+	}
+
+	text := message.String()
+	switch {
+	case messageFile != "":
+		content, err := readMessageFile(messageFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: could not read log file '%s': %s\n", messageFile, err)
+			os.Exit(128)
+		}
+		text = content
+	case haveMessage:
+	case amend:
+		text = amended.Message
+	case len(mergeHeads) > 0:
+		content, err := os.ReadFile(repo.Path("MERGE_MSG"))
+		if err != nil && !isMissing(err) {
+			fmt.Fprintf(os.Stderr, "fatal: could not read MERGE_MSG: %s\n", err)
+			os.Exit(128)
+		}
+		text = string(content)
+	}
+	text = objects.CleanupMessage(text, false)
+	if text == "" && !allowEmptyMessage {
+		fmt.Fprintf(os.Stderr, "Aborting commit due to empty commit message.\n")
+		os.Exit(1)
+	}
+
+	c := &objects.Commit{Tree: tree, Parents: parents, Message: text}
+	if amend {
+		// the amended commit keeps its authorship
+		c.Author = amended.Author
+	} else {
+		c.Author, err = repo.AuthorIdent()
+	}
+	if err == nil {
+		c.Committer, err = repo.CommitterIdent()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	hash, err := repo.WriteCommit(c)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: unable to write commit object: %s\n", err)
+		os.Exit(1)
+	}
+
+	action := "commit"
+	switch {
+	case amend:
+		action = "commit (amend)"
+	case head == "":
+		action = "commit (initial)"
+	case len(mergeHeads) > 0:
+		action = "commit (merge)"
+	}
+	oldHead := head
+	if oldHead == "" {
+		oldHead = refs.ZeroHash
+	}
+	subject, _, _ := strings.Cut(text, "\n")
+	if err := repo.Refs.Update("HEAD", hash, oldHead, action+": "+subject); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	// the index now also caches the trees just written
+	writeIndex(idx)
+	for _, name := range []string{"MERGE_HEAD", "MERGE_MSG", "MERGE_MODE"} {
+		os.Remove(repo.Path(name))
+	}
+
+	if !quiet {
+		branch := "detached HEAD"
+		if target, err := repo.Refs.Follow("HEAD"); err == nil && target != "HEAD" {
+			branch = strings.TrimPrefix(target, "refs/heads/")
+		}
+		printCommitSummary(repo, hash, c, branch, amend)
+	}
+}
+
+// readMergeHeads returns the commits a pending merge will join, listed in
+// MERGE_HEAD
+func readMergeHeads(repo *objects.Repository) []string {
+	content, err := os.ReadFile(repo.Path("MERGE_HEAD"))
+	if isMissing(err) {
+		return nil
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: could not read MERGE_HEAD: %s\n", err)
+		os.Exit(128)
+	}
+	return strings.Fields(string(content))
+}
+
+// commitTreeOf returns the tree of a commit
+func commitTreeOf(repo *objects.Repository, hash string) string {
+	c, err := repo.ReadCommit(hash)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	return c.Tree
+}
+
+// printCommitSummary prints the line naming the new commit, then its author
+// when that is not the committer, the author date kept by --amend, and a
+// summary of the changes made by a non-merge commit
+func printCommitSummary(repo *objects.Repository, hash string, c *objects.Commit, branch string, amend bool) {
+	root := ""
+	if len(c.Parents) == 0 {
+		root = " (root-commit)"
+	}
+	fmt.Printf("[%s%s %s] %s\n", branch, root, shortHash(repo, hash), commitSubject(c.Message))
+	if c.Author.Name != c.Committer.Name || c.Author.Email != c.Committer.Email {
+		fmt.Printf(" Author: %s <%s>\n", c.Author.Name, c.Author.Email)
+	}
+	if amend {
+		fmt.Printf(" Date: %s\n", c.Author.When.Format("Mon Jan 2 15:04:05 2006 -0700"))
+	}
+	if len(c.Parents) > 1 {
+		return
+	}
+	parentTree := ""
+	if len(c.Parents) == 1 {
+		parentTree = commitTreeOf(repo, c.Parents[0])
+	}
+	printChangeSummary(repo, readTreeIndex(repo, parentTree), readTreeIndex(repo, c.Tree))
+}
+
+// commitSubject returns the first paragraph of a message on one line
+func commitSubject(message string) string {
+	paragraph, _, _ := strings.Cut(message, "\n\n")
+	return strings.Join(strings.Split(strings.TrimSuffix(paragraph, "\n"), "\n"), " ")
+}
+
+// fileChange is a file that differs between two trees; from is nil for an
+// added file and to for a deleted one
+type fileChange struct {
+	from, to *index.Entry
+}
+
+// path returns the file's new path, or its old one when it was deleted
+func (ch fileChange) path() string {
+	if ch.to != nil {
+		return ch.to.Path
+	}
+	return ch.from.Path
+}
+
+// diffTrees lists the files that differ between two trees, in path order.
+// A deleted and an added file with the same content are paired as an exact
+// rename.
+func diffTrees(old, new *index.Index) []fileChange {
+	var changes []fileChange
+	var deleted, added []*index.Entry
+	for _, e := range old.Entries {
+		if new.Entry(e.Path) == nil {
+			deleted = append(deleted, e)
+		}
+	}
+	for _, e := range new.Entries {
+		o := old.Entry(e.Path)
+		switch {
+		case o == nil:
+			added = append(added, e)
+		case o.Mode != e.Mode || o.Hash != e.Hash:
+			changes = append(changes, fileChange{from: o, to: e})
+		}
+	}
+
+	from := make([]diff.File, len(deleted))
+	for i, e := range deleted {
+		from[i] = diff.File{Path: e.Path, Mode: e.Mode, Hash: e.Hash}
+	}
+	to := make([]diff.File, len(added))
+	for i, e := range added {
+		to[i] = diff.File{Path: e.Path, Mode: e.Mode, Hash: e.Hash}
+	}
+	renames := diff.ExactRenames(from, to)
+	renamed := make(map[int]bool)
+	for i, e := range added {
+		ch := fileChange{to: e}
+		if j, ok := renames[i]; ok {
+			ch.from = deleted[j]
+			renamed[j] = true
+		}
+		changes = append(changes, ch)
+	}
+	for j, e := range deleted {
+		if !renamed[j] {
+			changes = append(changes, fileChange{from: e})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path() < changes[j].path() })
+	return changes
+}
+
+// printChangeSummary prints git's short diffstat of the files changed
+// between two trees, followed by the created, deleted and renamed files and
+// the mode changes. Binary files count as changed but add no lines.
+func printChangeSummary(repo *objects.Repository, old, new *index.Index) {
+	changes := diffTrees(old, new)
+	if len(changes) == 0 {
+		return
+	}
+
+	content := func(e *index.Entry) []byte {
+		if e == nil {
+			return nil
+		}
+		if e.Mode == index.ModeGitlink {
+			return []byte("Subproject commit " + e.Hash + "\n")
+		}
+		obj, err := repo.ReadObject(e.Hash)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		return obj.Content
+	}
+	var insertions, deletions int
+	for _, ch := range changes {
+		if ch.from != nil && ch.to != nil && ch.from.Hash == ch.to.Hash {
+			continue
+		}
+		a, b := content(ch.from), content(ch.to)
+		if diff.IsBinary(a) || diff.IsBinary(b) {
+			continue
+		}
+		ins, del := diff.CountChanges(a, b)
+		insertions += ins
+		deletions += del
+	}
+
+	plural := func(n int) string {
+		if n == 1 {
+			return ""
+		}
+		return "s"
+	}
+	fmt.Printf(" %d file%s changed", len(changes), plural(len(changes)))
+	if insertions > 0 || deletions == 0 {
+		fmt.Printf(", %d insertion%s(+)", insertions, plural(insertions))
+	}
+	if deletions > 0 || insertions == 0 {
+		fmt.Printf(", %d deletion%s(-)", deletions, plural(deletions))
+	}
+	fmt.Println()
+
+	for _, ch := range changes {
+		switch {
+		case ch.from == nil:
+			fmt.Printf(" create mode %06o %s\n", ch.to.Mode, quotePath(ch.to.Path, false))
+		case ch.to == nil:
+			fmt.Printf(" delete mode %06o %s\n", ch.from.Mode, quotePath(ch.from.Path, false))
+		case ch.from.Path != ch.to.Path:
+			fmt.Printf(" rename %s (100%%)\n", renameLabel(quotePath(ch.from.Path, false), quotePath(ch.to.Path, false)))
+			if ch.from.Mode != ch.to.Mode {
+				fmt.Printf(" mode change %06o => %06o\n", ch.from.Mode, ch.to.Mode)
+			}
+		case ch.from.Mode != ch.to.Mode:
+			fmt.Printf(" mode change %06o => %06o %s\n", ch.from.Mode, ch.to.Mode, quotePath(ch.to.Path, false))
+		}
+	}
+}
+
+// renameLabel shows a rename as "a => b", factoring out the leading and
+// trailing directories both paths share: "dir/{a => b}/file"
+func renameLabel(a, b string) string {
+	prefix := 0
+	for i := 0; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+		if a[i] == '/' {
+			prefix = i + 1
+		}
+	}
+	// the shared suffix starts at a slash and may reuse the prefix's one
+	suffix := 0
+	adjust := 0
+	if prefix > 0 {
+		adjust = 1
+	}
+	for i, j := len(a)-1, len(b)-1; i >= prefix-adjust && j >= prefix-adjust && a[i] == b[j]; i, j = i-1, j-1 {
+		if a[i] == '/' {
+			suffix = len(a) - i
+		}
+	}
+	if prefix+suffix == 0 {
+		return a + " => " + b
+	}
+	mid := func(s string) string {
+		if end := len(s) - suffix; end > prefix {
+			return s[prefix:end]
+		}
+		return ""
+	}
+	return a[:prefix] + "{" + mid(a) + " => " + mid(b) + "}" + a[len(a)-suffix:]
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// commitTree implements `commit-tree <tree> [(-p <parent>)...] [(-m <message>)...]
+// [(-F <file>)...]`. Flags may appear before or after the tree. Each -m adds
+// a paragraph, -F - reads a message from stdin, and with neither the whole
+// message is read from stdin. Without -p the result is a root commit.
+//
+// Like git commit-tree, only -m gets a newline appended: -F and stdin
+// messages are stored byte for byte, without cleanup, so the commit has the
+// same hash git would give it.
+func commitTree(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "usage: mygit commit-tree <tree> [(-p <parent>)...] [(-m <message>)...] [(-F <file>)...]\n")
+		os.Exit(129)
+	}
+
+	repo := openRepository()
+	var tree string
+	var parents []string
+	var message strings.Builder
+	haveMessage := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-p", "-m", "-F":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "error: switch `%s' requires a value\n", arg[1:])
+				usage()
+			}
+			i++
+			value := args[i]
+
+			switch arg {
+			case "-p":
+				parent, err := repo.PeelTo(resolveRevision(repo, value), "commit")
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "fatal: %s is not a valid 'commit' object\n", value)
+					os.Exit(128)
+				}
+				if contains(parents, parent) {
+					fmt.Fprintf(os.Stderr, "error: duplicate parent %s ignored\n", parent)
+					continue
+				}
+				parents = append(parents, parent)
+			case "-m":
+				if message.Len() > 0 {
+					message.WriteByte('\n')
+				}
+				message.WriteString(value)
+				if !strings.HasSuffix(value, "\n") {
+					message.WriteByte('\n')
+				}
+				haveMessage = true
+			case "-F":
+				if message.Len() > 0 {
+					message.WriteByte('\n')
+				}
+				content, err := readMessageFile(value)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "fatal: could not read log file '%s': %s\n", value, err)
+					os.Exit(128)
+				}
+				message.WriteString(content)
+				haveMessage = true
+			}
+		default:
+			if strings.HasPrefix(arg, "-") || tree != "" {
+				usage()
+			}
+			tree = arg
+		}
+	}
+	if tree == "" {
+		usage()
+	}
+
+	treeSha, err := repo.PeelTo(resolveRevision(repo, tree), "tree")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s is not a valid 'tree' object\n", tree)
+		os.Exit(128)
+	}
+
+	if !haveMessage {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: unable to read commit message from standard input: %s\n", err)
+			os.Exit(128)
+		}
+		message.Write(content)
+	}
+
+	commitSha, err := repo.CommitTree(treeSha, parents, message.String())
+	if errors.Is(err, objects.ErrNoIdentity) {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(commitSha)
+}
+
+// readMessageFile reads a commit message from a file, or from stdin for "-"
+func readMessageFile(path string) (string, error) {
+	if path == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		return string(content), err
+	}
+	content, err := os.ReadFile(path)
+	return string(content), err
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
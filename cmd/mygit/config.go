@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/config"
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// configCommand implements `config [<scope>] [<type>] <action>`:
+//
+//	--get <key>, --get-all <key>, -l/--list, <key> (get)
+//	--set <key> <value>, <key> <value>, --add <key> <value>,
+//	--replace-all <key> <value>, --unset <key>, --unset-all <key>
+//
+// where <scope> is --local, --global, --system or --file <path> and
+// <type> is --type=bool|int|path (or --bool, --int, --path)
+func configCommand(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "usage: mygit config [--local | --global | --system | --file <path>] [--type=<type>] (--get | --get-all | --set | --add | --replace-all | --unset | --unset-all | --list) [<key> [<value>]]\n")
+		os.Exit(129)
+	}
+
+	var scope, file, valueType, action string
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--local", "--global", "--system":
+			scope = arg[2:]
+		case "-f", "--file":
+			if i+1 >= len(args) {
+				usage()
+			}
+			i++
+			scope, file = "file", args[i]
+		case "--bool", "--int", "--path":
+			valueType = arg[2:]
+		case "--get", "--get-all", "--set", "--add", "--replace-all", "--unset", "--unset-all", "--list":
+			action = arg[2:]
+		case "-l":
+			action = "list"
+		default:
+			if t, ok := strings.CutPrefix(arg, "--type="); ok {
+				valueType = t
+				continue
+			}
+			if strings.HasPrefix(arg, "-") {
+				usage()
+			}
+			positional = append(positional, arg)
+		}
+	}
+	switch valueType {
+	case "", "bool", "int", "path":
+	default:
+		fmt.Fprintf(os.Stderr, "error: unrecognized --type argument, %s\n", valueType)
+		os.Exit(129)
+	}
+	if action == "" {
+		switch len(positional) {
+		case 1:
+			action = "get"
+		case 2:
+			action = "set"
+		default:
+			usage()
+		}
+	}
+
+	// Only the repository scope needs a repository
+	repo, repoErr := objects.DiscoverRepository(".")
+	var gitDir string
+	if repoErr == nil {
+		gitDir = repo.GitDir
+	}
+
+	switch action {
+	case "get", "get-all", "list":
+		want := map[string]int{"get": 1, "get-all": 1, "list": 0}[action]
+		if len(positional) != want {
+			usage()
+		}
+		cfg, err := loadConfigScope(scope, file, gitDir, repoErr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		if action == "list" {
+			for _, e := range cfg.Entries {
+				if e.NoValue {
+					fmt.Println(e.Name())
+				} else {
+					fmt.Printf("%s=%s\n", e.Name(), e.Value)
+				}
+			}
+			return
+		}
+		printConfigValues(cfg, positional[0], valueType, action == "get-all")
+
+	case "set", "add", "replace-all", "unset", "unset-all":
+		want := 2
+		if strings.HasPrefix(action, "unset") {
+			want = 1
+		}
+		if len(positional) != want {
+			usage()
+		}
+		path := configWritePath(scope, file, gitDir, repoErr)
+		f, err := config.OpenFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+
+		key := positional[0]
+		switch action {
+		case "set", "replace-all":
+			value := positional[1]
+			// paths are stored as written and only expanded when read
+			if valueType == "bool" || valueType == "int" {
+				if value, err = normalizeConfigValue(value, valueType); err != nil {
+					fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+					os.Exit(128)
+				}
+			}
+			err = f.Set(key, value, action == "replace-all")
+		case "add":
+			err = f.Add(key, positional[1])
+		case "unset", "unset-all":
+			var removed bool
+			removed, err = f.Unset(key, action == "unset-all")
+			if err == nil && !removed {
+				os.Exit(5)
+			}
+		}
+		if errors.Is(err, config.ErrMultipleValues) {
+			fmt.Fprintf(os.Stderr, "warning: %s has multiple values\n", key)
+			os.Exit(5)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		if err := f.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(4)
+		}
+	}
+}
+
+// loadConfigScope reads the config of one scope, or all of them
+func loadConfigScope(scope, file, gitDir string, repoErr error) (*config.Config, error) {
+	switch scope {
+	case "file":
+		return config.LoadFiles(gitDir, file)
+	case "global":
+		return config.LoadFiles(gitDir, config.GlobalPaths()...)
+	case "system":
+		return config.LoadFiles(gitDir, config.SystemPath())
+	case "local":
+		if repoErr != nil {
+			return nil, fmt.Errorf("--local can only be used inside a git repository")
+		}
+		return config.LoadFiles(gitDir, gitDir+"/config")
+	}
+	return config.Load(gitDir)
+}
+
+// configWritePath returns the file an edit in the given scope goes to
+func configWritePath(scope, file, gitDir string, repoErr error) string {
+	switch scope {
+	case "file":
+		return file
+	case "global":
+		return config.GlobalWritePath()
+	case "system":
+		return config.SystemPath()
+	}
+	if repoErr != nil {
+		fmt.Fprintf(os.Stderr, "fatal: not in a git directory\n")
+		os.Exit(128)
+	}
+	return gitDir + "/config"
+}
+
+// printConfigValues prints the last (or every) value of key, converted to
+// valueType, exiting with status 1 when the key is not set
+func printConfigValues(cfg *config.Config, key, valueType string, all bool) {
+	entries := cfg.Find(key)
+	if len(entries) == 0 {
+		os.Exit(1)
+	}
+	if !all {
+		entries = entries[len(entries)-1:]
+	}
+
+	for _, e := range entries {
+		value := e.Value
+		if valueType == "bool" && e.NoValue {
+			value = "true"
+		} else if valueType != "" {
+			var err error
+			if value, err = normalizeConfigValue(e.Value, valueType); err != nil {
+				fmt.Fprintf(os.Stderr, "fatal: bad config value '%s' for '%s' in file %s: %s\n", e.Value, key, e.Origin, err)
+				os.Exit(128)
+			}
+		}
+		fmt.Println(value)
+	}
+}
+
+// normalizeConfigValue converts a value to the canonical form of valueType
+func normalizeConfigValue(value, valueType string) (string, error) {
+	switch valueType {
+	case "bool":
+		b, err := config.ParseBool(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	case "int":
+		n, err := config.ParseInt(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "path":
+		return config.ExpandPath(value)
+	}
+	return value, nil
+}
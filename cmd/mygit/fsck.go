@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/master-wayne7/go-git/internal/fsck"
+)
+
+// fsckCommand implements `fsck [--unreachable] [--no-dangling]`
+func fsckCommand(args []string) {
+	var opts fsck.Options
+	for _, arg := range args {
+		switch arg {
+		case "--unreachable":
+			opts.Unreachable = true
+		case "--no-dangling":
+			opts.NoDangling = true
+		case "--dangling":
+			opts.NoDangling = false
+		default:
+			fmt.Fprintf(os.Stderr, "usage: mygit fsck [--unreachable] [--[no-]dangling]\n")
+			os.Exit(129)
+		}
+	}
+
+	report, err := fsck.Check(openRepository(), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, issue := range report.Issues {
+		if issue.IsError() {
+			fmt.Fprintln(os.Stderr, issue)
+		} else {
+			fmt.Println(issue)
+		}
+	}
+	if report.HasErrors() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// hashObject implements
+// `hash-object [-t <type>] [-w] [--stdin] [--stdin-paths] [<file>...]`
+func hashObject(args []string) {
+	objType := "blob"
+	var write, stdin, stdinPaths bool
+	var files []string
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-w":
+			write = true
+		case "-t":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "error: switch `t' requires a value\n")
+				os.Exit(129)
+			}
+			i++
+			objType = args[i]
+		case "--stdin":
+			stdin = true
+		case "--stdin-paths":
+			stdinPaths = true
+		case "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+		default:
+			if strings.HasPrefix(arg, "-") {
+				fmt.Fprintf(os.Stderr, "usage: mygit hash-object [-t <type>] [-w] [--stdin] [--stdin-paths] [<file>...]\n")
+				os.Exit(129)
+			}
+			files = append(files, arg)
+		}
+	}
+
+	switch objType {
+	case "blob", "tree", "commit", "tag":
+	default:
+		fmt.Fprintf(os.Stderr, "fatal: invalid object type \"%s\"\n", objType)
+		os.Exit(128)
+	}
+	if stdin && stdinPaths {
+		fmt.Fprintf(os.Stderr, "fatal: Can't use --stdin-paths with --stdin\n")
+		os.Exit(129)
+	}
+	if stdinPaths && len(files) > 0 {
+		fmt.Fprintf(os.Stderr, "fatal: Can't specify files with --stdin-paths\n")
+		os.Exit(129)
+	}
+	if !stdin && !stdinPaths && len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: mygit hash-object [-t <type>] [-w] [--stdin] [--stdin-paths] [<file>...]\n")
+		os.Exit(129)
+	}
+
+	// Hashing without -w does not need a repository
+	repo, err := objects.DiscoverRepository(".")
+	if err != nil {
+		if write {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		repo = objects.NewMemoryRepository("")
+	}
+
+	if stdin {
+		hash, err := repo.HashStream(objType, os.Stdin, -1, write)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(128)
+		}
+		fmt.Println(hash)
+	}
+
+	if stdinPaths {
+		in := bufio.NewReader(os.Stdin)
+		for {
+			line, readErr := in.ReadString('\n')
+			if path := strings.TrimRight(line, "\r\n"); path != "" {
+				files = append(files[:0], path)
+				hashFiles(repo, files, objType, write)
+			}
+			if readErr == io.EOF {
+				return
+			} else if readErr != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", readErr)
+				os.Exit(128)
+			}
+		}
+	}
+
+	hashFiles(repo, files, objType, write)
+}
+
+// hashFiles prints the object hash of every file, exiting on the first error
+func hashFiles(repo *objects.Repository, files []string, objType string, write bool) {
+	for _, file := range files {
+		hash, err := repo.HashObject(file, objType, write)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(128)
+		}
+		fmt.Println(hash)
+	}
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/master-wayne7/go-git/internal/clone"
+	"github.com/master-wayne7/go-git/internal/index"
 	"github.com/master-wayne7/go-git/internal/objects"
 )
 
@@ -19,71 +21,81 @@ func main() {
 	case "init":
 		initFunction()
 	case "cat-file":
-		if os.Args[2] != "-p" || len(os.Args) < 4 {
-			fmt.Fprintf(os.Stderr, "usage: mygit cat-file -p <hash>\n")
+		catFile(os.Args[2:])
+	case "hash-object":
+		hashObject(os.Args[2:])
+	case "ls-tree":
+		if len(os.Args) < 3 || (os.Args[2] == "--name-only" && len(os.Args) < 4) {
+			fmt.Fprintf(os.Stderr, "usage: mygit ls-tree [--name-only] <tree-ish>\n")
 			os.Exit(1)
 		}
-		if err := objects.CatFile(os.Args[3]); err != nil {
+		nameOnly := os.Args[2] == "--name-only"
+		rev := os.Args[len(os.Args)-1]
+		repo := openRepository()
+		treeSha, err := repo.PeelTo(resolveRevision(repo, rev), "tree")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: not a tree object\n")
+			os.Exit(128)
+		}
+		if err := repo.LsTree(treeSha, nameOnly); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 			os.Exit(1)
 		}
-	case "hash-object":
-		if os.Args[2] == "-w" {
-			if len(os.Args) < 4 {
-				fmt.Fprintf(os.Stderr, "usage: mygit hash-object -w <file>\n")
-				os.Exit(1)
-			}
-			hash, err := objects.HashObject(os.Args[3], true)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-				os.Exit(1)
-			}
-			fmt.Println(hash)
-		} else {
-			hash, err := objects.HashObject(os.Args[2], false)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-				os.Exit(1)
-			}
-			fmt.Println(hash)
-		}
-	case "ls-tree":
-		if os.Args[2] == "--name-only" {
-			if len(os.Args) < 4 {
-				fmt.Fprintf(os.Stderr, "usage: mygit ls-tree --name-only <tree_hash>\n")
-				os.Exit(1)
-			}
-			if err := objects.LsTree(os.Args[3], true); err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-				os.Exit(1)
-			}
-		} else {
-			if err := objects.LsTree(os.Args[2], false); err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-				os.Exit(1)
-			}
-		}
 	case "write-tree":
-		sha, err := objects.WriteTree(".")
+		repo := openRepository()
+		idx, err := index.Open(repo)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing tree: %s\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
 		}
-		fmt.Println(sha)
-	case "commit-tree":
-		if len(os.Args) < 7 {
-			fmt.Fprintf(os.Stderr, "usage: mygit commit-tree <tree_sha> -p <parent_sha> -m <message> \n")
-			os.Exit(1)
-		}
-		treeSha := os.Args[2]
-		parentSha := os.Args[4]
-		message := os.Args[6]
-		commitSha, err := objects.CommitTree(treeSha, parentSha, message)
+		sha, err := idx.WriteTree(repo)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "error: %s\nfatal: git-write-tree: error building trees\n", err)
+			os.Exit(128)
+		}
+		// keep the trees just written in the index's cache, when there is an index
+		if len(idx.Entries) > 0 {
+			idx.Write()
 		}
-		fmt.Println(commitSha)
+		fmt.Println(sha)
+	case "mktree":
+		mktree(os.Args[2:])
+	case "add":
+		addCommand(os.Args[2:])
+	case "rm":
+		rmCommand(os.Args[2:])
+	case "mv":
+		mvCommand(os.Args[2:])
+	case "status":
+		statusCommand(os.Args[2:])
+	case "check-ignore":
+		checkIgnore(os.Args[2:])
+	case "commit":
+		commitCommand(os.Args[2:])
+	case "commit-tree":
+		commitTree(os.Args[2:])
+	case "show-ref":
+		showRef(os.Args[2:])
+	case "update-ref":
+		updateRef(os.Args[2:])
+	case "symbolic-ref":
+		symbolicRef(os.Args[2:])
+	case "check-ref-format":
+		checkRefFormat(os.Args[2:])
+	case "pack-refs":
+		packRefs(os.Args[2:])
+	case "tag":
+		tagCommand(os.Args[2:])
+	case "mktag":
+		mktag(os.Args[2:])
+	case "config":
+		configCommand(os.Args[2:])
+	case "rev-parse":
+		revParse(os.Args[2:])
+	case "reflog":
+		reflogCommand(os.Args[2:])
+	case "fsck":
+		fsckCommand(os.Args[2:])
 	case "clone":
 		if len(os.Args) < 4 {
 			fmt.Fprintf(os.Stderr, "usage: mygit clone <repo-url> <dir>\n")
@@ -103,16 +115,35 @@ func main() {
 
 // init command - kept as a simple function since it's straightforward
 func initFunction() {
-	for _, dir := range []string{".git", ".git/objects", ".git/refs"} {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating directory: %s\n", err)
-		}
+	if _, err := objects.InitRepository("."); err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing repository: %s\n", err)
+		os.Exit(1)
 	}
 
-	headFileContents := []byte("ref: refs/heads/main\n")
-	if err := os.WriteFile(".git/HEAD", headFileContents, 0644); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing file: %s\n", err)
+	fmt.Println("Initialized git directory")
+}
+
+// openRepository discovers the repository containing the current directory,
+// exiting with an error message if there is none
+func openRepository() *objects.Repository {
+	repo, err := objects.DiscoverRepository(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
 	}
+	return repo
+}
 
-	fmt.Println("Initialized git directory")
+// resolveRevision resolves a revision expression to an object name,
+// exiting like git does when it names no object
+func resolveRevision(repo *objects.Repository, rev string) string {
+	hash, err := repo.ResolveRevision(rev)
+	if err != nil {
+		if errors.Is(err, objects.ErrAmbiguousRevision) {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		}
+		fmt.Fprintf(os.Stderr, "fatal: Not a valid object name %s\n", rev)
+		os.Exit(128)
+	}
+	return hash
 }
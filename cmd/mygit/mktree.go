@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// mktree implements `mktree [-z] [--missing] [--batch]`: it reads ls-tree
+// formatted lines ("<mode> <type> <object>\t<name>") from stdin and writes
+// a tree of them. With --batch, blank lines separate several trees. A name
+// given twice keeps its last entry.
+func mktree(args []string) {
+	var nulTerminated, allowMissing, batch bool
+	for _, arg := range args {
+		switch arg {
+		case "-z":
+			nulTerminated = true
+		case "--missing":
+			allowMissing = true
+		case "--batch":
+			batch = true
+		default:
+			fmt.Fprintf(os.Stderr, "usage: mygit mktree [-z] [--missing] [--batch]\n")
+			os.Exit(129)
+		}
+	}
+
+	repo := openRepository()
+	reader := bufio.NewReader(os.Stdin)
+	delim := byte('\n')
+	if nulTerminated {
+		delim = 0
+	}
+
+	builder := newMktreeBuilder(repo)
+	empty := true
+	for {
+		line, err := reader.ReadString(delim)
+		if err != nil && err != io.EOF {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		atEOF := err == io.EOF
+		line = strings.TrimSuffix(line, string(delim))
+
+		switch {
+		case line == "" && atEOF:
+			if !batch || !empty {
+				writeMktree(builder)
+			}
+			return
+		case line == "" && batch:
+			writeMktree(builder)
+			builder = newMktreeBuilder(repo)
+			empty = true
+			continue
+		}
+
+		mode, objType, hash, name := parseMktreeLine(line)
+		checkMktreeEntry(repo, mode, objType, hash, name, allowMissing)
+		if err := builder.Insert(name, mode, hash); err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		empty = false
+		if atEOF {
+			writeMktree(builder)
+			return
+		}
+	}
+}
+
+// newMktreeBuilder starts an empty tree
+func newMktreeBuilder(repo *objects.Repository) *objects.TreeBuilder {
+	builder, err := repo.NewTreeBuilder("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	return builder
+}
+
+// writeMktree writes the tree and prints its hash
+func writeMktree(builder *objects.TreeBuilder) {
+	hash, err := builder.Write()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	fmt.Println(hash)
+}
+
+// parseMktreeLine splits "<mode> SP <type> SP <object> TAB <name>"
+func parseMktreeLine(line string) (mode, objType, hash, name string) {
+	meta, name, ok := strings.Cut(line, "\t")
+	fields := strings.Split(meta, " ")
+	if !ok || len(fields) != 3 {
+		fmt.Fprintf(os.Stderr, "fatal: input format error: %s\n", line)
+		os.Exit(128)
+	}
+	if strings.Contains(name, "/") {
+		fmt.Fprintf(os.Stderr, "fatal: path %s contains slash\n", name)
+		os.Exit(128)
+	}
+	return fields[0], fields[1], fields[2], name
+}
+
+// checkMktreeEntry checks that the mode, the stated type and the object's
+// actual type agree; the object may only be absent with --missing
+func checkMktreeEntry(repo *objects.Repository, mode, objType, hash, name string, allowMissing bool) {
+	modeType := "blob"
+	switch mode {
+	case "40000", "040000":
+		modeType = "tree"
+	case "160000":
+		modeType = "commit"
+	}
+	if objType != modeType {
+		fmt.Fprintf(os.Stderr, "fatal: entry '%s' object type (%s) doesn't match mode type (%s)\n", name, objType, modeType)
+		os.Exit(128)
+	}
+	// gitlinks name commits in another repository
+	if modeType == "commit" {
+		return
+	}
+
+	obj, err := repo.ReadObject(hash)
+	if err != nil {
+		if allowMissing {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "fatal: entry '%s' object %s is unavailable\n", name, hash)
+		os.Exit(128)
+	}
+	if obj.Type != objType {
+		fmt.Fprintf(os.Stderr, "fatal: entry '%s' object %s is a %s but specified type was (%s)\n", name, hash, obj.Type, objType)
+		os.Exit(128)
+	}
+}
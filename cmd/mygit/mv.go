@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/index"
+)
+
+// mvCommand implements `mv [-f] [-k] [-n] [-v] <source>... <destination>`.
+// Each tracked file or directory is renamed in the work tree and in the
+// index. With several sources, or when the destination is a directory, the
+// sources are moved into it. -k skips sources that cannot be moved instead
+// of failing.
+func mvCommand(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "usage: mygit mv [-f] [-k] [-n] [-v] <source>... <destination>\n")
+		os.Exit(129)
+	}
+
+	var force, skipErrors, dryRun, verbose bool
+	var paths []string
+	for i, arg := range args {
+		if arg == "--" {
+			paths = append(paths, args[i+1:]...)
+			break
+		}
+		switch arg {
+		case "-f", "--force":
+			force = true
+		case "-k":
+			skipErrors = true
+		case "-n", "--dry-run":
+			dryRun = true
+		case "-v", "--verbose":
+			verbose = true
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				usage()
+			}
+			paths = append(paths, arg)
+		}
+	}
+	if len(paths) < 2 {
+		usage()
+	}
+
+	repo := openWorkTree()
+	idx := openIndex(repo)
+	sources, destArg := paths[:len(paths)-1], paths[len(paths)-1]
+	dest := parsePathspecs(repo, []string{destArg})[0].path
+
+	destInfo, err := os.Stat(filepath.Join(repo.WorkTree, filepath.FromSlash(dest)))
+	intoDir := err == nil && destInfo.IsDir()
+	if len(sources) > 1 && !intoDir {
+		fmt.Fprintf(os.Stderr, "fatal: destination '%s' is not a directory\n", dest)
+		os.Exit(128)
+	}
+
+	// tracked returns the index entries at or below path
+	tracked := func(path string) []*index.Entry {
+		var entries []*index.Entry
+		for _, e := range idx.Entries {
+			if e.Path == path || strings.HasPrefix(e.Path, path+"/") {
+				entries = append(entries, e)
+			}
+		}
+		return entries
+	}
+
+	type move struct {
+		src, dst string
+		entries  []*index.Entry
+	}
+	var moves []move
+	for _, srcArg := range sources {
+		src := parsePathspecs(repo, []string{srcArg})[0].path
+		mv := move{src: src, dst: dest}
+		if intoDir {
+			mv.dst = strings.TrimPrefix(dest+"/"+filepath.Base(filepath.FromSlash(src)), "/")
+		}
+		mv.entries = tracked(src)
+
+		var problem string
+		srcInfo, err := os.Lstat(filepath.Join(repo.WorkTree, filepath.FromSlash(src)))
+		dstInfo, dstErr := os.Lstat(filepath.Join(repo.WorkTree, filepath.FromSlash(mv.dst)))
+		switch {
+		case src == "" || err != nil:
+			problem = "bad source"
+		case mv.dst == src || strings.HasPrefix(mv.dst, src+"/"):
+			problem = "can not move directory into itself"
+		case srcInfo.IsDir() && len(mv.entries) == 0:
+			problem = "source directory is empty"
+		case len(mv.entries) == 0:
+			problem = "not under version control"
+		case dstErr == nil && !force:
+			problem = "destination exists"
+		case dstErr == nil && (srcInfo.IsDir() || dstInfo.IsDir()):
+			// only files can overwrite each other
+			problem = "Cannot overwrite"
+		case dstErr != nil && !isMissing(dstErr):
+			problem = dstErr.Error()
+		}
+		if problem == "" {
+			if _, err := os.Stat(filepath.Dir(filepath.Join(repo.WorkTree, filepath.FromSlash(mv.dst)))); err != nil {
+				problem = "destination directory does not exist"
+			}
+		}
+		if problem != "" {
+			if skipErrors {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "fatal: %s, source=%s, destination=%s\n", problem, src, mv.dst)
+			os.Exit(128)
+		}
+		if dstErr == nil && verbose {
+			fmt.Fprintf(os.Stderr, "warning: overwriting '%s'\n", mv.dst)
+		}
+		moves = append(moves, mv)
+	}
+
+	// a directory is reported along with each tracked file moved with it
+	renamed := func(mv move, report func(src, dst string)) {
+		report(mv.src, mv.dst)
+		for i, e := range mv.entries {
+			if e.Path != mv.src && (i == 0 || mv.entries[i-1].Path != e.Path) {
+				report(e.Path, mv.dst+strings.TrimPrefix(e.Path, mv.src))
+			}
+		}
+	}
+	if dryRun {
+		for _, mv := range moves {
+			renamed(mv, func(src, dst string) { fmt.Printf("Checking rename of '%s' to '%s'\n", src, dst) })
+		}
+	}
+	for _, mv := range moves {
+		if dryRun || verbose {
+			renamed(mv, func(src, dst string) { fmt.Printf("Renaming %s to %s\n", src, dst) })
+		}
+		if dryRun {
+			continue
+		}
+		from := filepath.Join(repo.WorkTree, filepath.FromSlash(mv.src))
+		to := filepath.Join(repo.WorkTree, filepath.FromSlash(mv.dst))
+		if err := os.Rename(from, to); err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: renaming '%s' failed: %s\n", mv.src, err)
+			os.Exit(128)
+		}
+		for _, e := range mv.entries {
+			idx.Remove(e.Path)
+			moved := *e
+			moved.Path = mv.dst + strings.TrimPrefix(e.Path, mv.src)
+			idx.Add(&moved)
+		}
+	}
+	if !dryRun && len(moves) > 0 {
+		writeIndex(idx)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+	"github.com/master-wayne7/go-git/internal/refs"
+)
+
+// defaultReflogExpire matches git's gc.reflogExpire default of 90 days
+const defaultReflogExpire = 90 * 24 * time.Hour
+
+// reflogCommand implements `reflog [show] [<ref>]`, `reflog exists <ref>`
+// and `reflog expire [--expire=<time>] [--dry-run] (--all | <ref>...)`
+func reflogCommand(args []string) {
+	sub := "show"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "show", "expire", "exists":
+			sub, args = args[0], args[1:]
+		}
+	}
+
+	repo := openRepository()
+	switch sub {
+	case "show":
+		reflogShow(repo, args)
+	case "exists":
+		if len(args) != 1 {
+			fmt.Fprintf(os.Stderr, "usage: mygit reflog exists <ref>\n")
+			os.Exit(129)
+		}
+		if !repo.Refs.HasReflog(args[0]) {
+			os.Exit(1)
+		}
+	case "expire":
+		reflogExpire(repo, args)
+	}
+}
+
+// reflogShow prints a ref's reflog newest first as "<abbrev> <ref>@{n}: <msg>"
+func reflogShow(repo *objects.Repository, args []string) {
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "usage: mygit reflog show [<ref>]\n")
+		os.Exit(129)
+	}
+	display := "HEAD"
+	if len(args) == 1 {
+		display = args[0]
+	}
+	name, ok := reflogName(repo.Refs, display)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fatal: ambiguous argument '%s': unknown revision or path not in the working tree.\n", display)
+		os.Exit(128)
+	}
+
+	entries, err := repo.Refs.ReadReflog(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		short, err := repo.ShortHash(entries[i].New, 7)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		fmt.Printf("%s %s@{%d}: %s\n", short, display, len(entries)-1-i, entries[i].Message)
+	}
+}
+
+// reflogExpire prunes reflog entries older than the --expire time
+func reflogExpire(repo *objects.Repository, args []string) {
+	cutoff := time.Now().Add(-defaultReflogExpire)
+	var all, dryRun bool
+	var names []string
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--expire="):
+			t, err := parseExpiry(strings.TrimPrefix(arg, "--expire="))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s\n", err)
+				os.Exit(129)
+			}
+			cutoff = t
+		case arg == "--all":
+			all = true
+		case arg == "-n" || arg == "--dry-run":
+			dryRun = true
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(os.Stderr, "usage: mygit reflog expire [--expire=<time>] [--dry-run] (--all | <ref>...)\n")
+			os.Exit(129)
+		default:
+			name, ok := reflogName(repo.Refs, arg)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "error: reflog could not be found: '%s'\n", arg)
+				os.Exit(1)
+			}
+			names = append(names, name)
+		}
+	}
+	if all {
+		var err error
+		if names, err = repo.Refs.Reflogs(); err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+	}
+
+	keep := func(e *refs.ReflogEntry) bool { return !e.When.Before(cutoff) }
+	for _, name := range names {
+		if dryRun {
+			entries, err := repo.Refs.ReadReflog(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+				os.Exit(128)
+			}
+			for _, entry := range entries {
+				if !keep(entry) {
+					fmt.Printf("would prune %s", entry)
+				}
+			}
+			continue
+		}
+		if _, err := repo.Refs.ExpireReflog(name, keep); err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+	}
+}
+
+// reflogName maps a name as typed by the user to a ref that has a reflog,
+// trying the usual refs/, refs/heads/, refs/tags/ and refs/remotes/ prefixes
+func reflogName(store *refs.Store, name string) (string, bool) {
+	for _, prefix := range []string{"", "refs/", "refs/tags/", "refs/heads/", "refs/remotes/"} {
+		if store.HasReflog(prefix + name) {
+			return prefix + name, true
+		}
+	}
+	return "", false
+}
+
+// parseExpiry parses an --expire value: "now", "all", "never", a unix
+// timestamp, a date (YYYY-MM-DD) or an approximate "<n>.<unit>.ago"
+func parseExpiry(value string) (time.Time, error) {
+	now := time.Now()
+	switch value {
+	case "now", "all":
+		// a cutoff in the future expires every entry, even ones written this second
+		return now.Add(time.Second), nil
+	case "never", "false":
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == '.' || r == ' ' })
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		if err == nil && n >= 0 {
+			unit := strings.TrimSuffix(fields[1], "s")
+			switch unit {
+			case "second":
+				return now.Add(-time.Duration(n) * time.Second), nil
+			case "minute":
+				return now.Add(-time.Duration(n) * time.Minute), nil
+			case "hour":
+				return now.Add(-time.Duration(n) * time.Hour), nil
+			case "day":
+				return now.AddDate(0, 0, -n), nil
+			case "week":
+				return now.AddDate(0, 0, -7*n), nil
+			case "month":
+				return now.AddDate(0, -n, 0), nil
+			case "year":
+				return now.AddDate(-n, 0, 0), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry time '%s'", value)
+}
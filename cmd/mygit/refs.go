@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/refs"
+)
+
+// showRef implements `show-ref [--head] [--heads] [--tags] [-d]`
+func showRef(args []string) {
+	var head, dereference bool
+	var prefixes []string
+	for _, arg := range args {
+		switch arg {
+		case "--head":
+			head = true
+		case "--heads":
+			prefixes = append(prefixes, "refs/heads/")
+		case "--tags":
+			prefixes = append(prefixes, "refs/tags/")
+		case "-d", "--dereference":
+			dereference = true
+		default:
+			fmt.Fprintf(os.Stderr, "usage: mygit show-ref [--head] [--heads] [--tags] [-d]\n")
+			os.Exit(129)
+		}
+	}
+	if len(prefixes) == 0 {
+		prefixes = []string{"refs/"}
+	}
+
+	repo := openRepository()
+	if head {
+		if hash, err := repo.Refs.Resolve("HEAD"); err == nil {
+			fmt.Printf("%s HEAD\n", hash)
+		}
+	}
+
+	found := false
+	for _, prefix := range prefixes {
+		list, err := repo.Refs.List(prefix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		for _, ref := range list {
+			hash := ref.Hash
+			if ref.IsSymbolic() {
+				if hash, err = repo.Refs.Resolve(ref.Name); err != nil {
+					continue
+				}
+			}
+			found = true
+			fmt.Printf("%s %s\n", hash, ref.Name)
+
+			if dereference {
+				peeled := ref.Peeled
+				if peeled == "" {
+					if peeled, err = repo.PeelTag(hash); err != nil {
+						continue
+					}
+				}
+				if peeled != hash {
+					fmt.Printf("%s %s^{}\n", peeled, ref.Name)
+				}
+			}
+		}
+	}
+	if !found && !head {
+		os.Exit(1)
+	}
+}
+
+// updateRef implements `update-ref <ref> <new> [<old>]` and
+// `update-ref -d <ref> [<old>]`
+func updateRef(args []string) {
+	var del bool
+	var msg string
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-d":
+			del = true
+		case "-m":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "error: switch `m' requires a value\n")
+				os.Exit(129)
+			}
+			i++
+			msg = args[i]
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+
+	repo := openRepository()
+	if del {
+		if len(positional) < 1 || len(positional) > 2 {
+			fmt.Fprintf(os.Stderr, "usage: mygit update-ref -d <ref> [<old>]\n")
+			os.Exit(129)
+		}
+		var oldHash string
+		if len(positional) == 2 {
+			oldHash = resolveRevision(repo, positional[1])
+		}
+		if err := repo.Refs.Delete(positional[0], oldHash); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(positional) < 2 || len(positional) > 3 {
+		fmt.Fprintf(os.Stderr, "usage: mygit update-ref [-m <reason>] <ref> <new> [<old>]\n")
+		os.Exit(129)
+	}
+	name, newHash := positional[0], resolveRevision(repo, positional[1])
+	var oldHash string
+	if len(positional) == 3 {
+		oldHash = resolveRevision(repo, positional[2])
+	}
+	if !repo.Objects.Has(newHash) {
+		fmt.Fprintf(os.Stderr, "fatal: %s: not a valid SHA1\n", newHash)
+		os.Exit(128)
+	}
+	if err := repo.Refs.Update(name, newHash, oldHash, msg); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+}
+
+// symbolicRef implements `symbolic-ref [--short] [-m <reason>] <name> [<ref>]`
+func symbolicRef(args []string) {
+	var short bool
+	var msg string
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--short":
+			short = true
+		case "-m":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "error: switch `m' requires a value\n")
+				os.Exit(129)
+			}
+			i++
+			msg = args[i]
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+
+	repo := openRepository()
+	switch len(positional) {
+	case 1:
+		ref, err := repo.Refs.Read(positional[0])
+		if err != nil || !ref.IsSymbolic() {
+			fmt.Fprintf(os.Stderr, "fatal: ref %s is not a symbolic ref\n", positional[0])
+			os.Exit(128)
+		}
+		target := ref.Target
+		if short {
+			target = shortRefName(target)
+		}
+		fmt.Println(target)
+	case 2:
+		if err := repo.Refs.SetSymbolic(positional[0], positional[1], msg); err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "usage: mygit symbolic-ref [--short] [-m <reason>] <name> [<ref>]\n")
+		os.Exit(129)
+	}
+}
+
+// checkRefFormat implements `check-ref-format [--allow-onelevel] <refname>`
+func checkRefFormat(args []string) {
+	allowOneLevel := false
+	if len(args) == 2 && args[0] == "--allow-onelevel" {
+		allowOneLevel = true
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: mygit check-ref-format [--allow-onelevel] <refname>\n")
+		os.Exit(129)
+	}
+	if err := refs.CheckRefFormat(args[0], allowOneLevel); err != nil {
+		os.Exit(1)
+	}
+}
+
+// packRefs implements `pack-refs [--all]`
+func packRefs(args []string) {
+	all := len(args) == 1 && args[0] == "--all"
+	if len(args) > 0 && !all {
+		fmt.Fprintf(os.Stderr, "usage: mygit pack-refs [--all]\n")
+		os.Exit(129)
+	}
+
+	repo := openRepository()
+	peel := func(hash string) (string, error) {
+		peeled, err := repo.PeelTag(hash)
+		if err != nil || peeled == hash {
+			return "", err
+		}
+		return peeled, nil
+	}
+	if err := repo.Refs.PackRefs(all, peel); err != nil {
+		if errors.Is(err, refs.ErrLocked) {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// shortRefName strips the refs/heads/, refs/tags/ or refs/remotes/ prefix
+func shortRefName(name string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if short, ok := strings.CutPrefix(name, prefix); ok {
+			return short
+		}
+	}
+	return name
+}
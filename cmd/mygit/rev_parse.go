@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// revParse implements `rev-parse [--verify] [-q] [--short[=<n>]]
+// [--abbrev-ref | --symbolic-full-name] <rev>...` along with the
+// --git-dir, --show-toplevel, --is-bare-repository and
+// --is-inside-work-tree queries. Ranges (A..B) and exclusions (^A) print
+// the negated side as "^<sha>" like git.
+func revParse(args []string) {
+	var verify, quiet, abbrevRef, fullName bool
+	short := 0
+	var revs []string
+
+	repo := openRepository()
+	for _, arg := range args {
+		switch {
+		case arg == "--verify":
+			verify = true
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case arg == "--short":
+			// like git, --short implies --verify
+			verify, short = true, 7
+		case strings.HasPrefix(arg, "--short="):
+			verify = true
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--short="))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "fatal: invalid --short value '%s'\n", arg)
+				os.Exit(129)
+			}
+			short = n
+		case arg == "--abbrev-ref":
+			abbrevRef = true
+		case arg == "--symbolic-full-name":
+			fullName = true
+		case arg == "--git-dir":
+			fmt.Println(repo.GitDir)
+		case arg == "--show-toplevel":
+			if repo.IsBare() {
+				fmt.Fprintf(os.Stderr, "fatal: this operation must be run in a work tree\n")
+				os.Exit(128)
+			}
+			fmt.Println(repo.WorkTree)
+		case arg == "--is-bare-repository":
+			fmt.Println(repo.IsBare())
+		case arg == "--is-inside-work-tree":
+			fmt.Println(!repo.IsBare())
+		case arg == "--":
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			fmt.Fprintf(os.Stderr, "fatal: unsupported option '%s'\n", arg)
+			os.Exit(129)
+		default:
+			revs = append(revs, arg)
+		}
+	}
+
+	if verify && len(revs) != 1 {
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "fatal: Needed a single revision\n")
+		}
+		os.Exit(128)
+	}
+
+	for _, rev := range revs {
+		if abbrevRef || fullName {
+			name, err := repo.SymbolicFullName(rev)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+				os.Exit(128)
+			}
+			if name == "" {
+				// not a ref: the object name itself is the only answer
+				if _, err := repo.ResolveRevision(rev); err != nil {
+					revParseFailed(rev, err, verify, quiet)
+				}
+				if abbrevRef {
+					fmt.Println(rev)
+				}
+				continue
+			}
+			if abbrevRef {
+				name = shortRefName(name)
+			}
+			fmt.Println(name)
+			continue
+		}
+
+		if !verify {
+			// A..B means B excluding A; an empty side means HEAD
+			if left, right, ok := strings.Cut(rev, ".."); ok && !strings.HasPrefix(right, ".") {
+				if left == "" {
+					left = "HEAD"
+				}
+				if right == "" {
+					right = "HEAD"
+				}
+				printRevision(repo, right, "", short, verify, quiet)
+				printRevision(repo, left, "^", short, verify, quiet)
+				continue
+			}
+			if excluded, ok := strings.CutPrefix(rev, "^"); ok {
+				printRevision(repo, excluded, "^", short, verify, quiet)
+				continue
+			}
+		}
+		printRevision(repo, rev, "", short, verify, quiet)
+	}
+}
+
+// printRevision resolves rev and prints it, abbreviated if short > 0
+func printRevision(repo *objects.Repository, rev, prefix string, short int, verify, quiet bool) {
+	hash, err := repo.ResolveRevision(rev)
+	if err == nil && verify && !repo.Objects.Has(hash) {
+		err = fmt.Errorf("%s: %w", rev, objects.ErrUnknownRevision)
+	}
+	if err != nil {
+		revParseFailed(rev, err, verify, quiet)
+	}
+	if short > 0 {
+		if hash, err = repo.ShortHash(hash, short); err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+	}
+	fmt.Println(prefix + hash)
+}
+
+// revParseFailed reports a revision that could not be resolved and exits
+func revParseFailed(rev string, err error, verify, quiet bool) {
+	if quiet {
+		os.Exit(1)
+	}
+	if errors.Is(err, objects.ErrAmbiguousRevision) {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	}
+	if verify {
+		fmt.Fprintf(os.Stderr, "fatal: Needed a single revision\n")
+	} else {
+		fmt.Fprintf(os.Stderr, "fatal: ambiguous argument '%s': unknown revision or path not in the working tree.\n", rev)
+	}
+	os.Exit(128)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/index"
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// rmCommand implements `rm [-f] [--cached] [-r] [-n] [-q] [--ignore-unmatch]
+// [--] <pathspec>...`. Tracked files are removed from the index and, without
+// --cached, from the work tree. Unless -f is given, files whose content
+// would be lost are refused.
+func rmCommand(args []string) {
+	var cached, force, recursive, dryRun, quiet, ignoreUnmatch bool
+	var paths []string
+	for i, arg := range args {
+		if arg == "--" {
+			paths = append(paths, args[i+1:]...)
+			break
+		}
+		switch arg {
+		case "--cached":
+			cached = true
+		case "-f", "--force":
+			force = true
+		case "-r":
+			recursive = true
+		case "-n", "--dry-run":
+			dryRun = true
+		case "-q", "--quiet":
+			quiet = true
+		case "--ignore-unmatch":
+			ignoreUnmatch = true
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				fmt.Fprintf(os.Stderr, "usage: mygit rm [-f] [--cached] [-r] [-n] [-q] [--ignore-unmatch] [--] <pathspec>...\n")
+				os.Exit(129)
+			}
+			paths = append(paths, arg)
+		}
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "fatal: No pathspec was given. Which files should I remove?\n")
+		os.Exit(128)
+	}
+
+	repo := openWorkTree()
+	specs := parsePathspecs(repo, paths)
+	idx := openIndex(repo)
+
+	var removed []string
+	matched := make([]bool, len(specs))
+	for i, e := range idx.Entries {
+		if i > 0 && idx.Entries[i-1].Path == e.Path {
+			continue
+		}
+		n := matchAny(specs, e.Path)
+		if n < 0 {
+			continue
+		}
+		s := specs[n]
+		if !recursive && e.Path != s.path && (s.path == "" || strings.HasPrefix(e.Path, s.path+"/")) {
+			fmt.Fprintf(os.Stderr, "fatal: not removing '%s' recursively without -r\n", s.arg)
+			os.Exit(128)
+		}
+		matched[n] = true
+		removed = append(removed, e.Path)
+	}
+	for n, s := range specs {
+		if !matched[n] && !ignoreUnmatch {
+			fmt.Fprintf(os.Stderr, "fatal: pathspec '%s' did not match any files\n", s.arg)
+			os.Exit(128)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	if !force {
+		checkRemovable(repo, idx, removed, cached)
+	}
+	for _, path := range removed {
+		if !quiet {
+			fmt.Printf("rm '%s'\n", path)
+		}
+		idx.Remove(path)
+	}
+	if dryRun {
+		return
+	}
+
+	if !cached {
+		for _, path := range removed {
+			if err := removeWorkTreeFile(repo, path); err != nil {
+				fmt.Fprintf(os.Stderr, "fatal: git rm: '%s': %s\n", path, err)
+				os.Exit(128)
+			}
+		}
+	}
+	writeIndex(idx)
+}
+
+// checkRemovable refuses to remove files whose staged content would be
+// lost: content that differs from HEAD, unless it is still in the work tree
+// and only the index entry goes (--cached), and work tree changes that were
+// never staged, unless the work tree file is kept
+func checkRemovable(repo *objects.Repository, idx *index.Index, paths []string, cached bool) {
+	head := readHeadIndex(repo)
+	var both, staged, local []string
+	for _, path := range paths {
+		e := idx.Entry(path)
+		if e == nil {
+			// unmerged paths can always be removed
+			continue
+		}
+		full := filepath.Join(repo.WorkTree, filepath.FromSlash(path))
+		info, err := os.Lstat(full)
+		if err != nil || index.ModeOf(full, info) == 0 {
+			// already gone from the work tree
+			continue
+		}
+
+		localChanges, err := workTreeModified(repo, idx, e, info)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		h := head.Entry(path)
+		stagedChanges := h == nil || h.Mode != e.Mode || h.Hash != e.Hash
+
+		switch {
+		case localChanges && stagedChanges:
+			if !cached || !e.IntentToAdd {
+				both = append(both, path)
+			}
+		case cached:
+		case stagedChanges:
+			staged = append(staged, path)
+		case localChanges:
+			local = append(local, path)
+		}
+	}
+
+	failed := false
+	report := func(paths []string, singular, plural, hint string) {
+		if len(paths) == 0 {
+			return
+		}
+		failed = true
+		message := singular
+		if len(paths) > 1 {
+			message = plural
+		}
+		fmt.Fprintf(os.Stderr, "error: %s\n", message)
+		for _, path := range paths {
+			fmt.Fprintf(os.Stderr, "    %s\n", path)
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", hint)
+	}
+	report(both,
+		"the following file has staged content different from both the\nfile and the HEAD:",
+		"the following files have staged content different from both the\nfile and the HEAD:",
+		"(use -f to force removal)")
+	report(staged,
+		"the following file has changes staged in the index:",
+		"the following files have changes staged in the index:",
+		"(use --cached to keep the file, or -f to force removal)")
+	report(local,
+		"the following file has local modifications:",
+		"the following files have local modifications:",
+		"(use --cached to keep the file, or -f to force removal)")
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// removeWorkTreeFile deletes a file and then any parent directories it
+// leaves empty, stopping at the top of the work tree. Directories in the
+// file's place, such as nested repositories, are left alone.
+func removeWorkTreeFile(repo *objects.Repository, path string) error {
+	full := filepath.Join(repo.WorkTree, filepath.FromSlash(path))
+	if info, err := os.Lstat(full); err == nil && info.IsDir() {
+		return nil
+	}
+	if err := os.Remove(full); err != nil && !isMissing(err) {
+		return err
+	}
+	for dir := filepath.Dir(full); dir != repo.WorkTree && strings.HasPrefix(dir, repo.WorkTree); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
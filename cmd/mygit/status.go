@@ -0,0 +1,852 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/diff"
+	"github.com/master-wayne7/go-git/internal/index"
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// statusEntry is a tracked path that differs between HEAD, the index and
+// the work tree
+type statusEntry struct {
+	path string
+	// origPath is the HEAD path of a staged rename
+	origPath string
+	// x and y are the short format codes for the index (against HEAD) and
+	// the work tree (against the index); ' ' means unchanged
+	x, y byte
+	// head and staged are the HEAD and index entries, nil when absent
+	head, staged *index.Entry
+	// stages holds the conflict stages 1-3 of an unmerged path
+	stages   [3]*index.Entry
+	unmerged bool
+	// wtMode is the mode of the work tree file, 0 when it is missing
+	wtMode uint32
+}
+
+// statusReport is everything status shows
+type statusReport struct {
+	// branch is the short name of the current branch, "" when detached
+	branch string
+	// head is the HEAD commit, "" on an unborn branch
+	head string
+	// upstream is the short name of the branch's upstream, e.g. origin/main
+	upstream      string
+	upstreamGone  bool
+	ahead, behind int
+	// merging is set while a merge waits to be concluded (MERGE_HEAD exists)
+	merging bool
+	// commit is set when commit explains why there is nothing to commit,
+	// and amend when that commit would have replaced HEAD
+	commit, amend bool
+
+	entries   []*statusEntry
+	untracked []string
+	ignored   []string
+}
+
+// statusCommand implements `status [-s | --long | --porcelain[=v1|v2]] [-b]
+// [-z] [--ignored] [-u[<mode>]] [--] [<pathspec>...]`. It compares HEAD with
+// the index and the index with the work tree, then lists untracked files
+// (whole directories are shown as "dir/" unless -uall is given) and, with
+// --ignored, ignored ones.
+func statusCommand(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "usage: mygit status [-s | --long | --porcelain[=v1|v2]] [-b] [-z] [--ignored] [-u[<mode>]] [--] [<pathspec>...]\n")
+		os.Exit(129)
+	}
+
+	format := "long"
+	var showBranch, nulTerminated, showIgnored bool
+	untrackedMode := "normal"
+	var paths []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			paths = append(paths, args[i+1:]...)
+			break
+		}
+		// split bundled switches such as -sb
+		if len(arg) > 2 && arg[0] == '-' && strings.Trim(arg[1:], "sbz") == "" {
+			bundle := make([]string, 0, len(args)+len(arg))
+			bundle = append(bundle, args[:i]...)
+			for _, c := range arg[1:] {
+				bundle = append(bundle, "-"+string(c))
+			}
+			args = append(bundle, args[i+1:]...)
+			arg = args[i]
+		}
+		switch {
+		case arg == "-s" || arg == "--short":
+			format = "short"
+		case arg == "--long":
+			format = "long"
+		case arg == "--porcelain" || arg == "--porcelain=v1":
+			format = "v1"
+		case arg == "--porcelain=v2":
+			format = "v2"
+		case arg == "-b" || arg == "--branch":
+			showBranch = true
+		case arg == "-z":
+			nulTerminated = true
+		case arg == "--ignored" || arg == "--ignored=traditional":
+			showIgnored = true
+		case arg == "--ignored=no":
+			showIgnored = false
+		case arg == "-u" || arg == "--untracked-files":
+			untrackedMode = "all"
+		case strings.HasPrefix(arg, "-u") || strings.HasPrefix(arg, "--untracked-files="):
+			untrackedMode = strings.TrimPrefix(strings.TrimPrefix(arg, "-u"), "--untracked-files=")
+			if untrackedMode != "no" && untrackedMode != "normal" && untrackedMode != "all" {
+				fmt.Fprintf(os.Stderr, "fatal: Invalid untracked files mode '%s'\n", untrackedMode)
+				os.Exit(128)
+			}
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				usage()
+			}
+			paths = append(paths, arg)
+		}
+	}
+	if nulTerminated && (format == "long" || format == "short") {
+		format = "v1"
+	}
+
+	repo := openWorkTree()
+	prefix, err := repoRelative(repo, ".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	specs := parsePathspecs(repo, paths)
+	report := collectStatus(repo, openIndex(repo), readHeadIndex(repo), specs, untrackedMode, showIgnored)
+
+	switch format {
+	case "long":
+		printLongStatus(repo, report, prefix, untrackedMode)
+	case "short":
+		printShortStatus(report, prefix, showBranch, false)
+	case "v1":
+		printShortStatus(report, "", showBranch, nulTerminated)
+	case "v2":
+		printStatusV2(report, prefix, showBranch, nulTerminated)
+	}
+}
+
+// collectStatus compares head (normally HEAD's tree), the index and the work
+// tree. Files that only differ from the index in stat data are hashed, and
+// their refreshed stat data is saved so the next run can skip them.
+func collectStatus(repo *objects.Repository, idx, head *index.Index, specs []pathspec, untrackedMode string, showIgnored bool) *statusReport {
+	r := &statusReport{}
+	readBranchStatus(repo, r)
+	if _, err := os.Stat(repo.Path("MERGE_HEAD")); err == nil {
+		r.merging = true
+	}
+
+	inSpecs := func(path string) bool {
+		return len(specs) == 0 || matchAny(specs, path) >= 0
+	}
+	byPath := make(map[string]*statusEntry)
+	entry := func(path string) *statusEntry {
+		e := byPath[path]
+		if e == nil {
+			e = &statusEntry{path: path, x: ' ', y: ' ', head: head.Entry(path), staged: idx.Entry(path)}
+			if e.staged != nil && e.staged.IntentToAdd {
+				// nothing is staged yet for an intent-to-add path
+				e.staged = nil
+			}
+			byPath[path] = e
+		}
+		return e
+	}
+
+	// HEAD against the index
+	for _, e := range idx.Entries {
+		if !inSpecs(e.Path) {
+			continue
+		}
+		if e.Stage != 0 {
+			s := entry(e.Path)
+			s.unmerged = true
+			s.stages[e.Stage-1] = e
+			continue
+		}
+		h := head.Entry(e.Path)
+		switch {
+		case e.IntentToAdd:
+			// reported as added in the work tree, not staged
+		case h == nil:
+			entry(e.Path).x = 'A'
+		case h.Mode&0170000 != e.Mode&0170000:
+			entry(e.Path).x = 'T'
+		case h.Mode != e.Mode || h.Hash != e.Hash:
+			entry(e.Path).x = 'M'
+		}
+	}
+	for _, h := range head.Entries {
+		if inSpecs(h.Path) && len(idx.Stages(h.Path)) == 0 {
+			entry(h.Path).x = 'D'
+		}
+	}
+	detectRenames(byPath)
+
+	// the index against the work tree
+	refreshed := false
+	for _, e := range idx.Entries {
+		if !inSpecs(e.Path) || e.AssumeValid || e.SkipWorktree {
+			continue
+		}
+		full := filepath.Join(repo.WorkTree, filepath.FromSlash(e.Path))
+		info, err := os.Lstat(full)
+		if err != nil && !isMissing(err) {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		var mode uint32
+		if err == nil {
+			mode = index.ModeOf(full, info)
+		}
+		if e.Stage != 0 {
+			byPath[e.Path].wtMode = mode
+			continue
+		}
+
+		y := byte(' ')
+		switch {
+		case mode == 0:
+			y = 'D'
+		case e.IntentToAdd:
+			y = 'A'
+		case mode&0170000 != e.Mode&0170000:
+			y = 'T'
+		default:
+			modified, err := workTreeModified(repo, idx, e, info)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+				os.Exit(128)
+			}
+			if modified {
+				y = 'M'
+			} else if e.Mode != index.ModeGitlink && !e.StatMatches(info) {
+				e.SetStat(info)
+				refreshed = true
+			}
+		}
+		if y != ' ' {
+			s := entry(e.Path)
+			s.y = y
+			s.wtMode = mode
+		}
+	}
+	if refreshed {
+		// like git, only refresh the index when nobody else holds its lock
+		idx.Write()
+	}
+
+	for _, e := range byPath {
+		r.entries = append(r.entries, e)
+	}
+	sort.Slice(r.entries, func(i, j int) bool { return r.entries[i].path < r.entries[j].path })
+
+	if untrackedMode != "no" {
+		r.untracked, r.ignored = collectUntracked(repo, idx, untrackedMode == "all")
+		keep := func(paths []string) []string {
+			var kept []string
+			for _, p := range paths {
+				if inSpecs(strings.TrimSuffix(p, "/")) {
+					kept = append(kept, p)
+				}
+			}
+			return kept
+		}
+		r.untracked = keep(r.untracked)
+		r.ignored = keep(r.ignored)
+		if !showIgnored {
+			r.ignored = nil
+		}
+	}
+	return r
+}
+
+// detectRenames pairs staged deletions with staged additions of the same
+// content and reports them as renames. Only exact renames are found.
+func detectRenames(byPath map[string]*statusEntry) {
+	var deleted, added []*statusEntry
+	for _, e := range byPath {
+		switch {
+		case e.unmerged:
+		case e.x == 'D':
+			deleted = append(deleted, e)
+		case e.x == 'A':
+			added = append(added, e)
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].path < deleted[j].path })
+	sort.Slice(added, func(i, j int) bool { return added[i].path < added[j].path })
+
+	from := make([]diff.File, len(deleted))
+	for i, d := range deleted {
+		from[i] = diff.File{Path: d.path, Mode: d.head.Mode, Hash: d.head.Hash}
+	}
+	to := make([]diff.File, len(added))
+	for i, a := range added {
+		to[i] = diff.File{Path: a.path, Mode: a.staged.Mode, Hash: a.staged.Hash}
+	}
+	for i, j := range diff.ExactRenames(from, to) {
+		a, d := added[i], deleted[j]
+		a.x = 'R'
+		a.origPath = d.path
+		a.head = d.head
+		delete(byPath, d.path)
+	}
+}
+
+// collectUntracked lists the untracked and the ignored files of the work
+// tree. Unless all is set, a directory holding no tracked files is listed
+// as "dir/": as untracked when it holds untracked files, as ignored when it
+// only holds ignored ones.
+func collectUntracked(repo *objects.Repository, idx *index.Index, all bool) (untracked, ignored []string) {
+	m := newIgnoreMatcher(repo)
+	trackedDirs := make(map[string]bool)
+	for _, e := range idx.Entries {
+		for dir := path.Dir(e.Path); dir != "."; dir = path.Dir(dir) {
+			trackedDirs[dir] = true
+		}
+	}
+
+	var scan func(dir string) (untracked, ignored []string)
+	scan = func(dir string) (untracked, ignored []string) {
+		var children []string
+		infos := make(map[string]os.FileInfo)
+		ignoredPaths := make(map[string]bool)
+		err := walkWorkTree(repo, m, dir, func(path string, info os.FileInfo, isIgnored bool) bool {
+			children = append(children, path)
+			infos[path] = info
+			ignoredPaths[path] = isIgnored
+			return false
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+
+		for _, child := range children {
+			full := filepath.Join(repo.WorkTree, filepath.FromSlash(child))
+			switch mode := index.ModeOf(full, infos[child]); {
+			case mode != 0:
+				if len(idx.Stages(child)) > 0 {
+					continue
+				}
+				name := child
+				if mode == index.ModeGitlink {
+					name += "/"
+				}
+				if ignoredPaths[child] {
+					ignored = append(ignored, name)
+				} else {
+					untracked = append(untracked, name)
+				}
+			case trackedDirs[child]:
+				u, ig := scan(child)
+				untracked = append(untracked, u...)
+				ignored = append(ignored, ig...)
+			case ignoredPaths[child]:
+				files := listWorkTreeFiles(repo, child)
+				if all {
+					ignored = append(ignored, files...)
+				} else if len(files) > 0 {
+					ignored = append(ignored, child+"/")
+				}
+			default:
+				u, ig := scan(child)
+				switch {
+				case all:
+					untracked = append(untracked, u...)
+					ignored = append(ignored, ig...)
+				case len(u) > 0:
+					untracked = append(untracked, child+"/")
+					ignored = append(ignored, ig...)
+				case len(ig) > 0:
+					ignored = append(ignored, child+"/")
+				}
+			}
+		}
+		return untracked, ignored
+	}
+
+	untracked, ignored = scan("")
+	sort.Strings(untracked)
+	sort.Strings(ignored)
+	return untracked, ignored
+}
+
+// listWorkTreeFiles returns every file below dir
+func listWorkTreeFiles(repo *objects.Repository, dir string) []string {
+	var files []string
+	walkWorkTree(repo, nil, dir, func(path string, info os.FileInfo, ignored bool) bool {
+		if index.ModeOf(filepath.Join(repo.WorkTree, filepath.FromSlash(path)), info) != 0 {
+			files = append(files, path)
+			return false
+		}
+		return true
+	})
+	return files
+}
+
+// readBranchStatus fills in the current branch, its upstream and how far
+// they have diverged
+func readBranchStatus(repo *objects.Repository, r *statusReport) {
+	r.head, _ = repo.Refs.Resolve("HEAD")
+	name, err := repo.Refs.Follow("HEAD")
+	if err != nil || !strings.HasPrefix(name, "refs/heads/") {
+		return
+	}
+	r.branch = strings.TrimPrefix(name, "refs/heads/")
+
+	upstream, err := repo.SymbolicFullName("HEAD@{upstream}")
+	if err != nil || upstream == "" || r.head == "" {
+		return
+	}
+	r.upstream = strings.TrimPrefix(strings.TrimPrefix(upstream, "refs/remotes/"), "refs/heads/")
+	theirs, err := repo.Refs.Resolve(upstream)
+	if err != nil {
+		r.upstreamGone = true
+		return
+	}
+	if r.ahead, r.behind, err = repo.AheadBehind(r.head, theirs); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+}
+
+// codes returns the two status letters of an entry; unmerged paths are
+// described by which sides still have the file
+func (e *statusEntry) codes() (byte, byte) {
+	if !e.unmerged {
+		return e.x, e.y
+	}
+	switch [3]bool{e.stages[0] != nil, e.stages[1] != nil, e.stages[2] != nil} {
+	case [3]bool{true, false, false}:
+		return 'D', 'D'
+	case [3]bool{false, true, false}:
+		return 'A', 'U'
+	case [3]bool{false, false, true}:
+		return 'U', 'A'
+	case [3]bool{true, true, false}:
+		return 'U', 'D'
+	case [3]bool{true, false, true}:
+		return 'D', 'U'
+	case [3]bool{false, true, true}:
+		return 'A', 'A'
+	}
+	return 'U', 'U'
+}
+
+// printShortStatus prints the --short and --porcelain (v1) formats. Paths
+// are relative to prefix, and quoted unless the output is NUL terminated.
+func printShortStatus(r *statusReport, prefix string, showBranch, nulTerminated bool) {
+	end := "\n"
+	if nulTerminated {
+		end = "\x00"
+	}
+	show := func(path string) string {
+		path = relativePath(prefix, path)
+		if nulTerminated {
+			return path
+		}
+		return quotePath(path, true)
+	}
+
+	if showBranch {
+		fmt.Printf("## %s%s", shortBranchLine(r), end)
+	}
+	for _, e := range r.entries {
+		x, y := e.codes()
+		switch {
+		case e.origPath == "":
+			fmt.Printf("%c%c %s%s", x, y, show(e.path), end)
+		case nulTerminated:
+			fmt.Printf("%c%c %s\x00%s\x00", x, y, show(e.path), show(e.origPath))
+		default:
+			fmt.Printf("%c%c %s -> %s\n", x, y, show(e.origPath), show(e.path))
+		}
+	}
+	for _, path := range r.untracked {
+		fmt.Printf("?? %s%s", show(path), end)
+	}
+	for _, path := range r.ignored {
+		fmt.Printf("!! %s%s", show(path), end)
+	}
+}
+
+// shortBranchLine describes the branch for the "## " header
+func shortBranchLine(r *statusReport) string {
+	switch {
+	case r.branch == "":
+		return "HEAD (no branch)"
+	case r.head == "":
+		return "No commits yet on " + r.branch
+	case r.upstream == "":
+		return r.branch
+	}
+	line := r.branch + "..." + r.upstream
+	switch {
+	case r.upstreamGone:
+		line += " [gone]"
+	case r.ahead > 0 && r.behind > 0:
+		line += fmt.Sprintf(" [ahead %d, behind %d]", r.ahead, r.behind)
+	case r.ahead > 0:
+		line += fmt.Sprintf(" [ahead %d]", r.ahead)
+	case r.behind > 0:
+		line += fmt.Sprintf(" [behind %d]", r.behind)
+	}
+	return line
+}
+
+// printStatusV2 prints the --porcelain=v2 format, which spells out the
+// modes and objects of each changed path. As in git, paths are relative to
+// prefix unless the output is NUL terminated.
+func printStatusV2(r *statusReport, prefix string, showBranch, nulTerminated bool) {
+	end := "\n"
+	show := func(path string) string { return quotePath(relativePath(prefix, path), false) }
+	if nulTerminated {
+		end = "\x00"
+		show = func(path string) string { return path }
+	}
+
+	if showBranch {
+		oid, head := r.head, r.branch
+		if oid == "" {
+			oid = "(initial)"
+		}
+		if head == "" {
+			head = "(detached)"
+		}
+		fmt.Printf("# branch.oid %s%s# branch.head %s%s", oid, end, head, end)
+		if r.upstream != "" {
+			fmt.Printf("# branch.upstream %s%s", r.upstream, end)
+			if !r.upstreamGone {
+				fmt.Printf("# branch.ab +%d -%d%s", r.ahead, r.behind, end)
+			}
+		}
+	}
+
+	for _, e := range r.entries {
+		x, y := e.codes()
+		if e.unmerged {
+			fmt.Printf("u %c%c %s %06o %06o %06o %06o %s %s %s %s%s", x, y, submoduleState(e, e.stages[1]),
+				entryMode(e.stages[0]), entryMode(e.stages[1]), entryMode(e.stages[2]), e.wtMode,
+				entryHash(e.stages[0]), entryHash(e.stages[1]), entryHash(e.stages[2]), show(e.path), end)
+			continue
+		}
+
+		wtMode := e.wtMode
+		if y == ' ' {
+			wtMode = entryMode(e.staged)
+		}
+		xy := strings.ReplaceAll(string([]byte{x, y}), " ", ".")
+		fields := fmt.Sprintf("%s %s %06o %06o %06o %s %s", xy, submoduleState(e, e.staged),
+			entryMode(e.head), entryMode(e.staged), wtMode, entryHash(e.head), entryHash(e.staged))
+		if e.origPath == "" {
+			fmt.Printf("1 %s %s%s", fields, show(e.path), end)
+			continue
+		}
+		sep := "\t"
+		if nulTerminated {
+			sep = "\x00"
+		}
+		fmt.Printf("2 %s R100 %s%s%s%s", fields, show(e.path), sep, show(e.origPath), end)
+	}
+	for _, path := range r.untracked {
+		fmt.Printf("? %s%s", show(path), end)
+	}
+	for _, path := range r.ignored {
+		fmt.Printf("! %s%s", show(path), end)
+	}
+}
+
+// submoduleState is the v2 submodule field: "N..." for ordinary files, and
+// "S" followed by whether the commit changed for gitlinks
+func submoduleState(e *statusEntry, staged *index.Entry) string {
+	if staged == nil || staged.Mode != index.ModeGitlink {
+		return "N..."
+	}
+	if e.y == 'M' {
+		return "SC.."
+	}
+	return "S..."
+}
+
+// entryMode returns an entry's mode, or 0 for a missing entry
+func entryMode(e *index.Entry) uint32 {
+	if e == nil {
+		return 0
+	}
+	return e.Mode
+}
+
+// entryHash returns an entry's object, or the null hash for a missing entry
+func entryHash(e *index.Entry) string {
+	if e == nil {
+		return strings.Repeat("0", 40)
+	}
+	return e.Hash
+}
+
+// printLongStatus prints the default human readable format
+func printLongStatus(repo *objects.Repository, r *statusReport, prefix, untrackedMode string) {
+	if r.branch != "" {
+		fmt.Printf("On branch %s\n", r.branch)
+	} else {
+		fmt.Printf("HEAD detached at %s\n", shortHash(repo, r.head))
+	}
+	printTrackingInfo(r)
+	if r.head == "" && r.commit {
+		fmt.Printf("\nInitial commit\n\n")
+	} else if r.head == "" {
+		fmt.Printf("\nNo commits yet\n\n")
+	}
+
+	show := func(path string) string { return quotePath(relativePath(prefix, path), false) }
+	var staged, unmerged, unstaged []*statusEntry
+	for _, e := range r.entries {
+		switch {
+		case e.unmerged:
+			unmerged = append(unmerged, e)
+		default:
+			if e.x != ' ' {
+				staged = append(staged, e)
+			}
+			if e.y != ' ' {
+				unstaged = append(unstaged, e)
+			}
+		}
+	}
+	if r.merging && len(unmerged) > 0 {
+		fmt.Printf("You have unmerged paths.\n  (fix conflicts and run \"git commit\")\n  (use \"git merge --abort\" to abort the merge)\n\n")
+	} else if r.merging {
+		fmt.Printf("All conflicts fixed but you are still merging.\n  (use \"git commit\" to conclude merge)\n\n")
+	}
+
+	// while merging, unstaging would lose the merge result, so git offers no hint
+	unstageHint := `  (use "git restore --staged <file>..." to unstage)` + "\n"
+	switch {
+	case r.merging:
+		unstageHint = ""
+	case r.head == "":
+		unstageHint = `  (use "git rm --cached <file>..." to unstage)` + "\n"
+	}
+
+	if len(staged) > 0 {
+		fmt.Printf("Changes to be committed:\n%s", unstageHint)
+		for _, e := range staged {
+			if e.origPath != "" {
+				fmt.Printf("\t%-12s%s -> %s\n", "renamed:", show(e.origPath), show(e.path))
+			} else {
+				fmt.Printf("\t%-12s%s\n", changeLabel(e.x), show(e.path))
+			}
+		}
+		fmt.Println()
+	}
+
+	if len(unmerged) > 0 {
+		fmt.Printf("Unmerged paths:\n%s", unstageHint)
+		resolveHint := `  (use "git add <file>..." to mark resolution)`
+		for _, e := range unmerged {
+			if x, y := e.codes(); x == 'D' || y == 'D' {
+				resolveHint = `  (use "git add/rm <file>..." as appropriate to mark resolution)`
+			}
+		}
+		fmt.Println(resolveHint)
+		for _, e := range unmerged {
+			fmt.Printf("\t%-17s%s\n", unmergedLabel(e.codes()), show(e.path))
+		}
+		fmt.Println()
+	}
+
+	if len(unstaged) > 0 {
+		addHint := `  (use "git add <file>..." to update what will be committed)`
+		for _, e := range unstaged {
+			if e.y == 'D' {
+				addHint = `  (use "git add/rm <file>..." to update what will be committed)`
+			}
+		}
+		fmt.Printf("Changes not staged for commit:\n%s\n", addHint)
+		fmt.Printf("  (use \"git restore <file>...\" to discard changes in working directory)\n")
+		for _, e := range unstaged {
+			fmt.Printf("\t%-12s%s\n", changeLabel(e.y), show(e.path))
+		}
+		fmt.Println()
+	}
+
+	if len(r.untracked) > 0 {
+		fmt.Printf("Untracked files:\n  (use \"git add <file>...\" to include in what will be committed)\n")
+		for _, path := range r.untracked {
+			fmt.Printf("\t%s\n", show(path))
+		}
+		fmt.Println()
+	}
+	if len(r.ignored) > 0 {
+		fmt.Printf("Ignored files:\n  (use \"git add -f <file>...\" to include in what will be committed)\n")
+		for _, path := range r.ignored {
+			fmt.Printf("\t%s\n", show(path))
+		}
+		fmt.Println()
+	}
+
+	switch {
+	case len(staged) > 0:
+		if untrackedMode == "no" {
+			fmt.Printf("Untracked files not listed (use -u option to show untracked files)\n")
+		}
+	case r.amend:
+		fmt.Printf("No changes\n")
+	case len(unstaged) > 0 || len(unmerged) > 0:
+		fmt.Printf("no changes added to commit (use \"git add\" and/or \"git commit -a\")\n")
+	case len(r.untracked) > 0:
+		fmt.Printf("nothing added to commit but untracked files present (use \"git add\" to track)\n")
+	case r.head == "":
+		fmt.Printf("nothing to commit (create/copy files and use \"git add\" to track)\n")
+	case untrackedMode == "no":
+		fmt.Printf("nothing to commit (use -u to show untracked files)\n")
+	default:
+		fmt.Printf("nothing to commit, working tree clean\n")
+	}
+}
+
+// printTrackingInfo explains how the branch relates to its upstream
+func printTrackingInfo(r *statusReport) {
+	if r.upstream == "" {
+		return
+	}
+	plural := func(n int) string {
+		if n == 1 {
+			return "commit"
+		}
+		return "commits"
+	}
+	switch {
+	case r.upstreamGone:
+		fmt.Printf("Your branch is based on '%s', but the upstream is gone.\n", r.upstream)
+		fmt.Printf("  (use \"git branch --unset-upstream\" to fixup)\n")
+	case r.ahead > 0 && r.behind > 0:
+		fmt.Printf("Your branch and '%s' have diverged,\nand have %d and %d different commits each, respectively.\n", r.upstream, r.ahead, r.behind)
+		fmt.Printf("  (use \"git pull\" to merge the remote branch into yours)\n")
+	case r.ahead > 0:
+		fmt.Printf("Your branch is ahead of '%s' by %d %s.\n", r.upstream, r.ahead, plural(r.ahead))
+		fmt.Printf("  (use \"git push\" to publish your local commits)\n")
+	case r.behind > 0:
+		fmt.Printf("Your branch is behind '%s' by %d %s, and can be fast-forwarded.\n", r.upstream, r.behind, plural(r.behind))
+		fmt.Printf("  (use \"git pull\" to update your local branch)\n")
+	default:
+		fmt.Printf("Your branch is up to date with '%s'.\n", r.upstream)
+	}
+	fmt.Println()
+}
+
+// changeLabel names a status letter in the long format
+func changeLabel(code byte) string {
+	switch code {
+	case 'A':
+		return "new file:"
+	case 'D':
+		return "deleted:"
+	case 'R':
+		return "renamed:"
+	case 'T':
+		return "typechange:"
+	}
+	return "modified:"
+}
+
+// unmergedLabel names the state of a conflicted path in the long format
+func unmergedLabel(x, y byte) string {
+	switch string([]byte{x, y}) {
+	case "DD":
+		return "both deleted:"
+	case "AU":
+		return "added by us:"
+	case "UD":
+		return "deleted by them:"
+	case "UA":
+		return "added by them:"
+	case "DU":
+		return "deleted by us:"
+	case "AA":
+		return "both added:"
+	}
+	return "both modified:"
+}
+
+// relativePath rewrites a path relative to the top of the work tree to be
+// relative to prefix, the current directory; "dir/" keeps its slash
+func relativePath(prefix, p string) string {
+	if prefix == "" {
+		return p
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(prefix), filepath.FromSlash(strings.TrimSuffix(p, "/")))
+	if err != nil {
+		return p
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasSuffix(p, "/") {
+		rel += "/"
+	}
+	return rel
+}
+
+// quotePath quotes a path the way git prints unusual names: in double
+// quotes, with C escapes for control characters, quotes, backslashes and
+// bytes outside ASCII. quoteSpace also quotes names containing spaces.
+func quotePath(p string, quoteSpace bool) string {
+	needsQuotes := false
+	for i := 0; i < len(p); i++ {
+		if c := p[i]; c < 0x20 || c == '"' || c == '\\' || c >= 0x7f || (quoteSpace && c == ' ') {
+			needsQuotes = true
+			break
+		}
+	}
+	if !needsQuotes {
+		return p
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\v':
+			b.WriteString(`\v`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+	"github.com/master-wayne7/go-git/internal/refs"
+	"github.com/master-wayne7/go-git/internal/wildmatch"
+)
+
+// tagCommand implements `tag [-l] [<pattern>...]`, `tag [-f] <name> [<rev>]`,
+// `tag [-f] -a (-m <msg> | -F <file>) <name> [<rev>]` and `tag -d <name>...`.
+// -m or -F without -a also create an annotated tag.
+func tagCommand(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "usage: mygit tag [-l] [<pattern>...]\n   or: mygit tag [-f] [-a] [(-m <msg>)... | -F <file>] <name> [<rev>]\n   or: mygit tag -d <name>...\n")
+		os.Exit(129)
+	}
+
+	var list, del, annotate, force, haveMessage bool
+	var messages []string
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-l", "--list":
+			list = true
+		case "-d", "--delete":
+			del = true
+		case "-a", "--annotate":
+			annotate = true
+		case "-f", "--force":
+			force = true
+		case "-m", "-F":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "error: switch `%s' requires a value\n", arg[1:])
+				usage()
+			}
+			i++
+			message := args[i]
+			if arg == "-F" {
+				content, err := readMessageFile(message)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "fatal: could not open or read '%s': %s\n", message, err)
+					os.Exit(128)
+				}
+				message = content
+			}
+			messages = append(messages, message)
+			haveMessage = true
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				usage()
+			}
+			positional = append(positional, arg)
+		}
+	}
+
+	repo := openRepository()
+	switch {
+	case del:
+		if list || annotate || haveMessage || len(positional) == 0 {
+			usage()
+		}
+		deleteTags(repo, positional)
+	case list || len(positional) == 0:
+		if annotate || haveMessage || force {
+			usage()
+		}
+		listTags(repo, positional)
+	default:
+		if len(positional) > 2 {
+			usage()
+		}
+		rev := "HEAD"
+		if len(positional) == 2 {
+			rev = positional[1]
+		}
+		var message *string
+		if annotate || haveMessage {
+			if !haveMessage {
+				fmt.Fprintf(os.Stderr, "fatal: no tag message given; use -m or -F\n")
+				os.Exit(128)
+			}
+			m := objects.CleanupMessage(strings.Join(messages, "\n\n"), true)
+			message = &m
+		}
+		createTag(repo, positional[0], rev, message, force)
+	}
+}
+
+// listTags prints the tags matching any of the patterns (all tags when
+// there are none), in refname order
+func listTags(repo *objects.Repository, patterns []string) {
+	tags, err := repo.Refs.List("refs/tags/")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	for _, ref := range tags {
+		name := strings.TrimPrefix(ref.Name, "refs/tags/")
+		if len(patterns) == 0 || matchesAny(patterns, name) {
+			fmt.Println(name)
+		}
+	}
+}
+
+// matchesAny reports whether name matches one of the wildcard patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if wildmatch.Match(pattern, name, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// createTag points refs/tags/<name> at rev, through a new tag object when
+// message is not nil. An existing tag is only replaced with force.
+func createTag(repo *objects.Repository, name, rev string, message *string, force bool) {
+	refName := "refs/tags/" + name
+	if strings.HasPrefix(name, "-") || refs.CheckRefFormat(refName, false) != nil {
+		fmt.Fprintf(os.Stderr, "fatal: '%s' is not a valid tag name.\n", name)
+		os.Exit(128)
+	}
+	object := resolveRevision(repo, rev)
+
+	old, err := repo.Refs.Resolve(refName)
+	exists := err == nil
+	if exists && !force {
+		fmt.Fprintf(os.Stderr, "fatal: tag '%s' already exists\n", name)
+		os.Exit(128)
+	}
+
+	if message != nil {
+		object, err = repo.CreateTag(name, object, *message)
+		if errors.Is(err, objects.ErrNoIdentity) {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: unable to write tag file: %s\n", err)
+			os.Exit(128)
+		}
+	}
+
+	expected := refs.ZeroHash
+	if exists {
+		expected = old
+	}
+	if err := repo.Refs.Update(refName, object, expected, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	if exists && old != object {
+		fmt.Printf("Updated tag '%s' (was %s)\n", name, shortHash(repo, old))
+	}
+}
+
+// deleteTags removes the named tags, reporting each one's old value
+func deleteTags(repo *objects.Repository, names []string) {
+	failed := false
+	for _, name := range names {
+		refName := "refs/tags/" + name
+		hash, err := repo.Refs.Resolve(refName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: tag '%s' not found.\n", name)
+			failed = true
+			continue
+		}
+		if err := repo.Refs.Delete(refName, hash); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Deleted tag '%s' (was %s)\n", name, shortHash(repo, hash))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// shortHash abbreviates hash to git's default seven characters or more
+func shortHash(repo *objects.Repository, hash string) string {
+	short, err := repo.ShortHash(hash, 7)
+	if err != nil {
+		return hash
+	}
+	return short
+}
+
+// mktag implements `mktag`: it reads a tag object from stdin, checks it as
+// strictly as git does and writes it
+func mktag(args []string) {
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "usage: mygit mktag\n")
+		os.Exit(129)
+	}
+	repo := openRepository()
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: could not read from stdin: %s\n", err)
+		os.Exit(128)
+	}
+	hash, err := repo.MakeTag(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: tag on stdin did not pass our strict check: %s\n", err)
+		os.Exit(128)
+	}
+	fmt.Println(hash)
+}
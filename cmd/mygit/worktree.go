@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/master-wayne7/go-git/internal/ignore"
+	"github.com/master-wayne7/go-git/internal/index"
+	"github.com/master-wayne7/go-git/internal/objects"
+	"github.com/master-wayne7/go-git/internal/wildmatch"
+)
+
+// openWorkTree opens the current repository, which must have a work tree
+func openWorkTree() *objects.Repository {
+	repo := openRepository()
+	if repo.IsBare() {
+		fmt.Fprintf(os.Stderr, "fatal: this operation must be run in a work tree\n")
+		os.Exit(128)
+	}
+	return repo
+}
+
+// openIndex reads the repository's index
+func openIndex(repo *objects.Repository) *index.Index {
+	idx, err := index.Open(repo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	return idx
+}
+
+// writeIndex saves the index
+func writeIndex(idx *index.Index) {
+	if err := idx.Write(); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+}
+
+// newIgnoreMatcher loads the ignore rules of the work tree
+func newIgnoreMatcher(repo *objects.Repository) *ignore.Matcher {
+	cfg, err := repo.Config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	m, err := ignore.NewMatcher(repo.WorkTree, repo.GitDir, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+		os.Exit(128)
+	}
+	return m
+}
+
+// readHeadIndex returns the content of HEAD's tree as index entries; an
+// unborn branch gives an empty index
+func readHeadIndex(repo *objects.Repository) *index.Index {
+	head := &index.Index{}
+	hash, err := repo.Refs.Resolve("HEAD")
+	if err != nil {
+		return head
+	}
+	tree, err := repo.PeelTo(hash, "tree")
+	if err == nil {
+		err = head.ReadTree(repo, tree)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: unable to read HEAD tree: %s\n", err)
+		os.Exit(128)
+	}
+	return head
+}
+
+// readTreeIndex returns the content of a tree as index entries; "" gives an
+// empty index
+func readTreeIndex(repo *objects.Repository, tree string) *index.Index {
+	idx := &index.Index{}
+	if tree == "" {
+		return idx
+	}
+	if err := idx.ReadTree(repo, tree); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: unable to read tree %s: %s\n", tree, err)
+		os.Exit(128)
+	}
+	return idx
+}
+
+// repoRelative converts a path given on the command line, relative to the
+// current directory, into a slash-separated path relative to the top of
+// the work tree ("" for the top itself)
+func repoRelative(repo *objects.Repository, arg string) (string, error) {
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(repo.WorkTree, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: '%s' is outside repository at '%s'", arg, arg, repo.WorkTree)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// pathspec is one path argument: a file, a directory or a wildcard pattern
+// relative to the top of the work tree
+type pathspec struct {
+	// arg is the argument as given, for messages
+	arg  string
+	path string
+	glob bool
+}
+
+// parsePathspecs resolves path arguments against the current directory
+func parsePathspecs(repo *objects.Repository, args []string) []pathspec {
+	specs := make([]pathspec, 0, len(args))
+	for _, arg := range args {
+		path, err := repoRelative(repo, arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		}
+		specs = append(specs, pathspec{arg: arg, path: path, glob: strings.ContainsAny(path, "*?[")})
+	}
+	return specs
+}
+
+// matches reports whether path is the pathspec itself, lies inside it or
+// matches it as a wildcard pattern
+func (s pathspec) matches(path string) bool {
+	if s.path == "" || path == s.path || strings.HasPrefix(path, s.path+"/") {
+		return true
+	}
+	// wildcards match across directories, as in git's default pathspecs
+	return s.glob && wildmatch.Match(s.path, path, 0)
+}
+
+// literal returns the part of the pathspec before its first wildcard
+func (s pathspec) literal() string {
+	if s.glob {
+		return s.path[:strings.IndexAny(s.path, "*?[")]
+	}
+	return s.path
+}
+
+// mayContain reports whether a directory may hold paths the pathspec
+// matches, so walks can skip the others
+func (s pathspec) mayContain(dir string) bool {
+	literal := s.literal()
+	return s.matches(dir) || strings.HasPrefix(literal, dir+"/") || (s.glob && strings.HasPrefix(dir+"/", literal))
+}
+
+// names reports whether the pathspec spells out path or a path below it,
+// rather than reaching it through a wildcard or a parent directory
+func (s pathspec) names(path string) bool {
+	literal := s.literal()
+	return literal == path || strings.HasPrefix(literal, path+"/")
+}
+
+// matchAny returns the index of the first pathspec matching path, or -1
+func matchAny(specs []pathspec, path string) int {
+	for i, s := range specs {
+		if s.matches(path) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isMissing reports whether a stat error means the path is gone, including
+// when one of its parents was replaced by a file
+func isMissing(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR)
+}
+
+// hashWorkTreeFile hashes the file at path (relative to the work tree) the
+// way it would be staged, storing the object when write is set. Symlinks
+// are stored as their target and nested repositories as their HEAD commit.
+func hashWorkTreeFile(repo *objects.Repository, path string, info os.FileInfo, write bool) (string, uint32, error) {
+	full := filepath.Join(repo.WorkTree, filepath.FromSlash(path))
+	mode := index.ModeOf(full, info)
+	switch mode {
+	case index.ModeSymlink:
+		target, err := os.Readlink(full)
+		if err != nil {
+			return "", 0, err
+		}
+		hash, err := repo.HashStream("blob", strings.NewReader(target), int64(len(target)), write)
+		return hash, mode, err
+	case index.ModeGitlink:
+		sub, err := objects.OpenRepository(full)
+		if err != nil {
+			return "", 0, err
+		}
+		hash, err := sub.Refs.Resolve("HEAD")
+		if err != nil {
+			return "", 0, fmt.Errorf("'%s' does not have a commit checked out", path)
+		}
+		return hash, mode, nil
+	case 0:
+		return "", 0, fmt.Errorf("'%s' is a directory", path)
+	}
+	hash, err := repo.HashObject(full, "blob", write)
+	return hash, mode, err
+}
+
+// stageFile returns the index entry for a work tree file, writing its blob
+// when write is set
+func stageFile(repo *objects.Repository, path string, info os.FileInfo, write bool) (*index.Entry, error) {
+	hash, mode, err := hashWorkTreeFile(repo, path, info, write)
+	if err != nil {
+		return nil, err
+	}
+	e := &index.Entry{Path: path, Hash: hash, Mode: mode}
+	e.SetStat(info)
+	return e, nil
+}
+
+// restageFile is stageFile for commands that give up when a file cannot be
+// read
+func restageFile(repo *objects.Repository, path string, info os.FileInfo, write bool) *index.Entry {
+	e, err := stageFile(repo, path, info, write)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\nfatal: adding files failed\n", err)
+		os.Exit(128)
+	}
+	return e
+}
+
+// updateTracked records in changes the new entry of every tracked file
+// matching specs whose work tree content changed, or nil for a file that is
+// gone. Unmerged and intent-to-add paths are always restaged. matched marks
+// the pathspecs that matched a tracked path.
+func updateTracked(repo *objects.Repository, idx *index.Index, specs []pathspec, matched []bool, changes map[string]*index.Entry, write bool) {
+	for i, e := range idx.Entries {
+		if i > 0 && idx.Entries[i-1].Path == e.Path {
+			continue
+		}
+		n := matchAny(specs, e.Path)
+		if n < 0 {
+			continue
+		}
+		matched[n] = true
+
+		full := filepath.Join(repo.WorkTree, filepath.FromSlash(e.Path))
+		info, err := os.Lstat(full)
+		switch {
+		case err != nil && isMissing(err), err == nil && index.ModeOf(full, info) == 0:
+			// deleted, or replaced by a directory whose files are added separately
+			changes[e.Path] = nil
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+			os.Exit(128)
+		case e.Stage != 0 || e.IntentToAdd:
+			changes[e.Path] = restageFile(repo, e.Path, info, write)
+		default:
+			modified, err := workTreeModified(repo, idx, e, info)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "fatal: %s\n", err)
+				os.Exit(128)
+			}
+			if modified {
+				changes[e.Path] = restageFile(repo, e.Path, info, write)
+			}
+		}
+	}
+}
+
+// workTreeModified reports whether a work tree file differs from its index
+// entry. Matching stat data is trusted unless the entry is racily clean;
+// otherwise the file is hashed.
+func workTreeModified(repo *objects.Repository, idx *index.Index, e *index.Entry, info os.FileInfo) (bool, error) {
+	full := filepath.Join(repo.WorkTree, filepath.FromSlash(e.Path))
+	if index.ModeOf(full, info) != e.Mode {
+		return true, nil
+	}
+	if e.Mode != index.ModeGitlink && e.StatMatches(info) && !idx.IsRacy(e) {
+		return false, nil
+	}
+	hash, _, err := hashWorkTreeFile(repo, e.Path, info, false)
+	if err != nil {
+		return false, err
+	}
+	return hash != e.Hash, nil
+}
+
+// walkWorkTree calls visit for every file and directory below dir ("" for
+// the whole work tree) in name order, skipping .git. ignored tells whether
+// the path is ignored; directories are only entered when visit returns true,
+// and nested repositories never are.
+func walkWorkTree(repo *objects.Repository, m *ignore.Matcher, dir string, visit func(path string, info os.FileInfo, ignored bool) bool) error {
+	entries, err := os.ReadDir(filepath.Join(repo.WorkTree, filepath.FromSlash(dir)))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			continue
+		}
+		path := entry.Name()
+		if dir != "" {
+			path = dir + "/" + path
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		isDir := info.IsDir()
+		ignored := m != nil && m.Ignored(path, isDir)
+		if !visit(path, info, ignored) || !isDir {
+			continue
+		}
+		full := filepath.Join(repo.WorkTree, filepath.FromSlash(path))
+		if index.ModeOf(full, info) == index.ModeGitlink {
+			continue
+		}
+		if err := walkWorkTree(repo, m, path, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,141 +6,219 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/master-wayne7/go-git/internal/config"
+	"github.com/master-wayne7/go-git/internal/index"
 	"github.com/master-wayne7/go-git/internal/objects"
 	"github.com/master-wayne7/go-git/internal/pack"
 	"github.com/master-wayne7/go-git/internal/protocol"
+	"github.com/master-wayne7/go-git/internal/refs"
 )
 
 // Clone clones a Git repository from the given URL to the specified directory
 func Clone(repoUrl, dir string) error {
-	// Create target directory
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, err)
-	}
-
-	// Change to target directory
-	if err := os.Chdir(dir); err != nil {
-		return fmt.Errorf("failed to change to directory %s: %w", dir, err)
+	// Create the target directory and initialize the repository inside it
+	repo, err := objects.InitRepository(dir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize git repo in %s: %w", dir, err)
 	}
-
-	// Initialize git repository structure
-	if err := initializeGitRepo(); err != nil {
+	defer repo.Close()
+	if err := os.MkdirAll(repo.Path("refs", "remotes", "origin"), 0755); err != nil {
 		return fmt.Errorf("failed to initialize git repo: %w", err)
 	}
 
+	return CloneInto(repo, repoUrl)
+}
+
+// CloneInto fetches the default branch of repoUrl into an existing repository.
+// Refs and the index are only written for repositories with a git
+// directory and the working tree is only checked out for non-bare
+// repositories, so an in-memory repository receives just the objects and,
+// when it has a work tree, the checked out files.
+func CloneInto(repo *objects.Repository, repoUrl string) error {
 	// Discover repository references
-	refs, capabilities, err := protocol.DiscoverRefs(repoUrl)
+	remoteRefs, capabilities, err := protocol.DiscoverRefs(repoUrl)
 	if err != nil {
 		return fmt.Errorf("failed to discover refs: %w", err)
 	}
 
 	// ### CHANGE THIS ### - Debug: Print discovered refs
-	fmt.Printf("Discovered %d refs:\n", len(refs))
-	for _, ref := range refs {
+	fmt.Printf("Discovered %d refs:\n", len(remoteRefs))
+	for _, ref := range remoteRefs {
 		fmt.Printf("  %s -> %s\n", ref.Name, ref.Hash)
 	}
 	fmt.Printf("Capabilities: %v\n", capabilities)
 
 	// Find the default branch (usually main or master)
-	defaultRef := protocol.FindDefaultRef(refs)
+	defaultRef := protocol.FindDefaultRef(remoteRefs)
 	if defaultRef == nil {
 		return fmt.Errorf("no default branch found")
 	}
 	fmt.Printf("Using default ref: %s -> %s\n", defaultRef.Name, defaultRef.Hash)
 
-	// Negotiate and receive packfile
-	packData, err := protocol.NegotiatePackfile(repoUrl, []*protocol.GitRef{defaultRef}, capabilities)
+	// Negotiate and receive a packfile with every branch and tag, so all the
+	// refs written below point at objects we have
+	packData, err := protocol.NegotiatePackfile(repoUrl, wantedRefs(remoteRefs), capabilities)
 	if err != nil {
 		return fmt.Errorf("failed to negotiate packfile: %w", err)
 	}
 
-	// Unpack the packfile
-	if err := pack.UnpackPackfile(packData); err != nil {
-		return fmt.Errorf("failed to unpack packfile: %w", err)
+	// Store the packfile, keeping it whole when the object store supports packs
+	if err := storePackfile(repo.Objects, packData); err != nil {
+		return fmt.Errorf("failed to store packfile: %w", err)
 	}
 
-	// Update references
-	if err := updateRefs(refs, defaultRef); err != nil {
-		return fmt.Errorf("failed to update refs: %w", err)
+	// Record the remote and the default branch's upstream, then update references
+	if !repo.InMemory() {
+		if err := writeRemoteConfig(repo, repoUrl, defaultRef); err != nil {
+			return fmt.Errorf("failed to write config: %w", err)
+		}
+		if err := updateRefs(repo, remoteRefs, defaultRef, "clone: from "+repoUrl); err != nil {
+			return fmt.Errorf("failed to update refs: %w", err)
+		}
 	}
 
 	// Checkout the working tree
-	if err := checkoutWorkingTree(defaultRef.Hash); err != nil {
-		return fmt.Errorf("failed to checkout working tree: %w", err)
+	if !repo.IsBare() {
+		if err := checkoutWorkingTree(repo, defaultRef.Hash); err != nil {
+			return fmt.Errorf("failed to checkout working tree: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// initializeGitRepo creates the basic git repository structure
-func initializeGitRepo() error {
-	dirs := []string{".git", ".git/objects", ".git/refs", ".git/refs/heads", ".git/refs/remotes", ".git/refs/remotes/origin"}
-	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return err
+// wantedRefs returns the remote branches and tags, one per distinct object
+func wantedRefs(remoteRefs []*protocol.GitRef) []*protocol.GitRef {
+	var wants []*protocol.GitRef
+	seen := make(map[string]bool)
+	for _, ref := range remoteRefs {
+		if (isTagRef(ref.Name) || strings.HasPrefix(ref.Name, "refs/heads/")) && !seen[ref.Hash] {
+			seen[ref.Hash] = true
+			wants = append(wants, ref)
+		}
+	}
+	return wants
+}
+
+// isTagRef reports whether name is a tag, as opposed to the peeled
+// "<tag>^{}" lines servers advertise next to annotated tags
+func isTagRef(name string) bool {
+	return strings.HasPrefix(name, "refs/tags/") && !strings.HasSuffix(name, "^{}")
+}
+
+// storePackfile writes the received pack into the object store, either as a
+// .pack/.idx pair or object by object for stores without pack support
+func storePackfile(store objects.ObjectStore, packData []byte) error {
+	if pw, ok := store.(objects.PackWriter); ok {
+		return pw.WritePack(packData)
+	}
+
+	packObjects, err := pack.ParsePackfile(packData)
+	if err != nil {
+		return err
+	}
+	for _, obj := range packObjects {
+		if _, err := store.Write(obj.TypeName(), obj.Content); err != nil {
+			return fmt.Errorf("failed to write %s object: %w", obj.TypeName(), err)
 		}
 	}
 	return nil
 }
 
-// updateRefs updates local references to match the remote
-func updateRefs(refs []*protocol.GitRef, defaultRef *protocol.GitRef) error {
-	// Write remote refs
-	for _, ref := range refs {
-		if strings.HasPrefix(ref.Name, "refs/heads/") {
-			// Create corresponding remote tracking branch
-			branchName := strings.TrimPrefix(ref.Name, "refs/heads/")
-			remotePath := filepath.Join(".git", "refs", "remotes", "origin", branchName)
+// writeRemoteConfig adds the "origin" remote and makes it the upstream of
+// the default branch
+func writeRemoteConfig(repo *objects.Repository, repoUrl string, defaultRef *protocol.GitRef) error {
+	f, err := config.OpenFile(repo.Path("config"))
+	if err != nil {
+		return err
+	}
+	branch := strings.TrimPrefix(defaultRef.Name, "refs/heads/")
+	settings := [][2]string{
+		{"remote.origin.url", repoUrl},
+		{"remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*"},
+		{"branch." + branch + ".remote", "origin"},
+		{"branch." + branch + ".merge", defaultRef.Name},
+	}
+	for _, kv := range settings {
+		if err := f.Set(kv[0], kv[1], false); err != nil {
+			return err
+		}
+	}
+	return f.Save()
+}
 
-			if err := os.MkdirAll(filepath.Dir(remotePath), 0755); err != nil {
+// updateRefs updates local references to match the remote, logging each
+// update with msg
+func updateRefs(repo *objects.Repository, remoteRefs []*protocol.GitRef, defaultRef *protocol.GitRef, msg string) error {
+	// Write remote refs
+	for _, ref := range remoteRefs {
+		if isTagRef(ref.Name) {
+			if err := repo.Refs.Update(ref.Name, ref.Hash, "", msg); err != nil {
 				return err
 			}
+			continue
+		}
+		if !strings.HasPrefix(ref.Name, "refs/heads/") {
+			continue
+		}
 
-			if err := os.WriteFile(remotePath, []byte(ref.Hash+"\n"), 0644); err != nil {
-				return err
-			}
+		// Create corresponding remote tracking branch
+		branchName := strings.TrimPrefix(ref.Name, "refs/heads/")
+		if err := repo.Refs.Update("refs/remotes/origin/"+branchName, ref.Hash, "", msg); err != nil {
+			return err
+		}
 
-			// If this is the default branch, also create the local branch
-			if ref.Hash == defaultRef.Hash {
-				localPath := filepath.Join(".git", "refs", "heads", branchName)
-				if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
-					return err
-				}
-				if err := os.WriteFile(localPath, []byte(ref.Hash+"\n"), 0644); err != nil {
-					return err
-				}
+		// If this is the default branch, also create the local branch
+		if ref.Name == defaultRef.Name {
+			if err := repo.Refs.Update(ref.Name, ref.Hash, refs.ZeroHash, msg); err != nil {
+				return err
 			}
 		}
 	}
 
-	// Set HEAD to point to the default branch
+	// Point origin/HEAD and HEAD at the default branch
 	defaultBranch := strings.TrimPrefix(defaultRef.Name, "refs/heads/")
-	headContent := fmt.Sprintf("ref: refs/heads/%s\n", defaultBranch)
-	return os.WriteFile(".git/HEAD", []byte(headContent), 0644)
+	if err := repo.Refs.SetSymbolic("refs/remotes/origin/HEAD", "refs/remotes/origin/"+defaultBranch, msg); err != nil {
+		return err
+	}
+	return repo.Refs.SetSymbolic("HEAD", defaultRef.Name, msg)
 }
 
 // checkoutWorkingTree checks out files from the commit to the working directory
-func checkoutWorkingTree(commitHash string) error {
+func checkoutWorkingTree(repo *objects.Repository, commitHash string) error {
 	// Read commit object to get tree hash
-	commitContent, err := objects.ReadObject(commitHash)
+	commit, err := repo.ReadCommit(commitHash)
 	if err != nil {
 		return fmt.Errorf("failed to read commit %s: %w", commitHash, err)
 	}
 
-	// Parse commit to find tree hash
-	lines := strings.Split(string(commitContent), "\n")
-	var treeHash string
-	for _, line := range lines {
-		if strings.HasPrefix(line, "tree ") {
-			treeHash = strings.TrimPrefix(line, "tree ")
-			break
-		}
+	// Recursively checkout the tree
+	if err := repo.CheckoutTree(commit.Tree, repo.WorkTree); err != nil {
+		return err
 	}
-
-	if treeHash == "" {
-		return fmt.Errorf("no tree found in commit")
+	if repo.InMemory() {
+		// there is no git directory to keep an index in
+		return nil
 	}
+	return writeIndex(repo, commit.Tree)
+}
 
-	// Recursively checkout the tree
-	return objects.CheckoutTree(treeHash, ".")
+// writeIndex stages the checked out tree, recording the stat data of the
+// files just written so they are known to be unmodified
+func writeIndex(repo *objects.Repository, treeHash string) error {
+	idx, err := index.Open(repo)
+	if err != nil {
+		return err
+	}
+	if err := idx.ReadTree(repo, treeHash); err != nil {
+		return err
+	}
+	for _, e := range idx.Entries {
+		info, err := os.Lstat(filepath.Join(repo.WorkTree, e.Path))
+		if err != nil {
+			continue
+		}
+		e.SetStat(info)
+	}
+	return idx.Write()
 }
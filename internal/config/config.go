@@ -0,0 +1,368 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxIncludeDepth bounds nested include.path directives, like git
+const maxIncludeDepth = 10
+
+// ErrNotFound is returned by the typed getters when a key is not set
+var ErrNotFound = errors.New("key not set")
+
+// Entry is one "key = value" line of a config file
+type Entry struct {
+	// Section and Key are lowercase; Subsection keeps its case
+	Section    string
+	Subsection string
+	Key        string
+	Value      string
+	// NoValue is set for a bare "key" line, which booleans read as true
+	NoValue bool
+	// Origin is the file the entry was read from
+	Origin string
+
+	// start and end delimit the entry's line(s) in its file
+	start, end int
+}
+
+// Name returns the entry's full "section[.subsection].key" name
+func (e *Entry) Name() string {
+	if e.Subsection != "" {
+		return e.Section + "." + e.Subsection + "." + e.Key
+	}
+	return e.Section + "." + e.Key
+}
+
+// matches reports whether the entry has the given normalized name
+func (e *Entry) matches(section, subsection, key string) bool {
+	return e.Section == section && e.Subsection == subsection && e.Key == key
+}
+
+// Config is the combined content of one or more config files, in the order
+// they were read. Later entries override earlier ones for single values.
+type Config struct {
+	Entries []Entry
+}
+
+// lookup returns the last entry for key, or nil
+func (c *Config) lookup(key string) *Entry {
+	section, subsection, name, err := splitKey(key)
+	if err != nil {
+		return nil
+	}
+	for i := len(c.Entries) - 1; i >= 0; i-- {
+		if c.Entries[i].matches(section, subsection, name) {
+			return &c.Entries[i]
+		}
+	}
+	return nil
+}
+
+// Get returns the last value set for key ("section.key" or
+// "section.subsection.key")
+func (c *Config) Get(key string) (string, bool) {
+	if e := c.lookup(key); e != nil {
+		return e.Value, true
+	}
+	return "", false
+}
+
+// GetAll returns every value of a multivalued key in file order
+func (c *Config) GetAll(key string) []string {
+	var values []string
+	for _, e := range c.Find(key) {
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+// Find returns every entry for key in file order
+func (c *Config) Find(key string) []Entry {
+	section, subsection, name, err := splitKey(key)
+	if err != nil {
+		return nil
+	}
+	return matchingEntries(c.Entries, section, subsection, name)
+}
+
+// Bool returns key as a boolean
+func (c *Config) Bool(key string) (bool, error) {
+	e := c.lookup(key)
+	if e == nil {
+		return false, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if e.NoValue {
+		return true, nil
+	}
+	b, err := ParseBool(e.Value)
+	if err != nil {
+		return false, fmt.Errorf("bad boolean config value '%s' for '%s'", e.Value, key)
+	}
+	return b, nil
+}
+
+// Int returns key as an integer, honouring k, m and g suffixes
+func (c *Config) Int(key string) (int64, error) {
+	e := c.lookup(key)
+	if e == nil {
+		return 0, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	n, err := ParseInt(e.Value)
+	if err != nil {
+		return 0, fmt.Errorf("bad numeric config value '%s' for '%s': %w", e.Value, key, err)
+	}
+	return n, nil
+}
+
+// Path returns key as a path with a leading ~ expanded
+func (c *Config) Path(key string) (string, error) {
+	e := c.lookup(key)
+	if e == nil {
+		return "", fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return ExpandPath(e.Value)
+}
+
+// ParseBool parses git's boolean spellings: true/yes/on/1 and
+// false/no/off/0/"" (case-insensitive)
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+	if n, err := ParseInt(value); err == nil {
+		return n != 0, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+// ParseInt parses an integer with an optional k, m or g (binary) suffix
+func ParseInt(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("empty value")
+	}
+	factor := int64(1)
+	switch value[len(value)-1] {
+	case 'k', 'K':
+		factor = 1 << 10
+	case 'm', 'M':
+		factor = 1 << 20
+	case 'g', 'G':
+		factor = 1 << 30
+	}
+	if factor != 1 {
+		value = value[:len(value)-1]
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid unit")
+	}
+	if n > 0 && n > (1<<63-1)/factor || n < 0 && n < -(1<<63-1)/factor {
+		return 0, fmt.Errorf("out of range")
+	}
+	return n * factor, nil
+}
+
+// ExpandPath expands a leading "~/" or "~user/" to a home directory
+func ExpandPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	name, rest, _ := strings.Cut(path[1:], "/")
+	var home string
+	if name == "" {
+		var err error
+		if home, err = os.UserHomeDir(); err != nil {
+			return "", err
+		}
+	} else {
+		u, err := lookupUser(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to expand user dir in: '%s'", path)
+		}
+		home = u
+	}
+	return filepath.Join(home, rest), nil
+}
+
+// splitKey normalizes a "section[.subsection].key" name: the section and
+// key are case-insensitive, the subsection is not
+func splitKey(key string) (section, subsection, name string, err error) {
+	first := strings.IndexByte(key, '.')
+	last := strings.LastIndexByte(key, '.')
+	if first <= 0 || last == len(key)-1 {
+		return "", "", "", fmt.Errorf("key does not contain a section: %s", key)
+	}
+	section = strings.ToLower(key[:first])
+	name = strings.ToLower(key[last+1:])
+	if first != last {
+		subsection = key[first+1 : last]
+	}
+	if !validName(section, true) || !validName(name, false) {
+		return "", "", "", fmt.Errorf("invalid key: %s", key)
+	}
+	return section, subsection, name, nil
+}
+
+// validName checks a section name (letters, digits, '-' and '.') or a key
+// name (a letter followed by letters, digits and '-')
+func validName(name string, section bool) bool {
+	if name == "" || (!section && !isAlpha(name[0])) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isAlnum(c) && c != '-' && (!section || c != '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse parses the text of a config file
+func Parse(data []byte) (*Config, error) {
+	p := &parser{data: data, line: 1}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return &Config{Entries: p.entries}, nil
+}
+
+// ReadFile parses a config file; a missing file is an empty config.
+// Include directives are not followed.
+func ReadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("bad config file %s: %w", path, err)
+	}
+	for i := range cfg.Entries {
+		cfg.Entries[i].Origin = path
+	}
+	return cfg, nil
+}
+
+// SystemPath returns the system-wide config file, or "" when
+// GIT_CONFIG_NOSYSTEM disables it
+func SystemPath() string {
+	if os.Getenv("GIT_CONFIG_NOSYSTEM") != "" {
+		return ""
+	}
+	if path := os.Getenv("GIT_CONFIG_SYSTEM"); path != "" {
+		return path
+	}
+	return "/etc/gitconfig"
+}
+
+// GlobalPaths returns the per-user config files in the order they are read:
+// $XDG_CONFIG_HOME/git/config then ~/.gitconfig, or just GIT_CONFIG_GLOBAL
+func GlobalPaths() []string {
+	if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+		return []string{path}
+	}
+	var paths []string
+	home, _ := os.UserHomeDir()
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		paths = append(paths, filepath.Join(xdg, "git", "config"))
+	} else if home != "" {
+		paths = append(paths, filepath.Join(home, ".config", "git", "config"))
+	}
+	if home != "" {
+		paths = append(paths, filepath.Join(home, ".gitconfig"))
+	}
+	return paths
+}
+
+// GlobalWritePath returns the per-user file that edits go to: ~/.gitconfig,
+// unless only the XDG file exists
+func GlobalWritePath() string {
+	paths := GlobalPaths()
+	if len(paths) == 0 {
+		return ""
+	}
+	last := paths[len(paths)-1]
+	if _, err := os.Stat(last); os.IsNotExist(err) && len(paths) > 1 {
+		if _, err := os.Stat(paths[0]); err == nil {
+			return paths[0]
+		}
+	}
+	return last
+}
+
+// Load reads the system, global and repository config files, in increasing
+// order of precedence, following include and includeIf directives. gitDir
+// may be empty to skip the repository config.
+func Load(gitDir string) (*Config, error) {
+	var paths []string
+	if system := SystemPath(); system != "" {
+		paths = append(paths, system)
+	}
+	paths = append(paths, GlobalPaths()...)
+	if gitDir != "" {
+		paths = append(paths, filepath.Join(gitDir, "config"))
+	}
+	return LoadFiles(gitDir, paths...)
+}
+
+// LoadFiles reads the given config files in order, following include and
+// includeIf directives; gitDir is used to evaluate includeIf conditions
+func LoadFiles(gitDir string, paths ...string) (*Config, error) {
+	combined := &Config{}
+	for _, path := range paths {
+		if err := readWithIncludes(combined, path, gitDir, 0); err != nil {
+			return nil, err
+		}
+	}
+	return combined, nil
+}
+
+// readWithIncludes appends the entries of path to cfg, splicing in the
+// content of included files right after the directive that includes them
+func readWithIncludes(cfg *Config, path, gitDir string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("exceeded maximum include depth (%d) while including %s", maxIncludeDepth, path)
+	}
+	file, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range file.Entries {
+		cfg.Entries = append(cfg.Entries, e)
+		if e.Key != "path" || e.NoValue {
+			continue
+		}
+		switch {
+		case e.Section == "include" && e.Subsection == "":
+		case e.Section == "includeif" && includeConditionHolds(e.Subsection, path, gitDir):
+		default:
+			continue
+		}
+
+		target, err := ExpandPath(e.Value)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		if err := readWithIncludes(cfg, target, gitDir, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
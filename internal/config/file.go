@@ -0,0 +1,353 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrMultipleValues is returned when a single-value edit matches a
+	// multivalued key
+	ErrMultipleValues = errors.New("key has multiple values")
+	// ErrLocked is returned when another writer holds the config lock
+	ErrLocked = errors.New("could not lock config file")
+)
+
+// File is a single config file held verbatim, so that edits only touch the
+// lines they change and keep comments, blank lines and formatting intact
+type File struct {
+	Path string
+	data []byte
+}
+
+// OpenFile reads a config file for editing; a missing file starts empty
+func OpenFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &File{Path: path, data: data}, nil
+}
+
+// parse parses the current content, returning its entries and sections
+func (f *File) parse() (*parser, error) {
+	p := &parser{data: f.data, line: 1}
+	if err := p.parse(); err != nil {
+		return nil, fmt.Errorf("bad config file %s: %w", f.Path, err)
+	}
+	return p, nil
+}
+
+// Config returns the parsed entries of the file
+func (f *File) Config() (*Config, error) {
+	p, err := f.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Config{Entries: p.entries}, nil
+}
+
+// Set gives key a single value, replacing its existing line in place or
+// adding it at the end of its section (creating the section if needed).
+// With replaceAll every existing value is replaced by the new one;
+// otherwise a multivalued key is an error.
+func (f *File) Set(key, value string, replaceAll bool) error {
+	section, subsection, name, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	p, err := f.parse()
+	if err != nil {
+		return err
+	}
+	matches := matchingEntries(p.entries, section, subsection, name)
+	if len(matches) > 1 && !replaceAll {
+		return fmt.Errorf("%s: %w", key, ErrMultipleValues)
+	}
+	if len(matches) == 0 {
+		return f.Add(key, value)
+	}
+
+	line := formatEntry(keyName(key), value)
+	// replace the last occurrence and drop the others, working backwards so
+	// earlier offsets stay valid
+	last := matches[len(matches)-1]
+	f.splice(last.start, last.end, f.ownLine(last, line))
+	for i := len(matches) - 2; i >= 0; i-- {
+		f.splice(matches[i].start, matches[i].end, f.ownLine(matches[i], ""))
+	}
+	return nil
+}
+
+// ownLine returns the text that replaces entry e with line. Like git, an
+// entry that shares its line with the section header is moved off it.
+func (f *File) ownLine(e Entry, line string) string {
+	if e.start > 0 && f.data[e.start-1] != '\n' {
+		return "\n" + line
+	}
+	return line
+}
+
+// Add appends another value for key at the end of its last section
+func (f *File) Add(key, value string) error {
+	section, subsection, _, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	p, err := f.parse()
+	if err != nil {
+		return err
+	}
+	line := formatEntry(keyName(key), value)
+
+	for i := len(p.sections) - 1; i >= 0; i-- {
+		s := p.sections[i]
+		if s.section == section && s.subsection == subsection {
+			pos := s.end
+			if pos > 0 && f.data[pos-1] != '\n' {
+				// the header or last entry ends without a newline
+				if nl := strings.IndexByte(string(f.data[pos:]), '\n'); nl >= 0 {
+					pos += nl + 1
+				} else {
+					line = "\n" + line
+				}
+			}
+			f.splice(pos, pos, line)
+			return nil
+		}
+	}
+
+	// no such section yet: append it to the file
+	var buf strings.Builder
+	if len(f.data) > 0 && f.data[len(f.data)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(formatSection(key))
+	buf.WriteString(line)
+	f.splice(len(f.data), len(f.data), buf.String())
+	return nil
+}
+
+// Unset removes key. Unless all is set, a multivalued key is an error.
+// It reports whether anything was removed. Like git, a section left empty
+// is removed too, unless comments surround it.
+func (f *File) Unset(key string, all bool) (bool, error) {
+	section, subsection, name, err := splitKey(key)
+	if err != nil {
+		return false, err
+	}
+	p, err := f.parse()
+	if err != nil {
+		return false, err
+	}
+	matches := matchingEntries(p.entries, section, subsection, name)
+	if len(matches) > 1 && !all {
+		return false, fmt.Errorf("%s: %w", key, ErrMultipleValues)
+	}
+
+	type span struct {
+		start, end int
+		text       string
+	}
+	var spans []span
+	tokens := p.tokens(section, subsection, matches)
+	for i, t := range tokens {
+		if !t.removed || len(spans) > 0 && t.start < spans[len(spans)-1].end {
+			continue
+		}
+		if start, end, ok := f.emptiedSection(tokens, i); ok {
+			spans = append(spans, span{start, end, ""})
+		} else {
+			spans = append(spans, span{t.start, t.end, f.ownLine(Entry{start: t.start}, "")})
+		}
+	}
+	for i := len(spans) - 1; i >= 0; i-- {
+		f.splice(spans[i].start, spans[i].end, spans[i].text)
+	}
+	return len(matches) > 0, nil
+}
+
+// token is a section header, an entry or a comment of a parsed file
+type token struct {
+	kind       tokenKind
+	start, end int
+	// ours marks the headers of the section being edited, removed the
+	// entries being removed
+	ours, removed bool
+}
+
+type tokenKind int
+
+const (
+	tokenHeader tokenKind = iota
+	tokenEntry
+	tokenComment
+)
+
+// tokens lists the headers, entries and comments of the parsed file in
+// order, marking the headers of section.subsection and the entries in
+// removed
+func (p *parser) tokens(section, subsection string, removed []Entry) []token {
+	gone := make(map[int]bool, len(removed))
+	for _, e := range removed {
+		gone[e.start] = true
+	}
+	var tokens []token
+	for _, s := range p.sections {
+		ours := s.section == section && s.subsection == subsection
+		tokens = append(tokens, token{kind: tokenHeader, start: s.start, end: s.headerEnd, ours: ours})
+	}
+	for _, e := range p.entries {
+		tokens = append(tokens, token{kind: tokenEntry, start: e.start, end: e.end, removed: gone[e.start]})
+	}
+	for _, c := range p.comments {
+		tokens = append(tokens, token{kind: tokenComment, start: c, end: c})
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].start < tokens[j].start })
+	return tokens
+}
+
+// emptiedSection returns the span of the section around the removed entry
+// tokens[at] when removing it and the removed entries after it leaves the
+// section empty. As in git, the span runs from the end of whatever precedes
+// the header to the start of the next section, and no comment may come
+// before the entry or after it within the section.
+func (f *File) emptiedSection(tokens []token, at int) (start, end int, ok bool) {
+	headerSeen := false
+	i := at
+	for ; i > 0; i-- {
+		t := tokens[i-1]
+		if t.kind == tokenComment || t.kind == tokenEntry && !headerSeen {
+			return 0, 0, false
+		}
+		if t.kind == tokenEntry || !t.ours {
+			break
+		}
+		headerSeen = true
+	}
+	if i > 0 {
+		// keep the line the preceding header or entry ends on
+		start = tokens[i-1].end
+		if nl := bytes.IndexByte(f.data[start:tokens[i].start], '\n'); f.data[start-1] != '\n' && nl >= 0 {
+			start += nl + 1
+		}
+	}
+
+	j := at + 1
+	for ; j < len(tokens); j++ {
+		t := tokens[j]
+		if t.kind == tokenHeader && !t.ours {
+			break
+		}
+		if t.kind == tokenComment || t.kind == tokenEntry && !t.removed {
+			return 0, 0, false
+		}
+	}
+	end = len(f.data)
+	if j < len(tokens) {
+		end = tokens[j].start
+	}
+	return start, end, true
+}
+
+// Save writes the file through "<path>.lock" and renames it into place
+func (f *File) Save() error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+	lockPath := f.Path + ".lock"
+	lock, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s: %w", f.Path, ErrLocked)
+	} else if err != nil {
+		return err
+	}
+	if _, err := lock.Write(f.data); err != nil {
+		lock.Close()
+		os.Remove(lockPath)
+		return err
+	}
+	if err := lock.Close(); err != nil {
+		os.Remove(lockPath)
+		return err
+	}
+	if err := os.Rename(lockPath, f.Path); err != nil {
+		os.Remove(lockPath)
+		return err
+	}
+	return nil
+}
+
+// splice replaces data[start:end] with text
+func (f *File) splice(start, end int, text string) {
+	data := make([]byte, 0, len(f.data)-(end-start)+len(text))
+	data = append(data, f.data[:start]...)
+	data = append(data, text...)
+	data = append(data, f.data[end:]...)
+	f.data = data
+}
+
+// matchingEntries returns the entries with the given normalized name
+func matchingEntries(entries []Entry, section, subsection, key string) []Entry {
+	var matches []Entry
+	for _, e := range entries {
+		if e.matches(section, subsection, key) {
+			matches = append(matches, e)
+		}
+	}
+	return matches
+}
+
+// keyName returns the last component of key as the user spelled it
+func keyName(key string) string {
+	return key[strings.LastIndexByte(key, '.')+1:]
+}
+
+// formatSection returns the header line for the section of key
+func formatSection(key string) string {
+	first := strings.IndexByte(key, '.')
+	last := strings.LastIndexByte(key, '.')
+	section := strings.ToLower(key[:first])
+	if first == last {
+		return "[" + section + "]\n"
+	}
+	sub := key[first+1 : last]
+	sub = strings.ReplaceAll(sub, `\`, `\\`)
+	sub = strings.ReplaceAll(sub, `"`, `\"`)
+	return fmt.Sprintf("[%s \"%s\"]\n", section, sub)
+}
+
+// formatEntry returns an indented "key = value" line, quoting and escaping
+// the value where git's syntax requires it
+func formatEntry(key, value string) string {
+	var buf strings.Builder
+	needsQuotes := value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;")
+	if needsQuotes {
+		buf.WriteByte('"')
+	}
+	for _, c := range value {
+		switch c {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '"':
+			buf.WriteString(`\"`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\b':
+			buf.WriteString(`\b`)
+		default:
+			buf.WriteRune(c)
+		}
+	}
+	if needsQuotes {
+		buf.WriteByte('"')
+	}
+	return "\t" + key + " = " + buf.String() + "\n"
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileEdits edits config files in place and compares the result with
+// what `git config --file` produced from the same input
+func TestFileEdits(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		op    string
+		key   string
+		value string
+		want  string
+	}{
+		{"replace keeps comments", "# top\n[core]\n\tbare = false ; note\n\tfilemode = true\n", "set", "core.bare", "true", "# top\n[core]\n\tbare = true\n\tfilemode = true\n"},
+		{"key spelled differently", "[core]\n\tbare = false\n", "set", "CORE.Bare", "true", "[core]\n\tBare = true\n"},
+		{"add to earlier section", "[core]\n\tbare = false\n[remote \"origin\"]\n\turl = x\n", "set", "core.editor", "vim", "[core]\n\tbare = false\n\teditor = vim\n[remote \"origin\"]\n\turl = x\n"},
+		{"new subsection without final newline", "[core]\n\tx = 1", "set", "branch.main.remote", "origin", "[core]\n\tx = 1\n[branch \"main\"]\n\tremote = origin\n"},
+		{"new section in empty file", "", "set", "user.name", "A U Thor", "[user]\n\tname = A U Thor\n"},
+		{"quote padded value", "[user]\n", "set", "user.name", " padded ", "[user]\n\tname = \" padded \"\n"},
+		{"quote comment chars", "[user]\n", "set", "user.name", "a#b;c", "[user]\n\tname = \"a#b;c\"\n"},
+		{"escape specials", "[a]\n", "set", "a.b", "q\"\\\tx\ny", "[a]\n\tb = q\\\"\\\\\\tx\\ny\n"},
+		{"header and entry on one line", "[core] bare = false\n", "set", "core.bare", "true", "[core]\n\tbare = true\n"},
+		{"replace continued value", "[core]\n\tx = a \\\n b\n\ty = 1\n", "set", "core.x", "z", "[core]\n\tx = z\n\ty = 1\n"},
+		{"add to last of repeated sections", "[a]\n\tx = 1\n[b]\n\ty = 2\n[a]\n\tz = 3\n", "set", "a.w", "4", "[a]\n\tx = 1\n[b]\n\ty = 2\n[a]\n\tz = 3\n\tw = 4\n"},
+		{"replace all values", "[remote \"o\"]\n\tfetch = a\n\turl = u\n\tfetch = b\n", "replaceall", "remote.o.fetch", "c", "[remote \"o\"]\n\turl = u\n\tfetch = c\n"},
+		{"add another value", "[remote \"o\"]\n\tfetch = a\n\turl = u\n", "add", "remote.o.fetch", "b", "[remote \"o\"]\n\tfetch = a\n\turl = u\n\tfetch = b\n"},
+		{"unset one value", "[core]\n\tbare = false\n\tx = 1\n", "unset", "core.bare", "", "[core]\n\tx = 1\n"},
+		{"unset all values", "[remote \"o\"]\n\tfetch = a\n\turl = u\n\tfetch = b\n", "unsetall", "remote.o.fetch", "", "[remote \"o\"]\n\turl = u\n"},
+		{"unset in subsection only", "[a]\n\tk = 1\n[a \"s\"]\n\tk = 2\n", "unset", "a.s.k", "", "[a]\n\tk = 1\n"},
+		{"unset keeps section with comment", "[a]\n# c\n\tk = 1\n", "unset", "a.k", "", "[a]\n# c\n"},
+		{"unset keeps section with trailing comment", "[a]\n\tk = 1\n# c\n[b]\n\tx = 1\n", "unset", "a.k", "", "[a]\n# c\n[b]\n\tx = 1\n"},
+		{"unset drops inline comment with section", "[a]\n\tk = 1 # c\n[b]\n", "unset", "a.k", "", "[b]\n"},
+		{"unset drops blank lines with section", "[b]\n\tx = 1\n\n[a]\n\tk = 1\n\n\n[c]\n", "unset", "a.k", "", "[b]\n\tx = 1\n[c]\n"},
+		{"unset all over repeated sections", "[a]\n\tk = 1\n[b]\n\tx = 1\n[a]\n\tk = 2\n", "unsetall", "a.k", "", "[b]\n\tx = 1\n"},
+		{"unset keeps other keys", "[a]\n\tk = 1\n[a]\n\tj = 1\n", "unset", "a.k", "", "[a]\n[a]\n\tj = 1\n"},
+		{"unset entry on header line", "[core] bare = false\n\tx = 1\n", "unset", "core.bare", "", "[core]\n\tx = 1\n"},
+		{"replace all from header line", "[a] k = 1\n\tk = 3\n", "replaceall", "a.k", "2", "[a]\n\tk = 2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch tt.op {
+			case "set":
+				err = f.Set(tt.key, tt.value, false)
+			case "replaceall":
+				err = f.Set(tt.key, tt.value, true)
+			case "add":
+				err = f.Add(tt.key, tt.value)
+			case "unset":
+				_, err = f.Unset(tt.key, false)
+			case "unsetall":
+				_, err = f.Unset(tt.key, true)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Save(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileEditErrors(t *testing.T) {
+	f := &File{Path: "config", data: []byte("[remote \"o\"]\n\tfetch = a\n\tfetch = b\n")}
+	if err := f.Set("remote.o.fetch", "c", false); !errors.Is(err, ErrMultipleValues) {
+		t.Errorf("Set: err = %v, want ErrMultipleValues", err)
+	}
+	if _, err := f.Unset("remote.o.fetch", false); !errors.Is(err, ErrMultipleValues) {
+		t.Errorf("Unset: err = %v, want ErrMultipleValues", err)
+	}
+	if removed, err := f.Unset("remote.o.url", false); removed || err != nil {
+		t.Errorf("Unset of a missing key = %v, %v", removed, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path+".lock", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f.Path = path
+	if err := f.Save(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Save: err = %v, want ErrLocked", err)
+	}
+}
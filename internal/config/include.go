@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/wildmatch"
+)
+
+// includeConditionHolds evaluates the condition of an [includeIf "<cond>"]
+// section found in file: "gitdir:", "gitdir/i:" or "onbranch:" patterns
+func includeConditionHolds(cond, file, gitDir string) bool {
+	if gitDir == "" {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(cond, "gitdir:"):
+		return gitDirMatches(strings.TrimPrefix(cond, "gitdir:"), file, gitDir, false)
+	case strings.HasPrefix(cond, "gitdir/i:"):
+		return gitDirMatches(strings.TrimPrefix(cond, "gitdir/i:"), file, gitDir, true)
+	case strings.HasPrefix(cond, "onbranch:"):
+		head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+		if err != nil {
+			return false
+		}
+		branch, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+		if !ok {
+			return false
+		}
+		pattern := strings.TrimPrefix(cond, "onbranch:")
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+		return globMatch(pattern, branch, false)
+	}
+	return false
+}
+
+// gitDirMatches matches the repository's git directory against a gitdir:
+// pattern. Relative patterns match at any depth, "./" is relative to the
+// including file and a trailing slash matches everything below.
+func gitDirMatches(pattern, file, gitDir string, foldCase bool) bool {
+	pattern, err := ExpandPath(pattern)
+	if err != nil {
+		return false
+	}
+	if rest, ok := strings.CutPrefix(pattern, "./"); ok {
+		pattern = filepath.Join(filepath.Dir(file), rest)
+		if strings.HasSuffix(rest, "/") || rest == "" {
+			pattern += "/"
+		}
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = "**/" + pattern
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+
+	candidates := []string{filepath.ToSlash(gitDir)}
+	if real, err := filepath.EvalSymlinks(gitDir); err == nil && real != gitDir {
+		candidates = append(candidates, filepath.ToSlash(real))
+	}
+	for _, dir := range candidates {
+		if globMatch(filepath.ToSlash(pattern), dir, foldCase) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches name against a wildcard pattern the way git matches
+// includeIf conditions: "*" and "?" stop at slashes and "**" crosses them
+func globMatch(pattern, name string, foldCase bool) bool {
+	flags := wildmatch.Pathname
+	if foldCase {
+		flags |= wildmatch.CaseFold
+	}
+	return wildmatch.Match(pattern, name, flags)
+}
+
+// lookupUser returns the home directory of the named user
+func lookupUser(name string) (string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return u.HomeDir, nil
+}
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parser reads the git config syntax: [section] and [section "subsection"]
+// headers, "key = value" lines, # and ; comments, quoted values with
+// backslash escapes and backslash-newline continuations
+type parser struct {
+	data     []byte
+	pos      int
+	line     int
+	entries  []Entry
+	sections []sectionSpan
+	// comments holds the offset of every comment outside an entry
+	comments []int
+
+	section    string
+	subsection string
+}
+
+// sectionSpan records where the header of one occurrence of a section
+// starts and ends, and where the section ends, i.e. after its header or its
+// last entry, which is where new keys are inserted
+type sectionSpan struct {
+	section    string
+	subsection string
+	start      int
+	headerEnd  int
+	end        int
+}
+
+// lineStart returns the offset of the start of the line containing pos
+func (p *parser) lineStart(pos int) int {
+	for pos > 0 && p.data[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+// errorf reports a syntax error at the current line
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// peek returns the next byte, or 0 at the end of the input
+func (p *parser) peek() byte {
+	if p.pos >= len(p.data) {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+// next consumes and returns the next byte, or 0 at the end of the input
+func (p *parser) next() byte {
+	if p.pos >= len(p.data) {
+		return 0
+	}
+	c := p.data[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// skipLine consumes everything up to and including the next newline
+func (p *parser) skipLine() {
+	for p.pos < len(p.data) {
+		if p.next() == '\n' {
+			return
+		}
+	}
+}
+
+// parse reads the whole input into p.entries
+func (p *parser) parse() error {
+	// a UTF-8 byte order mark is allowed at the start of the file
+	if strings.HasPrefix(string(p.data), "\xef\xbb\xbf") {
+		p.pos = 3
+	}
+
+	for p.pos < len(p.data) {
+		c := p.peek()
+		switch {
+		case c == '\n' || c == ' ' || c == '\t' || c == '\r':
+			p.next()
+		case c == '#' || c == ';':
+			p.comments = append(p.comments, p.pos)
+			p.skipLine()
+		case c == '[':
+			start := p.pos
+			p.next()
+			if err := p.parseSectionHeader(start); err != nil {
+				return err
+			}
+		case isAlpha(c):
+			if p.section == "" {
+				return p.errorf("key outside of a section")
+			}
+			if err := p.parseEntry(); err != nil {
+				return err
+			}
+		default:
+			return p.errorf("unexpected character %q", c)
+		}
+	}
+	return nil
+}
+
+// parseSectionHeader parses the rest of "[section]", "[section "sub"]" or
+// the deprecated "[section.sub]", whose "[" is at start
+func (p *parser) parseSectionHeader(start int) error {
+	var name strings.Builder
+	for {
+		c := p.next()
+		switch {
+		case c == ']':
+			section, sub, dotted := strings.Cut(name.String(), ".")
+			if section == "" {
+				return p.errorf("empty section name")
+			}
+			p.section = strings.ToLower(section)
+			p.subsection = ""
+			if dotted {
+				// the old [section.subsection] syntax is case-insensitive
+				p.subsection = strings.ToLower(sub)
+			}
+			p.startSection(start)
+			return nil
+		case c == ' ' || c == '\t':
+			if name.Len() == 0 {
+				return p.errorf("empty section name")
+			}
+			return p.parseSubsection(name.String(), start)
+		case isAlnum(c) || c == '-' || c == '.':
+			name.WriteByte(c)
+		default:
+			return p.errorf("invalid section header")
+		}
+	}
+}
+
+// parseSubsection parses ` "subsection"]` after a section name
+func (p *parser) parseSubsection(section string, start int) error {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.next()
+	}
+	if p.next() != '"' {
+		return p.errorf("invalid section header")
+	}
+
+	var sub strings.Builder
+	for {
+		c := p.next()
+		switch c {
+		case 0, '\n':
+			return p.errorf("unterminated subsection name")
+		case '\\':
+			c = p.next()
+			if c == 0 || c == '\n' {
+				return p.errorf("unterminated subsection name")
+			}
+			sub.WriteByte(c)
+		case '"':
+			if p.next() != ']' {
+				return p.errorf("invalid section header")
+			}
+			p.section = strings.ToLower(section)
+			p.subsection = sub.String()
+			p.startSection(start)
+			return nil
+		default:
+			sub.WriteByte(c)
+		}
+	}
+}
+
+// startSection records a section header, starting at start, that has just
+// been parsed
+func (p *parser) startSection(start int) {
+	p.sections = append(p.sections, sectionSpan{section: p.section, subsection: p.subsection, start: start, headerEnd: p.pos, end: p.pos})
+}
+
+// parseEntry parses "key", "key = value" or "key = value # comment"
+func (p *parser) parseEntry() error {
+	start := p.lineStart(p.pos)
+	if header := p.sections[len(p.sections)-1].end; header > start {
+		// the key shares its line with the section header
+		start = header
+	}
+	var key strings.Builder
+	for isAlnum(p.peek()) || p.peek() == '-' {
+		key.WriteByte(p.next())
+	}
+	entry := Entry{
+		Section:    p.section,
+		Subsection: p.subsection,
+		Key:        strings.ToLower(key.String()),
+	}
+
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.next()
+	}
+	switch c := p.peek(); c {
+	case 0, '\n', '\r', '#', ';':
+		entry.NoValue = true
+		p.skipLine()
+	case '=':
+		p.next()
+		value, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		entry.Value = value
+	default:
+		return p.errorf("invalid key %q", key.String()+string(c))
+	}
+
+	entry.start, entry.end = start, p.pos
+	p.entries = append(p.entries, entry)
+	p.sections[len(p.sections)-1].end = p.pos
+	return nil
+}
+
+// parseValue parses a value up to the end of its (possibly continued) line.
+// Outside quotes, leading and trailing whitespace is dropped and inner
+// whitespace is kept as spaces.
+func (p *parser) parseValue() (string, error) {
+	var value strings.Builder
+	inQuotes := false
+	// pending holds whitespace that is kept only if more value follows
+	pending := ""
+	started := false
+
+	for {
+		c := p.next()
+		switch {
+		case c == 0 || c == '\n':
+			if inQuotes {
+				return "", p.errorf("unterminated quoted value")
+			}
+			return value.String(), nil
+		case c == '\r' && p.peek() == '\n' && !inQuotes:
+			continue
+		case !inQuotes && (c == '#' || c == ';'):
+			p.skipLine()
+			return value.String(), nil
+		case !inQuotes && (c == ' ' || c == '\t'):
+			if started {
+				pending += " "
+			}
+			continue
+		case c == '\\':
+			e := p.next()
+			switch e {
+			case '\n':
+				continue
+			case '\r':
+				if p.peek() == '\n' {
+					p.next()
+					continue
+				}
+				return "", p.errorf("invalid escape sequence")
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case '\\', '"':
+				c = e
+			default:
+				return "", p.errorf("invalid escape sequence \\%c", e)
+			}
+		case c == '"':
+			value.WriteString(pending)
+			pending = ""
+			started = true
+			inQuotes = !inQuotes
+			continue
+		}
+		value.WriteString(pending)
+		pending = ""
+		started = true
+		value.WriteByte(c)
+	}
+}
+
+// isAlpha reports whether c is an ASCII letter
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isAlnum reports whether c is an ASCII letter or digit
+func isAlnum(c byte) bool {
+	return isAlpha(c) || (c >= '0' && c <= '9')
+}
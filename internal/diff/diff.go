@@ -0,0 +1,94 @@
+// Package diff compares file contents line by line and pairs renamed
+// files, as needed for status and the change summaries printed after a
+// commit.
+package diff
+
+import "bytes"
+
+// binaryCheckSize is how much of a file git looks at to decide whether it
+// is binary
+const binaryCheckSize = 8000
+
+// IsBinary reports whether content looks binary to git: a NUL byte within
+// its first 8000 bytes
+func IsBinary(content []byte) bool {
+	if len(content) > binaryCheckSize {
+		content = content[:binaryCheckSize]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// lines splits content into lines, each keeping its newline; the last line
+// may lack one
+func lines(content []byte) []string {
+	var result []string
+	for len(content) > 0 {
+		n := bytes.IndexByte(content, '\n') + 1
+		if n == 0 {
+			n = len(content)
+		}
+		result = append(result, string(content[:n]))
+		content = content[n:]
+	}
+	return result
+}
+
+// CountChanges returns the number of lines a shortest edit script adds and
+// removes to turn old into new. A last line without a newline differs from
+// the same line with one. When finding that script costs too much, the
+// lines between the common head and tail count as fully rewritten.
+func CountChanges(old, new []byte) (insertions, deletions int) {
+	a, b := lines(old), lines(new)
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	d := editDistance(a, b)
+	// d = insertions + deletions and len(b) - len(a) = insertions - deletions
+	insertions = (d + len(b) - len(a)) / 2
+	return insertions, d - insertions
+}
+
+// maxEditCost bounds the steps editDistance takes, like the cost limit of
+// git's xdiff, so that large and very different files stay cheap
+const maxEditCost = 1 << 24
+
+// editDistance is the length of the shortest edit script between a and b,
+// counting inserted and deleted lines, found with Myers' O(ND) algorithm.
+// Past maxEditCost steps it gives up and returns len(a) + len(b).
+func editDistance(a, b []string) int {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return 0
+	}
+	cost := 0
+	// v[offset+k] is the furthest x reached on diagonal k = x - y
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y, start := x-k, x
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			if cost += x - start + 1; cost > maxEditCost {
+				return max
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return d
+			}
+		}
+	}
+	return max
+}
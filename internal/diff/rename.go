@@ -0,0 +1,51 @@
+package diff
+
+import "path"
+
+// File is one side of a changed path, as seen by rename detection
+type File struct {
+	Path string
+	Mode uint32
+	Hash string
+}
+
+// ExactRenames pairs added files with deleted files of the same content,
+// the way git finds exact renames. Each added file, in the order given,
+// takes the first unused deleted file with its hash, preferring one with
+// the same base name. Anything but regular files also needs the same mode.
+// The result maps the index of each renamed file in added to the index of
+// its source in deleted.
+func ExactRenames(deleted, added []File) map[int]int {
+	renames := make(map[int]int)
+	used := make([]bool, len(deleted))
+	for i, a := range added {
+		best := -1
+		for j, d := range deleted {
+			if used[j] || d.Hash != a.Hash || !sameKind(d.Mode, a.Mode) {
+				continue
+			}
+			if path.Base(d.Path) == path.Base(a.Path) {
+				best = j
+				break
+			}
+			if best < 0 {
+				best = j
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			renames[i] = best
+		}
+	}
+	return renames
+}
+
+// sameKind reports whether a file of mode a may be renamed to one of mode
+// b: two regular files, whatever their permissions, or equal modes
+func sameKind(a, b uint32) bool {
+	const regular = 0100000
+	if a&0170000 == regular && b&0170000 == regular {
+		return true
+	}
+	return a == b
+}
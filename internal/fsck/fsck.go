@@ -0,0 +1,332 @@
+package fsck
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/master-wayne7/go-git/internal/index"
+	"github.com/master-wayne7/go-git/internal/objects"
+	"github.com/master-wayne7/go-git/internal/refs"
+)
+
+// Kinds of issues reported by Check
+const (
+	KindCorrupt     = "corrupt"
+	KindMissing     = "missing"
+	KindBrokenLink  = "broken link"
+	KindBadRef      = "bad ref"
+	KindDangling    = "dangling"
+	KindUnreachable = "unreachable"
+)
+
+// Options controls what Check reports
+type Options struct {
+	// Unreachable reports every unreachable object, not only dangling ones
+	Unreachable bool
+	// NoDangling suppresses dangling object reports
+	NoDangling bool
+}
+
+// Issue is a single problem found in the repository
+type Issue struct {
+	Kind   string
+	Type   string
+	Hash   string
+	Detail string
+}
+
+// String formats the issue the way git fsck prints it
+func (i Issue) String() string {
+	switch i.Kind {
+	case KindCorrupt:
+		return fmt.Sprintf("error: %s %s: %s", i.Type, i.Hash, i.Detail)
+	case KindBrokenLink:
+		return fmt.Sprintf("broken link from %s\n              to %s %s", i.Detail, i.Type, i.Hash)
+	case KindBadRef:
+		return fmt.Sprintf("error: %s: %s", i.Detail, i.Hash)
+	}
+	return fmt.Sprintf("%s %s %s", i.Kind, i.Type, i.Hash)
+}
+
+// IsError reports whether the issue means the repository is damaged.
+// Dangling and unreachable objects are informational only.
+func (i Issue) IsError() bool {
+	return i.Kind != KindDangling && i.Kind != KindUnreachable
+}
+
+// Report is the result of a repository check
+type Report struct {
+	Issues []Issue
+	// Checked is the number of objects that were rehashed and validated
+	Checked int
+}
+
+// HasErrors reports whether any issue indicates missing or corrupt data
+func (r *Report) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.IsError() {
+			return true
+		}
+	}
+	return false
+}
+
+// link is a reference from one object to another
+type link struct {
+	hash    string
+	objType string
+}
+
+// Check rehashes and validates every loose and packed object, then walks
+// reachability from all refs and from the index to find missing, dangling
+// and unreachable objects
+func Check(repo *objects.Repository, opts Options) (*Report, error) {
+	report := &Report{}
+	types := make(map[string]string)
+	links := make(map[string][]link)
+	referenced := make(map[string]bool)
+	// corrupt objects are present, so links to them are not broken, but
+	// they are reported once and never as dangling or unreachable
+	corrupt := make(map[string]bool)
+
+	err := repo.Objects.Iterate(func(hash string) error {
+		report.Checked++
+		objType, content, err := repo.Objects.Read(hash)
+		types[hash] = objType
+		if err != nil {
+			corrupt[hash] = true
+			report.Issues = append(report.Issues, Issue{Kind: KindCorrupt, Type: "object", Hash: hash, Detail: err.Error()})
+			return nil
+		}
+		// An object whose content does not match its name is corrupt
+		if actual := objects.ComputeHash(objType, content); actual != hash {
+			corrupt[hash] = true
+			report.Issues = append(report.Issues, Issue{Kind: KindCorrupt, Type: objType, Hash: hash, Detail: "hash mismatch, content hashes to " + actual})
+			return nil
+		}
+
+		if err := objects.ValidateObject(objType, content); err != nil {
+			report.Issues = append(report.Issues, Issue{Kind: KindCorrupt, Type: objType, Hash: hash, Detail: err.Error()})
+			return nil
+		}
+
+		objLinks, err := parseLinks(objType, content)
+		if err != nil {
+			report.Issues = append(report.Issues, Issue{Kind: KindCorrupt, Type: objType, Hash: hash, Detail: err.Error()})
+			return nil
+		}
+		links[hash] = objLinks
+		for _, l := range objLinks {
+			referenced[l.hash] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	roots, badRefs, err := refRoots(repo)
+	if err != nil {
+		return nil, err
+	}
+	report.Issues = append(report.Issues, badRefs...)
+
+	// Walk everything reachable from the refs
+	reachable := make(map[string]bool)
+	var stack []string
+	for _, name := range sortedKeys(roots) {
+		hash := roots[name]
+		if _, ok := types[hash]; !ok {
+			report.Issues = append(report.Issues, Issue{Kind: KindBadRef, Hash: hash, Detail: fmt.Sprintf("%s: invalid sha1 pointer", name)})
+			continue
+		}
+		stack = append(stack, hash)
+	}
+	missing := make(map[string]bool)
+
+	// Like git, staged blobs and the trees cached in the index are in use
+	staged, cached, err := indexRoots(repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range staged {
+		if _, ok := types[hash]; ok {
+			stack = append(stack, hash)
+		} else if !missing[hash] {
+			missing[hash] = true
+			report.Issues = append(report.Issues, Issue{Kind: KindMissing, Type: "blob", Hash: hash})
+		}
+	}
+	for _, hash := range cached {
+		if _, ok := types[hash]; ok {
+			stack = append(stack, hash)
+		} else {
+			report.Issues = append(report.Issues, Issue{Kind: KindBadRef, Hash: hash, Detail: "invalid sha1 pointer in cache-tree"})
+		}
+	}
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if reachable[hash] {
+			continue
+		}
+		reachable[hash] = true
+
+		for _, l := range links[hash] {
+			if _, ok := types[l.hash]; !ok {
+				report.Issues = append(report.Issues, Issue{Kind: KindBrokenLink, Type: l.objType, Hash: l.hash, Detail: types[hash] + " " + hash})
+				if !missing[l.hash] {
+					missing[l.hash] = true
+					report.Issues = append(report.Issues, Issue{Kind: KindMissing, Type: l.objType, Hash: l.hash})
+				}
+				continue
+			}
+			stack = append(stack, l.hash)
+		}
+	}
+
+	for _, hash := range sortedKeys(types) {
+		if reachable[hash] || corrupt[hash] {
+			continue
+		}
+		if opts.Unreachable {
+			report.Issues = append(report.Issues, Issue{Kind: KindUnreachable, Type: types[hash], Hash: hash})
+		} else if !opts.NoDangling && !referenced[hash] {
+			report.Issues = append(report.Issues, Issue{Kind: KindDangling, Type: types[hash], Hash: hash})
+		}
+	}
+
+	return report, nil
+}
+
+// parseLinks returns the objects referenced by a tree, commit or tag.
+// Gitlinks are skipped because submodule commits live in another repository.
+func parseLinks(objType string, content []byte) ([]link, error) {
+	var result []link
+	switch objType {
+	case "tree":
+		entries, err := objects.ParseTreePayload(content)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type == "commit" {
+				continue
+			}
+			result = append(result, link{hash: entry.Hash, objType: entry.Type})
+		}
+	case "commit":
+		commit, err := objects.ParseCommit(content)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, link{hash: commit.Tree, objType: "tree"})
+		for _, parent := range commit.Parents {
+			result = append(result, link{hash: parent, objType: "commit"})
+		}
+	case "tag":
+		tag, err := objects.ParseTag(content)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, link{hash: tag.Object, objType: tag.Type})
+	}
+	return result, nil
+}
+
+// indexRoots returns the blobs staged in the index, in path order, and the
+// trees of its valid cache-tree entries. Gitlinks and intent-to-add entries
+// name no object of this repository.
+func indexRoots(repo *objects.Repository) (staged, cached []string, err error) {
+	if repo.InMemory() {
+		return nil, nil, nil
+	}
+	idx, err := index.Open(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, e := range idx.Entries {
+		if e.Mode != index.ModeGitlink && !e.IntentToAdd {
+			staged = append(staged, e.Hash)
+		}
+	}
+	var walk func(t *index.CacheTree)
+	walk = func(t *index.CacheTree) {
+		if t.Valid() {
+			cached = append(cached, t.Hash)
+		}
+		for _, sub := range t.Subtrees {
+			walk(sub)
+		}
+	}
+	if idx.Tree != nil {
+		walk(idx.Tree)
+	}
+	return staged, cached, nil
+}
+
+// refRoots collects the object every ref points at, including HEAD and
+// every reflog entry. In-memory repositories have no refs.
+// Symbolic refs that cannot be resolved are reported.
+func refRoots(repo *objects.Repository) (map[string]string, []Issue, error) {
+	roots := make(map[string]string)
+	var issues []Issue
+	if repo.InMemory() || repo.Refs == nil {
+		return roots, nil, nil
+	}
+
+	all, err := repo.Refs.List("refs/")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, ref := range all {
+		if !ref.IsSymbolic() {
+			roots[ref.Name] = ref.Hash
+			continue
+		}
+		hash, err := repo.Refs.Resolve(ref.Name)
+		if err != nil {
+			issues = append(issues, Issue{Kind: KindBadRef, Hash: ref.Name, Detail: "symbolic ref points nowhere: " + ref.Target})
+			continue
+		}
+		roots[ref.Name] = hash
+	}
+
+	// Like git, objects recorded in reflogs count as reachable
+	logs, err := repo.Refs.Reflogs()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range logs {
+		entries, err := repo.Refs.ReadReflog(name)
+		if err != nil {
+			issues = append(issues, Issue{Kind: KindBadRef, Hash: name, Detail: err.Error()})
+			continue
+		}
+		for i, entry := range entries {
+			if entry.New != refs.ZeroHash {
+				roots[fmt.Sprintf("%s@{%d}", name, len(entries)-1-i)] = entry.New
+			}
+		}
+	}
+
+	// An unborn HEAD is fine; anything else must resolve to an object
+	if hash, err := repo.Refs.Resolve("HEAD"); err == nil {
+		roots["HEAD"] = hash
+	} else if !errors.Is(err, refs.ErrNotFound) {
+		issues = append(issues, Issue{Kind: KindBadRef, Hash: "HEAD", Detail: err.Error()})
+	}
+
+	return roots, issues, nil
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+package fsck
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// TestCheckMemoryRepository checks a repository without a git directory,
+// which has neither refs nor an index, so every commit is dangling
+func TestCheckMemoryRepository(t *testing.T) {
+	repo := objects.NewMemoryRepository("")
+	write := func(objType, content string) string {
+		t.Helper()
+		hash, _, err := repo.WriteObject(objType, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	blob := write("blob", "hello\n")
+	raw, _ := hex.DecodeString(blob)
+	tree := write("tree", "100644 a\x00"+string(raw))
+	commit := write("commit", "tree "+tree+"\nauthor A <a@b> 0 +0000\ncommitter A <a@b> 0 +0000\n\nm\n")
+	const missingTree = "0123456789012345678901234567890123456789"
+	broken := write("commit", "tree "+missingTree+"\nauthor A <a@b> 0 +0000\ncommitter A <a@b> 0 +0000\n\nm\n")
+
+	report, err := Check(repo, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 4 {
+		t.Errorf("Checked = %d, want 4", report.Checked)
+	}
+	want := map[Issue]bool{
+		{Kind: KindDangling, Type: "commit", Hash: commit}: true,
+		{Kind: KindDangling, Type: "commit", Hash: broken}: true,
+	}
+	for _, issue := range report.Issues {
+		if !want[issue] {
+			t.Errorf("unexpected issue %q", issue)
+		}
+		delete(want, issue)
+	}
+	for issue := range want {
+		t.Errorf("missing issue %q", issue)
+	}
+}
+
+// tamperedStore returns other content for one of its objects
+type tamperedStore struct {
+	objects.ObjectStore
+	hash    string
+	content string
+}
+
+func (s *tamperedStore) Read(hash string) (string, []byte, error) {
+	objType, content, err := s.ObjectStore.Read(hash)
+	if hash == s.hash {
+		content = []byte(s.content)
+	}
+	return objType, content, err
+}
+
+// TestCheckCorruptObject checks that an object whose content does not match
+// its name is reported as corrupt, and not as missing from the tree that
+// names it
+func TestCheckCorruptObject(t *testing.T) {
+	t.Setenv("GIT_COMMITTER_NAME", "C O Mitter")
+	t.Setenv("GIT_COMMITTER_EMAIL", "committer@example.com")
+	repo, err := objects.InitRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	blob, _, err := repo.WriteObject("blob", []byte("hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := hex.DecodeString(blob)
+	tree, _, err := repo.WriteObject("tree", []byte("100644 a\x00"+string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, _, err := repo.WriteObject("commit", []byte("tree "+tree+"\nauthor A <a@b> 0 +0000\ncommitter A <a@b> 0 +0000\n\nm\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Refs.Update("HEAD", commit, "", "commit (initial): m"); err != nil {
+		t.Fatal(err)
+	}
+	repo.Objects = &tamperedStore{ObjectStore: repo.Objects, hash: blob, content: "tampered\n"}
+
+	report, err := Check(repo, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[Issue]bool{
+		{Kind: KindCorrupt, Type: "blob", Hash: blob, Detail: "hash mismatch, content hashes to " + objects.ComputeHash("blob", []byte("tampered\n"))}: true,
+	}
+	for _, issue := range report.Issues {
+		if !want[issue] {
+			t.Errorf("unexpected issue %q", issue)
+		}
+		delete(want, issue)
+	}
+	for issue := range want {
+		t.Errorf("missing issue %q", issue)
+	}
+}
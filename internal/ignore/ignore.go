@@ -0,0 +1,254 @@
+// Package ignore decides which untracked files git leaves alone, from the
+// patterns in .gitignore files, .git/info/exclude and core.excludesFile.
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/config"
+	"github.com/master-wayne7/go-git/internal/wildmatch"
+)
+
+// Pattern is one rule of an ignore file
+type Pattern struct {
+	// Text is the line as written, for reporting
+	Text string
+	// Source is the file the pattern was read from, relative to the work
+	// tree when it lies inside it, and Line its line number
+	Source string
+	Line   int
+	// Negate is set for "!pattern", which re-includes what it matches
+	Negate bool
+
+	// base is the directory the pattern is relative to ("" or "dir/")
+	base string
+	glob string
+	// dirOnly patterns end in "/" and only match directories
+	dirOnly bool
+	// anchored patterns contain a slash and match the whole relative path;
+	// others match the last path component at any depth
+	anchored bool
+}
+
+// parsePattern parses one line of an ignore file found in base, returning
+// nil for blank lines and comments
+func parsePattern(line, base, source string, lineNo int) *Pattern {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpaces(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	p := &Pattern{Text: line, Source: source, Line: lineNo, base: base}
+	glob := line
+	if strings.HasPrefix(glob, "!") {
+		p.Negate = true
+		glob = glob[1:]
+	} else if strings.HasPrefix(glob, `\!`) || strings.HasPrefix(glob, `\#`) {
+		glob = glob[1:]
+	}
+	if strings.HasSuffix(glob, "/") {
+		p.dirOnly = true
+		glob = strings.TrimSuffix(glob, "/")
+	}
+	if strings.Contains(glob, "/") {
+		p.anchored = true
+		glob = strings.TrimPrefix(glob, "/")
+	}
+	if glob == "" {
+		return nil
+	}
+	p.glob = glob
+	return p
+}
+
+// trimTrailingSpaces drops unescaped trailing spaces
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		// count the backslashes escaping this space
+		slashes := 0
+		for i := end - 2; i >= 0 && line[i] == '\\'; i-- {
+			slashes++
+		}
+		if slashes%2 == 1 {
+			break
+		}
+		end--
+	}
+	return line[:end]
+}
+
+// matches reports whether the pattern applies to path (relative to the
+// work tree), which is a directory when isDir is set
+func (p *Pattern) matches(path string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if !strings.HasPrefix(path, p.base) {
+		return false
+	}
+	rel := path[len(p.base):]
+	if !p.anchored {
+		rel = rel[strings.LastIndexByte(rel, '/')+1:]
+	}
+	return wildmatch.Match(p.glob, rel, wildmatch.Pathname)
+}
+
+// Matcher answers whether work tree paths are ignored. Each directory's
+// .gitignore is read the first time a path below it is checked.
+type Matcher struct {
+	workTree string
+	// excludes are the patterns that apply everywhere, lowest precedence
+	// first: core.excludesFile, then .git/info/exclude
+	excludes [][]*Pattern
+	// perDir caches the .gitignore patterns of each directory ("" or "dir/")
+	perDir map[string][]*Pattern
+}
+
+// NewMatcher creates a matcher for a work tree and its git directory
+// (which may be "" for none). core.excludesFile is looked up in cfg, which
+// may be nil; it defaults to $XDG_CONFIG_HOME/git/ignore.
+func NewMatcher(workTree, gitDir string, cfg *config.Config) (*Matcher, error) {
+	m := &Matcher{workTree: workTree, perDir: make(map[string][]*Pattern)}
+	global, err := excludesFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{global}
+	if gitDir != "" {
+		files = append(files, filepath.Join(gitDir, "info", "exclude"))
+	}
+	for _, path := range files {
+		if path == "" {
+			continue
+		}
+		patterns, err := readPatterns(path, "", m.sourceName(path))
+		if err != nil {
+			return nil, err
+		}
+		m.excludes = append(m.excludes, patterns)
+	}
+	return m, nil
+}
+
+// excludesFile returns the path of the user's global ignore file
+func excludesFile(cfg *config.Config) (string, error) {
+	if cfg != nil {
+		path, err := cfg.Path("core.excludesFile")
+		if !errors.Is(err, config.ErrNotFound) {
+			return path, err
+		}
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "git", "ignore"), nil
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config", "git", "ignore"), nil
+	}
+	return "", nil
+}
+
+// sourceName names an ignore file for reporting: relative to the work tree
+// when it is inside it, as given otherwise
+func (m *Matcher) sourceName(path string) string {
+	rel, err := filepath.Rel(m.workTree, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// readPatterns reads an ignore file whose patterns are relative to base; a
+// missing file has no patterns
+func readPatterns(path, base, source string) ([]*Pattern, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var patterns []*Pattern
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if p := parsePattern(scanner.Text(), base, source, lineNo); p != nil {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns, nil
+}
+
+// dirPatterns returns the .gitignore patterns of a directory
+func (m *Matcher) dirPatterns(dir string) []*Pattern {
+	if patterns, ok := m.perDir[dir]; ok {
+		return patterns
+	}
+	path := filepath.Join(m.workTree, filepath.FromSlash(dir), ".gitignore")
+	// an unreadable .gitignore is treated as empty, as git does
+	patterns, _ := readPatterns(path, dir, dir+".gitignore")
+	m.perDir[dir] = patterns
+	return patterns
+}
+
+// matchOne returns the pattern deciding path, ignoring its parents: the
+// last matching line of the deepest .gitignore wins, then info/exclude
+func (m *Matcher) matchOne(path string, isDir bool) *Pattern {
+	var dirs []string
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' {
+			dirs = append(dirs, path[:i+1])
+		}
+	}
+	dirs = append(dirs, "")
+
+	for _, dir := range dirs {
+		if p := lastMatch(m.dirPatterns(dir), path, isDir); p != nil {
+			return p
+		}
+	}
+	for i := len(m.excludes) - 1; i >= 0; i-- {
+		if p := lastMatch(m.excludes[i], path, isDir); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// lastMatch returns the last pattern in the list that matches path
+func lastMatch(patterns []*Pattern, path string, isDir bool) *Pattern {
+	for i := len(patterns) - 1; i >= 0; i-- {
+		if patterns[i].matches(path, isDir) {
+			return patterns[i]
+		}
+	}
+	return nil
+}
+
+// Match returns the pattern that decides whether path (slash-separated,
+// relative to the work tree) is ignored, or nil when none applies. A file
+// inside an ignored directory is ignored by that directory's pattern, since
+// git cannot re-include files below an excluded directory.
+func (m *Matcher) Match(path string, isDir bool) *Pattern {
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' {
+			if p := m.matchOne(path[:i], true); p != nil && !p.Negate {
+				return p
+			}
+		}
+	}
+	return m.matchOne(path, isDir)
+}
+
+// Ignored reports whether path is ignored
+func (m *Matcher) Ignored(path string, isDir bool) bool {
+	p := m.Match(path, isDir)
+	return p != nil && !p.Negate
+}
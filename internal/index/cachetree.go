@@ -0,0 +1,134 @@
+package index
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CacheTree is the TREE extension: the tree objects already written for
+// directories of the index, so unchanged directories need not be rehashed.
+// A node with EntryCount -1 has changed since its tree was written.
+type CacheTree struct {
+	// Name is the directory name relative to its parent ("" for the root)
+	Name string
+	// EntryCount is the number of index entries the tree covers
+	EntryCount int
+	Hash       string
+	// Subtrees are ordered by name length, then name, like git's
+	Subtrees []*CacheTree
+}
+
+// Valid reports whether the node's tree is up to date
+func (t *CacheTree) Valid() bool {
+	return t.EntryCount >= 0
+}
+
+// parseCacheTree decodes the data of a TREE extension
+func parseCacheTree(data []byte) (*CacheTree, error) {
+	tree, rest, err := parseCacheTreeNode(data)
+	if err != nil {
+		return nil, fmt.Errorf("bad TREE extension: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("bad TREE extension: trailing data")
+	}
+	return tree, nil
+}
+
+// parseCacheTreeNode decodes "<name>\0<count> <subtrees>\n[<hash>]" and then
+// the subtrees, returning the data that follows
+func parseCacheTreeNode(data []byte) (*CacheTree, []byte, error) {
+	nul := bytes.IndexByte(data, 0)
+	if nul < 0 {
+		return nil, nil, fmt.Errorf("unterminated name")
+	}
+	t := &CacheTree{Name: string(data[:nul])}
+	data = data[nul+1:]
+
+	nl := bytes.IndexByte(data, '\n')
+	if nl < 0 {
+		return nil, nil, fmt.Errorf("unterminated counts")
+	}
+	countStr, subStr, ok := strings.Cut(string(data[:nl]), " ")
+	if !ok {
+		return nil, nil, fmt.Errorf("bad counts %q", data[:nl])
+	}
+	count, err1 := strconv.Atoi(countStr)
+	subtrees, err2 := strconv.Atoi(subStr)
+	if err1 != nil || err2 != nil || subtrees < 0 {
+		return nil, nil, fmt.Errorf("bad counts %q", data[:nl])
+	}
+	t.EntryCount = count
+	data = data[nl+1:]
+
+	if count >= 0 {
+		if len(data) < 20 {
+			return nil, nil, fmt.Errorf("truncated hash")
+		}
+		t.Hash = hex.EncodeToString(data[:20])
+		data = data[20:]
+	}
+
+	for i := 0; i < subtrees; i++ {
+		sub, rest, err := parseCacheTreeNode(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		t.Subtrees = append(t.Subtrees, sub)
+		data = rest
+	}
+	return t, data, nil
+}
+
+// encode appends the node and its subtrees in the TREE extension format
+func (t *CacheTree) encode(buf *bytes.Buffer) {
+	buf.WriteString(t.Name)
+	buf.WriteByte(0)
+	fmt.Fprintf(buf, "%d %d\n", t.EntryCount, len(t.Subtrees))
+	if t.Valid() {
+		raw, _ := hex.DecodeString(t.Hash)
+		buf.Write(raw)
+	}
+	for _, sub := range t.Subtrees {
+		sub.encode(buf)
+	}
+}
+
+// invalidate marks this node and the nodes on the way to path as changed
+func (t *CacheTree) invalidate(path string) {
+	t.EntryCount = -1
+	t.Hash = ""
+	dir, rest, ok := strings.Cut(path, "/")
+	if !ok {
+		return
+	}
+	if sub := t.subtree(dir); sub != nil {
+		sub.invalidate(rest)
+	}
+}
+
+// subtree returns the child named name, or nil
+func (t *CacheTree) subtree(name string) *CacheTree {
+	for _, sub := range t.Subtrees {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// setSubtrees replaces the children, in git's order
+func (t *CacheTree) setSubtrees(subtrees []*CacheTree) {
+	sort.Slice(subtrees, func(i, j int) bool {
+		a, b := subtrees[i].Name, subtrees[j].Name
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	t.Subtrees = subtrees
+}
@@ -0,0 +1,501 @@
+// Package index reads and writes the staging area (.git/index, the
+// "dircache"): the list of paths, modes, object names and cached stat data
+// that the next commit is built from.
+package index
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+const (
+	signature = "DIRC"
+
+	// flags in the 16-bit entry flags field
+	flagAssumeValid = 0x8000
+	flagExtended    = 0x4000
+	flagStageMask   = 0x3000
+	flagStageShift  = 12
+	flagNameMask    = 0x0fff
+
+	// flags in the 16-bit extended flags field (version 3 and later)
+	flagSkipWorktree = 0x4000
+	flagIntentToAdd  = 0x2000
+
+	// entryHeaderSize is the fixed part of an entry before the path:
+	// ten 32-bit stat fields, the object name and the flags
+	entryHeaderSize = 10*4 + 20 + 2
+)
+
+// Entry modes, as stored in the index and in trees
+const (
+	ModeRegular    uint32 = 0100644
+	ModeExecutable uint32 = 0100755
+	ModeSymlink    uint32 = 0120000
+	ModeGitlink    uint32 = 0160000
+)
+
+// ErrLocked is returned when another process holds index.lock
+var ErrLocked = errors.New("index file is locked")
+
+// Entry is one staged path. Paths appear once at stage 0, or at stages 1-3
+// (base, ours, theirs) while a merge conflict is unresolved.
+type Entry struct {
+	Path  string
+	Hash  string
+	Mode  uint32
+	Stage int
+
+	// cached stat data, used to tell whether the file may have changed
+	CTime time.Time
+	MTime time.Time
+	Dev   uint32
+	Ino   uint32
+	UID   uint32
+	GID   uint32
+	Size  uint32
+
+	AssumeValid  bool
+	SkipWorktree bool
+	IntentToAdd  bool
+}
+
+// ModeString returns the entry's mode as written in trees, e.g. "100644"
+func (e *Entry) ModeString() string {
+	return fmt.Sprintf("%o", e.Mode)
+}
+
+// Index is the content of an index file
+type Index struct {
+	// Path is the file the index is read from and written to
+	Path string
+	// Version is 2, 3 or 4; 3 is needed for extended flags and 4
+	// compresses paths against the previous entry
+	Version uint32
+	// Entries are sorted by path, then stage
+	Entries []*Entry
+	// Tree is the cached tree (TREE extension), or nil
+	Tree *CacheTree
+	// ModTime is the index file's modification time when it was read.
+	// Entries modified at or after it are "racily clean" and cannot be
+	// trusted on stat data alone.
+	ModTime time.Time
+}
+
+// FilePath returns the index file of a repository, honouring GIT_INDEX_FILE
+func FilePath(repo *objects.Repository) string {
+	if path := os.Getenv("GIT_INDEX_FILE"); path != "" {
+		return path
+	}
+	return repo.Path("index")
+}
+
+// Open reads the index of a repository; a missing index is empty
+func Open(repo *objects.Repository) (*Index, error) {
+	return ReadFile(FilePath(repo))
+}
+
+// ReadFile reads an index file; a missing file is an empty version 2 index
+func ReadFile(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Index{Path: path, Version: 2}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	idx, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	idx.Path = path
+	if info, err := os.Stat(path); err == nil {
+		idx.ModTime = info.ModTime()
+	}
+	return idx, nil
+}
+
+// Parse decodes the content of an index file, verifying its checksum
+func Parse(data []byte) (*Index, error) {
+	if len(data) < 12+sha1.Size {
+		return nil, fmt.Errorf("index file too short")
+	}
+	body := data[:len(data)-sha1.Size]
+	sum := sha1.Sum(body)
+	if !bytes.Equal(sum[:], data[len(body):]) {
+		return nil, fmt.Errorf("index file checksum mismatch")
+	}
+	if string(body[:4]) != signature {
+		return nil, fmt.Errorf("bad index file signature")
+	}
+
+	idx := &Index{Version: binary.BigEndian.Uint32(body[4:8])}
+	if idx.Version < 2 || idx.Version > 4 {
+		return nil, fmt.Errorf("bad index file version %d", idx.Version)
+	}
+	count := binary.BigEndian.Uint32(body[8:12])
+
+	pos := 12
+	prevPath := ""
+	for i := uint32(0); i < count; i++ {
+		entry, n, err := parseEntry(body[pos:], idx.Version, prevPath)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		idx.Entries = append(idx.Entries, entry)
+		prevPath = entry.Path
+		pos += n
+	}
+
+	// extensions: a 4-byte signature, a 32-bit size and the data
+	for pos < len(body) {
+		if len(body)-pos < 8 {
+			return nil, fmt.Errorf("truncated extension header")
+		}
+		sig := string(body[pos : pos+4])
+		size := int(binary.BigEndian.Uint32(body[pos+4 : pos+8]))
+		pos += 8
+		if size > len(body)-pos {
+			return nil, fmt.Errorf("truncated %s extension", sig)
+		}
+		ext := body[pos : pos+size]
+		pos += size
+
+		switch {
+		case sig == "TREE":
+			tree, err := parseCacheTree(ext)
+			if err != nil {
+				return nil, err
+			}
+			idx.Tree = tree
+		case sig[0] >= 'A' && sig[0] <= 'Z':
+			// optional extensions are caches that may be dropped
+		default:
+			return nil, fmt.Errorf("unsupported index extension %q", sig)
+		}
+	}
+	return idx, nil
+}
+
+// parseEntry decodes one entry and returns it with its encoded length
+func parseEntry(data []byte, version uint32, prevPath string) (*Entry, int, error) {
+	if len(data) < entryHeaderSize {
+		return nil, 0, fmt.Errorf("truncated entry")
+	}
+	u32 := func(i int) uint32 { return binary.BigEndian.Uint32(data[i*4:]) }
+	e := &Entry{
+		CTime: time.Unix(int64(u32(0)), int64(u32(1))),
+		MTime: time.Unix(int64(u32(2)), int64(u32(3))),
+		Dev:   u32(4),
+		Ino:   u32(5),
+		Mode:  u32(6),
+		UID:   u32(7),
+		GID:   u32(8),
+		Size:  u32(9),
+		Hash:  hex.EncodeToString(data[40:60]),
+	}
+	flags := binary.BigEndian.Uint16(data[60:62])
+	e.AssumeValid = flags&flagAssumeValid != 0
+	e.Stage = int(flags&flagStageMask) >> flagStageShift
+	pos := entryHeaderSize
+
+	if flags&flagExtended != 0 {
+		if version < 3 {
+			return nil, 0, fmt.Errorf("extended flags in a version %d index", version)
+		}
+		if len(data) < pos+2 {
+			return nil, 0, fmt.Errorf("truncated entry")
+		}
+		extended := binary.BigEndian.Uint16(data[pos:])
+		e.SkipWorktree = extended&flagSkipWorktree != 0
+		e.IntentToAdd = extended&flagIntentToAdd != 0
+		pos += 2
+	}
+
+	if version == 4 {
+		// the path is the previous one minus n trailing bytes, plus a suffix
+		strip, n := decodeVarint(data[pos:])
+		if n == 0 || strip > uint64(len(prevPath)) {
+			return nil, 0, fmt.Errorf("bad path prefix")
+		}
+		pos += n
+		end := bytes.IndexByte(data[pos:], 0)
+		if end < 0 {
+			return nil, 0, fmt.Errorf("unterminated path")
+		}
+		e.Path = prevPath[:len(prevPath)-int(strip)] + string(data[pos:pos+end])
+		return e, pos + end + 1, nil
+	}
+
+	end := bytes.IndexByte(data[pos:], 0)
+	if end < 0 {
+		return nil, 0, fmt.Errorf("unterminated path")
+	}
+	e.Path = string(data[pos : pos+end])
+	// entries are NUL padded to a multiple of eight bytes
+	size := (pos + end + 8) &^ 7
+	if size > len(data) {
+		return nil, 0, fmt.Errorf("truncated entry")
+	}
+	return e, size, nil
+}
+
+// Encode serializes the index, including its checksum
+func (idx *Index) Encode() []byte {
+	version := idx.Version
+	if version == 0 {
+		version = 2
+	}
+	if version == 2 {
+		for _, e := range idx.Entries {
+			if e.SkipWorktree || e.IntentToAdd {
+				version = 3
+				break
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(signature)
+	binary.Write(&buf, binary.BigEndian, version)
+	binary.Write(&buf, binary.BigEndian, uint32(len(idx.Entries)))
+
+	prevPath := ""
+	for _, e := range idx.Entries {
+		encodeEntry(&buf, e, version, prevPath)
+		prevPath = e.Path
+	}
+
+	if idx.Tree != nil {
+		var ext bytes.Buffer
+		idx.Tree.encode(&ext)
+		buf.WriteString("TREE")
+		binary.Write(&buf, binary.BigEndian, uint32(ext.Len()))
+		buf.Write(ext.Bytes())
+	}
+
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+	return buf.Bytes()
+}
+
+// encodeEntry appends one entry in the given index version
+func encodeEntry(buf *bytes.Buffer, e *Entry, version uint32, prevPath string) {
+	start := buf.Len()
+	for _, v := range []uint32{
+		uint32(e.CTime.Unix()), uint32(e.CTime.Nanosecond()),
+		uint32(e.MTime.Unix()), uint32(e.MTime.Nanosecond()),
+		e.Dev, e.Ino, e.Mode, e.UID, e.GID, e.Size,
+	} {
+		binary.Write(buf, binary.BigEndian, v)
+	}
+	raw, _ := hex.DecodeString(e.Hash)
+	buf.Write(raw)
+
+	flags := uint16(e.Stage<<flagStageShift) & flagStageMask
+	if len(e.Path) < flagNameMask {
+		flags |= uint16(len(e.Path))
+	} else {
+		flags |= flagNameMask
+	}
+	if e.AssumeValid {
+		flags |= flagAssumeValid
+	}
+	extended := version >= 3 && (e.SkipWorktree || e.IntentToAdd)
+	if extended {
+		flags |= flagExtended
+	}
+	binary.Write(buf, binary.BigEndian, flags)
+	if extended {
+		var ext uint16
+		if e.SkipWorktree {
+			ext |= flagSkipWorktree
+		}
+		if e.IntentToAdd {
+			ext |= flagIntentToAdd
+		}
+		binary.Write(buf, binary.BigEndian, ext)
+	}
+
+	if version == 4 {
+		common := 0
+		for common < len(prevPath) && common < len(e.Path) && prevPath[common] == e.Path[common] {
+			common++
+		}
+		buf.Write(encodeVarint(uint64(len(prevPath) - common)))
+		buf.WriteString(e.Path[common:])
+		buf.WriteByte(0)
+		return
+	}
+
+	buf.WriteString(e.Path)
+	size := (buf.Len() - start + 8) &^ 7
+	buf.Write(make([]byte, size-(buf.Len()-start)))
+}
+
+// decodeVarint decodes git's offset varint, in which each continuation
+// adds one before shifting so that every value has a single encoding. It
+// returns the value and the number of bytes read (0 when truncated).
+func decodeVarint(data []byte) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	c := data[0]
+	value := uint64(c & 0x7f)
+	n := 1
+	for c&0x80 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+		c = data[n]
+		n++
+		value = ((value + 1) << 7) | uint64(c&0x7f)
+	}
+	return value, n
+}
+
+// encodeVarint is the inverse of decodeVarint
+func encodeVarint(value uint64) []byte {
+	var tmp [16]byte
+	pos := len(tmp) - 1
+	tmp[pos] = byte(value & 0x7f)
+	for value >>= 7; value != 0; value >>= 7 {
+		value--
+		pos--
+		tmp[pos] = 0x80 | byte(value&0x7f)
+	}
+	return tmp[pos:]
+}
+
+// Write stores the index at idx.Path through "<path>.lock"
+func (idx *Index) Write() error {
+	if err := os.MkdirAll(filepath.Dir(idx.Path), 0755); err != nil {
+		return err
+	}
+	lockPath := idx.Path + ".lock"
+	lock, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("unable to create '%s': %w", lockPath, ErrLocked)
+	} else if err != nil {
+		return err
+	}
+	if _, err := lock.Write(idx.Encode()); err != nil {
+		lock.Close()
+		os.Remove(lockPath)
+		return err
+	}
+	if err := lock.Close(); err != nil {
+		os.Remove(lockPath)
+		return err
+	}
+	if err := os.Rename(lockPath, idx.Path); err != nil {
+		os.Remove(lockPath)
+		return err
+	}
+	if info, err := os.Stat(idx.Path); err == nil {
+		idx.ModTime = info.ModTime()
+	}
+	return nil
+}
+
+// compareEntries orders entries by path, then stage
+func compareEntries(path string, stage int, e *Entry) int {
+	if c := strings.Compare(path, e.Path); c != 0 {
+		return c
+	}
+	return stage - e.Stage
+}
+
+// find returns the position of path at stage, and whether it is there
+func (idx *Index) find(path string, stage int) (int, bool) {
+	i := sort.Search(len(idx.Entries), func(i int) bool {
+		return compareEntries(path, stage, idx.Entries[i]) <= 0
+	})
+	return i, i < len(idx.Entries) && compareEntries(path, stage, idx.Entries[i]) == 0
+}
+
+// Entry returns the stage 0 entry for path, or nil
+func (idx *Index) Entry(path string) *Entry {
+	if i, ok := idx.find(path, 0); ok {
+		return idx.Entries[i]
+	}
+	return nil
+}
+
+// Stages returns every entry for path: one at stage 0, or the conflict
+// stages 1-3 of an unresolved merge
+func (idx *Index) Stages(path string) []*Entry {
+	i, _ := idx.find(path, 0)
+	var entries []*Entry
+	for ; i < len(idx.Entries) && idx.Entries[i].Path == path; i++ {
+		entries = append(entries, idx.Entries[i])
+	}
+	return entries
+}
+
+// Add inserts or replaces an entry. Adding a path at stage 0 resolves any
+// conflict stages it had.
+func (idx *Index) Add(e *Entry) {
+	if e.Stage == 0 {
+		idx.removeStages(e.Path)
+	}
+	i, ok := idx.find(e.Path, e.Stage)
+	if ok {
+		idx.Entries[i] = e
+	} else {
+		idx.Entries = append(idx.Entries, nil)
+		copy(idx.Entries[i+1:], idx.Entries[i:])
+		idx.Entries[i] = e
+	}
+	idx.invalidate(e.Path)
+}
+
+// Remove deletes every stage of path and reports whether it was present
+func (idx *Index) Remove(path string) bool {
+	removed := idx.removeStages(path)
+	if removed {
+		idx.invalidate(path)
+	}
+	return removed
+}
+
+// removeStages deletes every entry for path
+func (idx *Index) removeStages(path string) bool {
+	i, _ := idx.find(path, 0)
+	j := i
+	for j < len(idx.Entries) && idx.Entries[j].Path == path {
+		j++
+	}
+	if i == j {
+		return false
+	}
+	idx.Entries = append(idx.Entries[:i], idx.Entries[j:]...)
+	return true
+}
+
+// invalidate marks the cached trees containing path as out of date
+func (idx *Index) invalidate(path string) {
+	if idx.Tree != nil {
+		idx.Tree.invalidate(path)
+	}
+}
+
+// Unmerged reports whether any path has conflict stages
+func (idx *Index) Unmerged() bool {
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+package index
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// readFixture loads an index file that git wrote into testdata
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// fixtureTree is the tree git wrote for the fixture entries
+func fixtureTree(t *testing.T) string {
+	t.Helper()
+	return strings.TrimSpace(string(readFixture(t, "tree")))
+}
+
+// TestParseEncodeGit parses index files written by git and checks that
+// encoding them gives back the same bytes
+func TestParseEncodeGit(t *testing.T) {
+	tests := []struct {
+		file    string
+		version uint32
+		entries int
+		tree    bool
+		intent  string
+	}{
+		{"added.index", 2, 10, false, ""},
+		{"tree.index", 2, 10, true, ""},
+		{"intent.index", 3, 11, true, "a/b/new"},
+		{"v4.index", 4, 11, true, "a/b/new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data := readFixture(t, tt.file)
+			idx, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idx.Version != tt.version {
+				t.Errorf("Version = %d, want %d", idx.Version, tt.version)
+			}
+			if len(idx.Entries) != tt.entries {
+				t.Fatalf("%d entries, want %d", len(idx.Entries), tt.entries)
+			}
+			if (idx.Tree != nil) != tt.tree {
+				t.Errorf("Tree = %v, want present %v", idx.Tree, tt.tree)
+			}
+			for _, e := range idx.Entries {
+				if e.IntentToAdd != (e.Path == tt.intent) {
+					t.Errorf("%s: IntentToAdd = %v", e.Path, e.IntentToAdd)
+				}
+			}
+
+			modes := map[string]uint32{"run": ModeExecutable, "link": ModeSymlink, "sub": ModeGitlink, "a/b/x": ModeRegular}
+			for path, mode := range modes {
+				if e := idx.Entry(path); e == nil || e.Mode != mode {
+					t.Errorf("%s: entry %+v, want mode %o", path, e, mode)
+				}
+			}
+
+			if got := idx.Encode(); !bytes.Equal(got, data) {
+				t.Errorf("encoding differs from git's (%d bytes, want %d)", len(got), len(data))
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	valid := readFixture(t, "tree.index")
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"too short", valid[:20], "too short"},
+		{"corrupt", append(bytes.Clone(valid[:100]), valid[101:]...), "checksum mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCacheTreeGit checks the TREE extension git wrote, including the
+// directories that adding an intent-to-add path invalidated
+func TestCacheTreeGit(t *testing.T) {
+	type node struct {
+		path     string
+		count    int
+		subtrees int
+	}
+	tests := []struct {
+		file  string
+		nodes []node
+	}{
+		{"tree.index", []node{{"", 10, 2}, {"a", 3, 1}, {"a/b", 2, 0}, {"c.d", 1, 0}}},
+		{"intent.index", []node{{"", -1, 2}, {"a", -1, 1}, {"a/b", -1, 0}, {"c.d", 1, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			idx, err := Parse(readFixture(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.nodes {
+				n := idx.Tree
+				if want.path != "" {
+					for _, name := range strings.Split(want.path, "/") {
+						if n = n.subtree(name); n == nil {
+							t.Fatalf("no cached tree for %q", want.path)
+						}
+					}
+				}
+				if n.EntryCount != want.count || len(n.Subtrees) != want.subtrees {
+					t.Errorf("%q: %d entries and %d subtrees, want %d and %d", want.path, n.EntryCount, len(n.Subtrees), want.count, want.subtrees)
+				}
+			}
+			if tt.file == "tree.index" && idx.Tree.Hash != fixtureTree(t) {
+				t.Errorf("root hash %s, want %s", idx.Tree.Hash, fixtureTree(t))
+			}
+		})
+	}
+}
+
+// TestWriteTreeGit writes the trees of git's index into a MemoryStore and
+// checks both the tree and the TREE extension against what git wrote
+func TestWriteTreeGit(t *testing.T) {
+	tests := []struct {
+		from, want string
+	}{
+		{"added.index", "tree.index"},
+		{"tree.index", "tree.index"},
+		{"intent.index", "intent.index"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			idx, err := Parse(readFixture(t, tt.from))
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo := objects.NewMemoryRepository("")
+			for _, e := range idx.Entries {
+				content := e.Path + "\n"
+				switch {
+				case e.Mode == ModeGitlink:
+					continue
+				case e.Mode == ModeSymlink:
+					content = "a-b"
+				}
+				if _, _, err := repo.WriteObject("blob", []byte(content)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			hash, err := idx.WriteTree(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hash != fixtureTree(t) {
+				t.Errorf("WriteTree() = %s, want %s", hash, fixtureTree(t))
+			}
+			if !bytes.Equal(idx.Encode(), readFixture(t, tt.want)) {
+				t.Errorf("index after WriteTree differs from %s", tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteTreeMissingObject(t *testing.T) {
+	idx, err := Parse(readFixture(t, "added.index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = idx.WriteTree(objects.NewMemoryRepository(""))
+	if err == nil || !strings.Contains(err.Error(), "invalid object") {
+		t.Fatalf("err = %v, want an invalid object error", err)
+	}
+}
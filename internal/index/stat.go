@@ -0,0 +1,48 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ModeOf returns the index mode for a file: a symlink, an executable or
+// regular file, or a gitlink for a directory holding another repository
+func ModeOf(path string, info os.FileInfo) uint32 {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return ModeSymlink
+	case info.IsDir():
+		if _, err := os.Lstat(filepath.Join(path, ".git")); err == nil {
+			return ModeGitlink
+		}
+		return 0
+	case info.Mode()&0111 != 0:
+		return ModeExecutable
+	}
+	return ModeRegular
+}
+
+// SetStat records the stat data of the file the entry was staged from
+func (e *Entry) SetStat(info os.FileInfo) {
+	e.MTime = info.ModTime()
+	e.CTime = info.ModTime()
+	e.Size = uint32(info.Size())
+	setSysStat(e, info)
+}
+
+// StatMatches reports whether a file's stat data is still the one recorded
+// when the entry was staged, in which case its content is assumed unchanged
+func (e *Entry) StatMatches(info os.FileInfo) bool {
+	current := Entry{}
+	current.SetStat(info)
+	return e.MTime.Equal(current.MTime) && e.CTime.Equal(current.CTime) &&
+		e.Size == current.Size && e.Ino == current.Ino && e.Dev == current.Dev &&
+		e.UID == current.UID && e.GID == current.GID
+}
+
+// IsRacy reports whether the entry's file was modified no earlier than the
+// index was written, so a change within the same timestamp could have gone
+// unnoticed and the content must be compared
+func (idx *Index) IsRacy(e *Entry) bool {
+	return !idx.ModTime.IsZero() && !e.MTime.Before(idx.ModTime)
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package index
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// setSysStat copies the fields only the operating system's stat result has
+func setSysStat(e *Entry, info os.FileInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	e.CTime = time.Unix(st.Ctim.Sec, st.Ctim.Nsec)
+	e.Dev = uint32(st.Dev)
+	e.Ino = uint32(st.Ino)
+	e.UID = st.Uid
+	e.GID = st.Gid
+}
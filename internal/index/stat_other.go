@@ -0,0 +1,8 @@
+//go:build !linux
+
+package index
+
+import "os"
+
+// setSysStat leaves the platform-specific fields unset
+func setSysStat(e *Entry, info os.FileInfo) {}
@@ -0,0 +1,28 @@
+#!/bin/sh
+# Regenerates the index fixtures with git. Every file holds its own path
+# followed by a newline and the symlink points at a-b, so tests can
+# recreate the blobs.
+set -e
+out=$(cd "$(dirname "$0")" && pwd)
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+cd "$tmp"
+git init -q
+for f in README a-b a.c a/b/x a/b/y a/z c.d/w run; do
+	mkdir -p "$(dirname "$f")"
+	echo "$f" >"$f"
+done
+chmod +x run
+ln -s a-b link
+git add .
+git update-index --add --cacheinfo 160000,5e2a3d6ab01f6d2c6f0b8a2cc4e1ee1f6e6f1e55,sub
+cp .git/index "$out/added.index"
+git write-tree >"$out/tree"
+cp .git/index "$out/tree.index"
+
+echo new >a/b/new
+git add -N a/b/new
+cp .git/index "$out/intent.index"
+
+git update-index --index-version 4
+cp .git/index "$out/v4.index"
@@ -0,0 +1,140 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/objects"
+)
+
+// WriteTree writes the tree objects for the staged content and returns the
+// root tree's hash. Directories whose cached tree is still valid are not
+// rewritten, and the cache is updated with the trees that were. Paths
+// marked intent-to-add are left out; unmerged paths and missing objects are
+// errors.
+func (idx *Index) WriteTree(repo *objects.Repository) (string, error) {
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			return "", fmt.Errorf("%s: unmerged (stage %d)", e.Path, e.Stage)
+		}
+	}
+	if idx.Tree == nil {
+		idx.Tree = &CacheTree{EntryCount: -1}
+	}
+	hash, _, err := writeTree(repo, idx.Tree, "", idx.Entries)
+	return hash, err
+}
+
+// writeTree writes the tree for entries, which all lie under prefix, and
+// returns its hash and how many entries it holds. Like git, it leaves the
+// cache of a directory with intent-to-add entries invalid.
+func writeTree(repo *objects.Repository, cache *CacheTree, prefix string, entries []*Entry) (string, int, error) {
+	if cache.Valid() && cache.EntryCount == len(entries) && repo.Objects.Has(cache.Hash) {
+		return cache.Hash, len(entries), nil
+	}
+
+	builder, err := repo.NewTreeBuilder("")
+	if err != nil {
+		return "", 0, err
+	}
+	var subtrees []*CacheTree
+	written, skipped := 0, 0
+	for i := 0; i < len(entries); {
+		e := entries[i]
+		rel := e.Path[len(prefix):]
+		mode := e.ModeString()
+		var hash string
+
+		if slash := strings.IndexByte(rel, '/'); slash >= 0 {
+			// every entry of a directory is adjacent, as "/" sorts them together
+			name := rel[:slash]
+			dirPrefix := prefix + name + "/"
+			j := i + 1
+			for j < len(entries) && strings.HasPrefix(entries[j].Path, dirPrefix) {
+				j++
+			}
+			sub := cache.subtree(name)
+			if sub == nil {
+				sub = &CacheTree{Name: name, EntryCount: -1}
+			}
+			subHash, n, err := writeTree(repo, sub, dirPrefix, entries[i:j])
+			if err != nil {
+				return "", 0, err
+			}
+			subtrees = append(subtrees, sub)
+			skipped += j - i - n
+			i = j
+			if n == 0 {
+				// only intent-to-add entries: no directory yet
+				continue
+			}
+			hash, rel, mode = subHash, name, "40000"
+			written += n
+		} else {
+			i++
+			if e.IntentToAdd {
+				skipped++
+				continue
+			}
+			if e.Mode != ModeGitlink && !repo.Objects.Has(e.Hash) {
+				return "", 0, fmt.Errorf("invalid object %s %s for '%s'", mode, e.Hash, e.Path)
+			}
+			hash = e.Hash
+			written++
+		}
+
+		if err := builder.Insert(rel, mode, hash); err != nil {
+			return "", 0, fmt.Errorf("%s: %w", e.Path, err)
+		}
+	}
+
+	hash, err := builder.Write()
+	if err != nil {
+		return "", 0, err
+	}
+	cache.Hash, cache.EntryCount = hash, len(entries)
+	if skipped > 0 {
+		cache.Hash, cache.EntryCount = "", -1
+	}
+	cache.setSubtrees(subtrees)
+	return hash, written, nil
+}
+
+// ReadTree replaces the entries with the content of a tree, like
+// `git read-tree`. The new entries have no stat data, and the cached trees
+// are set from the tree that was read.
+func (idx *Index) ReadTree(repo *objects.Repository, treeHash string) error {
+	idx.Entries = nil
+	idx.Tree = &CacheTree{}
+	return idx.readTree(repo, idx.Tree, treeHash, "")
+}
+
+// readTree appends the entries of a tree under prefix, in index order
+func (idx *Index) readTree(repo *objects.Repository, cache *CacheTree, treeHash, prefix string) error {
+	entries, err := repo.ParseTree(treeHash)
+	if err != nil {
+		return err
+	}
+	start := len(idx.Entries)
+	var subtrees []*CacheTree
+	for _, te := range entries {
+		if te.Type == "tree" {
+			sub := &CacheTree{Name: te.Name}
+			if err := idx.readTree(repo, sub, te.Hash, prefix+te.Name+"/"); err != nil {
+				return err
+			}
+			subtrees = append(subtrees, sub)
+			continue
+		}
+
+		var mode uint32
+		if _, err := fmt.Sscanf(te.Mode, "%o", &mode); err != nil {
+			return fmt.Errorf("%s%s: bad mode %s", prefix, te.Name, te.Mode)
+		}
+		idx.Entries = append(idx.Entries, &Entry{Path: prefix + te.Name, Hash: te.Hash, Mode: mode})
+	}
+	cache.Hash = treeHash
+	cache.EntryCount = len(idx.Entries) - start
+	cache.setSubtrees(subtrees)
+	return nil
+}
@@ -0,0 +1,477 @@
+package objects
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature is the identity and time of an author, committer or tagger,
+// stored as "Name <email> <unix-seconds> <+hhmm>"
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+
+	// raw is the identity exactly as parsed, written back as long as the
+	// fields still describe it, so unusual spacing such as "Name<email>"
+	// survives a round trip
+	raw string
+}
+
+// ParseSignature parses an identity line such as
+// "A U Thor <author@example.com> 1112911993 -0700"
+func ParseSignature(line string) (Signature, error) {
+	lt := strings.IndexByte(line, '<')
+	gt := strings.LastIndexByte(line, '>')
+	if lt < 0 || gt < lt {
+		return Signature{}, fmt.Errorf("malformed identity %q", line)
+	}
+	sig := Signature{
+		Name:  strings.TrimSuffix(line[:lt], " "),
+		Email: line[lt+1 : gt],
+	}
+
+	fields := strings.Fields(line[gt+1:])
+	if len(fields) != 2 {
+		return Signature{}, fmt.Errorf("malformed identity date %q", line)
+	}
+	secs, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Signature{}, fmt.Errorf("malformed timestamp in %q", line)
+	}
+	offset, err := parseTimezone(fields[1])
+	if err != nil {
+		return Signature{}, err
+	}
+	// The zone keeps its original spelling so that e.g. "-0000" round-trips
+	sig.When = time.Unix(secs, 0).In(time.FixedZone(fields[1], offset))
+	sig.raw = line
+	return sig, nil
+}
+
+// parseTimezone converts a "+hhmm" / "-hhmm" offset to seconds east of UTC
+func parseTimezone(tz string) (int, error) {
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') || !isDigits(tz[1:]) {
+		return 0, fmt.Errorf("malformed timezone %q", tz)
+	}
+	hours, _ := strconv.Atoi(tz[1:3])
+	minutes, _ := strconv.Atoi(tz[3:5])
+	offset := hours*3600 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// String formats the signature as it appears in commit and tag headers. A
+// parsed signature whose fields have not changed gives back its raw text.
+func (s Signature) String() string {
+	if s.raw != "" {
+		if parsed, err := ParseSignature(s.raw); err == nil && parsed.sameAs(s) {
+			return s.raw
+		}
+	}
+	return fmt.Sprintf("%s <%s> %d %s", s.Name, s.Email, s.When.Unix(), formatTimezone(s.When))
+}
+
+// sameAs reports whether two signatures hold the same identity and time,
+// including the spelling of the time zone
+func (s Signature) sameAs(other Signature) bool {
+	return s.Name == other.Name && s.Email == other.Email &&
+		s.When.Unix() == other.When.Unix() && formatTimezone(s.When) == formatTimezone(other.When)
+}
+
+// formatTimezone formats the offset of t as "+hhmm", preferring the zone's
+// name when it is already spelled that way
+func formatTimezone(t time.Time) string {
+	name, offset := t.Zone()
+	if parsed, err := parseTimezone(name); err == nil && parsed == offset {
+		return name
+	}
+	return t.Format("-0700")
+}
+
+// Header is an object header line that has no dedicated struct field. Values
+// of multi-line headers contain "\n" between their lines.
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Commit is a parsed commit object. Encode writes the headers of a parsed
+// commit in their original order, so any commit round-trips byte for byte;
+// new commits get the order git itself uses (tree, parents, author,
+// committer, encoding, mergetags, gpgsig, then any other headers).
+type Commit struct {
+	Tree      string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+	// Encoding is the message encoding, empty for UTF-8
+	Encoding string
+	// MergeTags are the tag objects embedded by merges of signed tags
+	MergeTags []string
+	// GPGSig is the commit's signature, without the header's continuation spaces
+	GPGSig       string
+	ExtraHeaders []Header
+	Message      string
+
+	// keys is the header sequence of a parsed commit, nil for a new one
+	keys []string
+}
+
+// ParseCommit parses the content of a commit object
+func ParseCommit(content []byte) (*Commit, error) {
+	headers, message, err := parseHeaders(content)
+	if err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	c := &Commit{Message: message}
+	var hasAuthor, hasCommitter bool
+	for _, h := range headers {
+		c.keys = append(c.keys, h.Key)
+		switch h.Key {
+		case "tree":
+			if c.Tree != "" || !isObjectName(h.Value) {
+				return nil, fmt.Errorf("commit: invalid tree line")
+			}
+			c.Tree = h.Value
+		case "parent":
+			if !isObjectName(h.Value) {
+				return nil, fmt.Errorf("commit: invalid parent line %q", h.Value)
+			}
+			c.Parents = append(c.Parents, h.Value)
+		case "author":
+			if c.Author, err = ParseSignature(h.Value); err != nil {
+				return nil, fmt.Errorf("commit: author: %w", err)
+			}
+			hasAuthor = true
+		case "committer":
+			if c.Committer, err = ParseSignature(h.Value); err != nil {
+				return nil, fmt.Errorf("commit: committer: %w", err)
+			}
+			hasCommitter = true
+		case "encoding":
+			c.Encoding = h.Value
+		case "mergetag":
+			c.MergeTags = append(c.MergeTags, h.Value)
+		case "gpgsig":
+			c.GPGSig = h.Value
+		default:
+			c.ExtraHeaders = append(c.ExtraHeaders, h)
+		}
+	}
+	if c.Tree == "" {
+		return nil, fmt.Errorf("commit: missing tree line")
+	}
+	if !hasAuthor || !hasCommitter {
+		return nil, fmt.Errorf("commit: missing author or committer line")
+	}
+	return c, nil
+}
+
+// Encode serializes the commit into the content of a commit object
+func (c *Commit) Encode() []byte {
+	if buf, ok := c.encodeInOrder(); ok {
+		return buf.Bytes()
+	}
+	var buf bytes.Buffer
+	writeHeader(&buf, "tree", c.Tree)
+	for _, parent := range c.Parents {
+		writeHeader(&buf, "parent", parent)
+	}
+	writeHeader(&buf, "author", c.Author.String())
+	writeHeader(&buf, "committer", c.Committer.String())
+	if c.Encoding != "" {
+		writeHeader(&buf, "encoding", c.Encoding)
+	}
+	for _, tag := range c.MergeTags {
+		writeHeader(&buf, "mergetag", tag)
+	}
+	if c.GPGSig != "" {
+		writeHeader(&buf, "gpgsig", c.GPGSig)
+	}
+	for _, h := range c.ExtraHeaders {
+		writeHeader(&buf, h.Key, h.Value)
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(c.Message)
+	return buf.Bytes()
+}
+
+// encodeInOrder writes the headers of a parsed commit in the sequence they
+// were read in, taking each value from the current fields. It fails when
+// the fields no longer fit that sequence, e.g. after adding a parent.
+func (c *Commit) encodeInOrder() (*bytes.Buffer, bool) {
+	if c.keys == nil {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	var parents, mergeTags, extras int
+	var hasEncoding, hasGPGSig bool
+	for _, key := range c.keys {
+		switch key {
+		case "tree":
+			writeHeader(&buf, key, c.Tree)
+		case "parent":
+			if parents == len(c.Parents) {
+				return nil, false
+			}
+			writeHeader(&buf, key, c.Parents[parents])
+			parents++
+		case "author":
+			writeHeader(&buf, key, c.Author.String())
+		case "committer":
+			writeHeader(&buf, key, c.Committer.String())
+		case "encoding":
+			writeHeader(&buf, key, c.Encoding)
+			hasEncoding = true
+		case "mergetag":
+			if mergeTags == len(c.MergeTags) {
+				return nil, false
+			}
+			writeHeader(&buf, key, c.MergeTags[mergeTags])
+			mergeTags++
+		case "gpgsig":
+			writeHeader(&buf, key, c.GPGSig)
+			hasGPGSig = true
+		default:
+			if extras == len(c.ExtraHeaders) || c.ExtraHeaders[extras].Key != key {
+				return nil, false
+			}
+			writeHeader(&buf, key, c.ExtraHeaders[extras].Value)
+			extras++
+		}
+	}
+	if parents != len(c.Parents) || mergeTags != len(c.MergeTags) || extras != len(c.ExtraHeaders) ||
+		hasEncoding != (c.Encoding != "") || hasGPGSig != (c.GPGSig != "") {
+		return nil, false
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(c.Message)
+	return &buf, true
+}
+
+// ReadCommit reads and parses a commit object
+func (r *Repository) ReadCommit(hash string) (*Commit, error) {
+	content, err := r.readTypedObject(hash, "commit")
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseCommit(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", hash, err)
+	}
+	return c, nil
+}
+
+// WriteCommit stores a commit object and returns its hash
+func (r *Repository) WriteCommit(c *Commit) (string, error) {
+	hash, _, err := r.WriteObject("commit", c.Encode())
+	return hash, err
+}
+
+// aheadBehindSlop is how many commits AheadBehind walks past the point
+// where only shared history seems to be left, like git's SLOP
+const aheadBehindSlop = 5
+
+// Sides of an ahead/behind walk a commit is reachable from
+const (
+	sideOurs   = 1
+	sideTheirs = 2
+	sideBoth   = sideOurs | sideTheirs
+)
+
+// AheadBehind counts the commits reachable from ours but not theirs, and
+// from theirs but not ours. Like git's merge-base walk it visits both
+// histories at once, newest commit first, and stops once every commit left
+// to visit is reachable from both sides, since so are their ancestors.
+func (r *Repository) AheadBehind(ours, theirs string) (ahead, behind int, err error) {
+	sides := make(map[string]int)
+	walked := make(map[string]*Commit)
+	queue := &commitQueue{}
+	// mark records that hash is reachable from side. A commit not seen
+	// before is queued; one already walked passes side on to its parents
+	// at once, like git marking the parents of a commit uninteresting.
+	mark := func(hash string, side int) error {
+		stack := []string{hash}
+		for len(stack) > 0 {
+			hash := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			seen := sides[hash] != 0
+			if sides[hash]&side == side {
+				continue
+			}
+			sides[hash] |= side
+			if c, ok := walked[hash]; ok {
+				stack = append(stack, c.Parents...)
+			} else if !seen {
+				c, err := r.ReadCommit(hash)
+				if err != nil {
+					return err
+				}
+				queue.push(hash, c)
+			}
+		}
+		return nil
+	}
+	if err := mark(ours, sideOurs); err != nil {
+		return 0, 0, err
+	}
+	if err := mark(theirs, sideTheirs); err != nil {
+		return 0, 0, err
+	}
+
+	// A commit reachable from both sides may still have one-sided commits
+	// among its ancestors if they were walked first. With sane dates they
+	// are newer than it, so the walk ends once everything queued is shared
+	// and older than every one-sided commit; like git it then goes on for a
+	// few more commits in case of clock skew.
+	oneSided := int64(math.MaxInt64)
+	slop := aheadBehindSlop
+	for queue.Len() > 0 {
+		if !queue.shared(sides) || queue.newest() >= oneSided {
+			slop = aheadBehindSlop
+		} else if slop--; slop == 0 {
+			break
+		}
+		entry := queue.pop()
+		walked[entry.hash] = entry.commit
+		if sides[entry.hash] != sideBoth {
+			oneSided = min(oneSided, entry.commit.Committer.When.Unix())
+		}
+		for _, parent := range entry.commit.Parents {
+			if err := mark(parent, sides[entry.hash]); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	for _, side := range sides {
+		switch side {
+		case sideOurs:
+			ahead++
+		case sideTheirs:
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// queuedCommit is an entry of a commitQueue
+type queuedCommit struct {
+	hash   string
+	commit *Commit
+	seq    int
+}
+
+// commitQueue hands out commits newest first by committer date, and in the
+// order they were queued when the dates are equal
+type commitQueue struct {
+	entries []queuedCommit
+	seq     int
+}
+
+func (q *commitQueue) Len() int { return len(q.entries) }
+
+func (q *commitQueue) Less(i, j int) bool {
+	a, b := q.entries[i], q.entries[j]
+	if ta, tb := a.commit.Committer.When.Unix(), b.commit.Committer.When.Unix(); ta != tb {
+		return ta > tb
+	}
+	return a.seq < b.seq
+}
+
+func (q *commitQueue) Swap(i, j int) { q.entries[i], q.entries[j] = q.entries[j], q.entries[i] }
+
+func (q *commitQueue) Push(x any) { q.entries = append(q.entries, x.(queuedCommit)) }
+
+func (q *commitQueue) Pop() any {
+	last := q.entries[len(q.entries)-1]
+	q.entries = q.entries[:len(q.entries)-1]
+	return last
+}
+
+func (q *commitQueue) push(hash string, c *Commit) {
+	q.seq++
+	heap.Push(q, queuedCommit{hash: hash, commit: c, seq: q.seq})
+}
+
+func (q *commitQueue) pop() queuedCommit {
+	return heap.Pop(q).(queuedCommit)
+}
+
+// newest returns the committer date of the next commit pop returns
+func (q *commitQueue) newest() int64 {
+	return q.entries[0].commit.Committer.When.Unix()
+}
+
+// shared reports whether every queued commit is reachable from both sides,
+// which an empty queue trivially is
+func (q *commitQueue) shared(sides map[string]int) bool {
+	for _, e := range q.entries {
+		if sides[e.hash] != sideBoth {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHeaders splits commit or tag content into its headers and message.
+// Lines starting with a space continue the previous header's value.
+func parseHeaders(content []byte) ([]Header, string, error) {
+	header, message, found := strings.Cut(string(content), "\n\n")
+	if !found {
+		if !strings.HasSuffix(header, "\n") {
+			return nil, "", fmt.Errorf("unterminated header")
+		}
+		header = strings.TrimSuffix(header, "\n")
+	}
+
+	var headers []Header
+	for _, line := range strings.Split(header, "\n") {
+		if rest, ok := strings.CutPrefix(line, " "); ok {
+			if len(headers) == 0 {
+				return nil, "", fmt.Errorf("continuation line without a header")
+			}
+			headers[len(headers)-1].Value += "\n" + rest
+			continue
+		}
+		key, value, ok := strings.Cut(line, " ")
+		if !ok || key == "" {
+			return nil, "", fmt.Errorf("malformed header line %q", line)
+		}
+		headers = append(headers, Header{Key: key, Value: value})
+	}
+	return headers, message, nil
+}
+
+// writeHeader writes a header line, indenting the continuation lines of
+// multi-line values
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	buf.WriteByte(' ')
+	buf.WriteString(strings.ReplaceAll(value, "\n", "\n "))
+	buf.WriteByte('\n')
+}
+
+// isObjectName reports whether s is a 40-char lowercase hex object name
+func isObjectName(s string) bool {
+	return len(s) == 40 && isHex(s)
+}
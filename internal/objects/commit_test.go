@@ -0,0 +1,66 @@
+package objects
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAheadBehind(t *testing.T) {
+	// root - a1 - a2       ours
+	//      \    \
+	//       b1 - m         theirs
+	graph := []struct {
+		name    string
+		parents []string
+	}{
+		{"root", nil},
+		{"a1", []string{"root"}},
+		{"a2", []string{"a1"}},
+		{"b1", []string{"root"}},
+		{"m", []string{"b1", "a1"}},
+	}
+	tests := []struct {
+		name          string
+		dates         map[string]int64
+		ours, theirs  string
+		ahead, behind int
+	}{
+		{"diverged", nil, "a2", "m", 1, 2},
+		{"swapped", nil, "m", "a2", 2, 1},
+		{"behind", nil, "a1", "m", 0, 2},
+		{"same", nil, "a2", "a2", 0, 0},
+		{"equal dates", map[string]int64{"root": 5, "a1": 5, "a2": 5, "b1": 5, "m": 5}, "a2", "m", 1, 2},
+		{"clock skew", map[string]int64{"root": 9, "a1": 1}, "a2", "m", 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMemoryRepository("")
+			hashes := make(map[string]string)
+			for i, c := range graph {
+				date, ok := tt.dates[c.name]
+				if !ok {
+					date = int64(i)
+				}
+				var content strings.Builder
+				content.WriteString("tree " + emptyTree + "\n")
+				for _, p := range c.parents {
+					content.WriteString("parent " + hashes[p] + "\n")
+				}
+				fmt.Fprintf(&content, "author A <a@b> %d +0000\ncommitter A <a@b> %d +0000\n\n%s\n", date, date, c.name)
+				hash, _, err := repo.WriteObject("commit", []byte(content.String()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				hashes[c.name] = hash
+			}
+			ahead, behind, err := repo.AheadBehind(hashes[tt.ours], hashes[tt.theirs])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ahead != tt.ahead || behind != tt.behind {
+				t.Errorf("AheadBehind(%s, %s) = %d, %d, want %d, %d", tt.ours, tt.theirs, ahead, behind, tt.ahead, tt.behind)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/master-wayne7/go-git/internal/config"
+)
+
+// ErrNoIdentity is returned when no name or email is configured for the
+// author or committer of a new object
+var ErrNoIdentity = errors.New("identity unknown")
+
+// Config loads the system, global and repository configuration
+func (r *Repository) Config() (*config.Config, error) {
+	return config.Load(r.GitDir)
+}
+
+// AuthorIdent returns the author of new commits, from GIT_AUTHOR_NAME,
+// GIT_AUTHOR_EMAIL and GIT_AUTHOR_DATE or the author.* / user.* config
+func (r *Repository) AuthorIdent() (Signature, error) {
+	return r.ident("AUTHOR", "author")
+}
+
+// CommitterIdent returns the committer of new commits and the tagger of new
+// tags, from GIT_COMMITTER_NAME, GIT_COMMITTER_EMAIL and GIT_COMMITTER_DATE
+// or the committer.* / user.* config
+func (r *Repository) CommitterIdent() (Signature, error) {
+	return r.ident("COMMITTER", "committer")
+}
+
+// ident resolves a name, email and date: environment variables first, then
+// <role>.name / <role>.email, then user.name / user.email, then $EMAIL
+func (r *Repository) ident(envRole, role string) (Signature, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return Signature{}, err
+	}
+	lookup := func(env string, keys ...string) string {
+		if value := os.Getenv(env); value != "" {
+			return value
+		}
+		for _, key := range keys {
+			if value, ok := cfg.Get(key); ok && value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	sig := Signature{
+		Name:  lookup("GIT_"+envRole+"_NAME", role+".name", "user.name"),
+		Email: lookup("GIT_"+envRole+"_EMAIL", role+".email", "user.email"),
+	}
+	if sig.Email == "" {
+		sig.Email = os.Getenv("EMAIL")
+	}
+	if sig.Name == "" || sig.Email == "" {
+		return Signature{}, fmt.Errorf("%s %w: set user.name and user.email in the repository or global config, or GIT_%s_NAME and GIT_%s_EMAIL", role, ErrNoIdentity, envRole, envRole)
+	}
+	if strings.ContainsAny(sig.Name+sig.Email, "<>\n") {
+		return Signature{}, fmt.Errorf("invalid %s identity %q <%s>", role, sig.Name, sig.Email)
+	}
+
+	sig.When = time.Now()
+	if date := os.Getenv("GIT_" + envRole + "_DATE"); date != "" {
+		if sig.When, err = ParseDate(date); err != nil {
+			return Signature{}, fmt.Errorf("invalid GIT_%s_DATE: %w", envRole, err)
+		}
+	}
+	return sig, nil
+}
+
+// dateLayouts are the RFC 2822 and ISO 8601 forms accepted by ParseDate
+var dateLayouts = []string{
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"Mon Jan 2 15:04:05 2006 -0700",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05-0700",
+}
+
+// localDateLayouts are ISO 8601 forms without a zone, read as local time
+var localDateLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006.01.02 15:04:05",
+}
+
+// ParseDate parses the date formats git accepts for GIT_AUTHOR_DATE and
+// GIT_COMMITTER_DATE: its internal "<unix> <+hhmm>" (optionally "@<unix>"),
+// RFC 2822 and ISO 8601
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	// internal format: "<unix> <tz>", "@<unix> <tz>" or "@<unix>"
+	fields := strings.Fields(value)
+	if len(fields) >= 1 && len(fields) <= 2 {
+		unix := strings.TrimPrefix(fields[0], "@")
+		if secs, err := strconv.ParseInt(unix, 10, 64); err == nil && (len(fields) == 2 || unix != fields[0]) {
+			if len(fields) == 1 {
+				return time.Unix(secs, 0).UTC(), nil
+			}
+			offset, err := parseTimezone(fields[1])
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(secs, 0).In(time.FixedZone(fields[1], offset)), nil
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range localDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date format %q", value)
+}
@@ -0,0 +1,78 @@
+package objects
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// memoryObject is an object held by a MemoryStore
+type memoryObject struct {
+	objType string
+	content []byte
+}
+
+// MemoryStore keeps objects in memory, for tests and ephemeral repositories
+// that should never touch disk. It is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+// NewMemoryStore returns an empty in-memory object store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]memoryObject)}
+}
+
+// Has reports whether the object exists in the store
+func (s *MemoryStore) Has(hash string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.objects[hash]
+	return ok
+}
+
+// Read returns the object's type and content
+func (s *MemoryStore) Read(hash string) (string, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[hash]
+	if !ok {
+		return "", nil, fmt.Errorf("object %s: %w", hash, ErrObjectNotFound)
+	}
+	return obj.objType, obj.content, nil
+}
+
+// Write stores a copy of content and returns the object's hex SHA
+func (s *MemoryStore) Write(objType string, content []byte) (string, error) {
+	hexStr, _ := hashObject(objType, content)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[hexStr]; !ok {
+		s.objects[hexStr] = memoryObject{
+			objType: objType,
+			content: append([]byte(nil), content...),
+		}
+	}
+	return hexStr, nil
+}
+
+// Iterate calls fn for every object in hash order. The store is snapshotted
+// first, so fn may write new objects.
+func (s *MemoryStore) Iterate(fn func(hash string) error) error {
+	s.mu.RLock()
+	hashes := make([]string, 0, len(s.objects))
+	for hash := range s.objects {
+		hashes = append(hashes, hash)
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		if err := fn(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
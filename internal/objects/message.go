@@ -0,0 +1,30 @@
+package objects
+
+import "strings"
+
+// CleanupMessage tidies a commit or tag message the way git's default
+// "strip" cleanup does: trailing whitespace is removed from every line,
+// runs of blank lines collapse to one, leading and trailing blank lines are
+// dropped and a non-empty message ends with a newline. With stripComments,
+// lines starting with '#' are removed first.
+func CleanupMessage(message string, stripComments bool) string {
+	var out strings.Builder
+	blank := false
+	for _, line := range strings.Split(message, "\n") {
+		if stripComments && strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r\v\f")
+		if line == "" {
+			blank = out.Len() > 0
+			continue
+		}
+		if blank {
+			out.WriteByte('\n')
+			blank = false
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
@@ -2,115 +2,201 @@ package objects
 
 import (
 	"bytes"
-	"compress/zlib"
 	"crypto/sha1"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strconv"
-	"time"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/config"
+	"github.com/master-wayne7/go-git/internal/ignore"
 )
 
-// ReadObject reads a Git object from the objects directory
-func ReadObject(hash string) ([]byte, error) {
-	data, err := os.ReadFile(".git/objects/" + hash[:2] + "/" + hash[2:])
+// Object is a git object as read from an object store
+type Object struct {
+	Hash    string
+	Type    string
+	Size    int64
+	Content []byte
+}
+
+// ReadObject reads a Git object from the repository's object store,
+// returning its type and size along with the content
+func (r *Repository) ReadObject(hash string) (*Object, error) {
+	objType, content, err := r.Objects.Read(hash)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading file: %s\n", err)
 		return nil, err
 	}
-	r, err := zlib.NewReader(bytes.NewReader(data))
+	return &Object{
+		Hash:    hash,
+		Type:    objType,
+		Size:    int64(len(content)),
+		Content: content,
+	}, nil
+}
+
+// PeelTag follows annotated tags until it reaches an object that is not a
+// tag and returns that object's hash
+func (r *Repository) PeelTag(hash string) (string, error) {
+	for depth := 0; ; depth++ {
+		obj, err := r.ReadObject(hash)
+		if err != nil {
+			return "", err
+		}
+		if obj.Type != "tag" {
+			return hash, nil
+		}
+		if depth > 100 {
+			return "", fmt.Errorf("tag chain too deep at %s", hash)
+		}
+		tag, err := ParseTag(obj.Content)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", hash, err)
+		}
+		hash = tag.Object
+	}
+}
+
+// readTypedObject reads an object and checks that it has the expected type
+func (r *Repository) readTypedObject(hash, objType string) ([]byte, error) {
+	obj, err := r.ReadObject(hash)
 	if err != nil {
-		fmt.Printf("Error: %s", err.Error())
 		return nil, err
 	}
-	var out bytes.Buffer
-	io.Copy(&out, r)
-	r.Close()
-	outBytes := out.Bytes()
-	if i := bytes.IndexByte(outBytes, '\x00'); i != -1 {
-		return outBytes[i+1:], nil
+	if obj.Type != objType {
+		return nil, fmt.Errorf("object %s is a %s, not a %s", hash, obj.Type, objType)
 	}
-	return nil, errors.New("file empty")
+	return obj.Content, nil
 }
 
 // WriteObject writes an object of type objType ("blob", "tree", "commit", "tag") with the provided
 // content (already the raw content for the object, e.g. blob bytes or tree payload).
 // It returns the 40-char hex SHA, the raw 20-byte SHA, or an error.
-func WriteObject(objType string, content []byte) (string, [20]byte, error) {
-	header := objType + " " + strconv.Itoa(len(content)) + "\x00"
-
-	hasher := sha1.New()
-	_, _ = hasher.Write([]byte(header))
-	_, _ = hasher.Write(content)
-	sum := hasher.Sum(nil)
-
+func (r *Repository) WriteObject(objType string, content []byte) (string, [20]byte, error) {
+	hexStr, err := r.Objects.Write(objType, content)
+	if err != nil {
+		return "", [20]byte{}, err
+	}
 	var raw [20]byte
-	copy(raw[:], sum)
-	hexStr := hex.EncodeToString(sum)
+	hex.Decode(raw[:], []byte(hexStr))
+	return hexStr, raw, nil
+}
 
-	var buf bytes.Buffer
-	w := zlib.NewWriter(&buf)
-	if _, err := w.Write([]byte(header)); err != nil {
-		return "", raw, err
-	}
-	if _, err := w.Write(content); err != nil {
-		return "", raw, err
+// HashObject computes the hash of a file as an object of objType and
+// optionally writes it to the object store. Blobs are streamed from disk so
+// arbitrarily large files never have to fit in memory.
+func (r *Repository) HashObject(file string, objType string, write bool) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", fmt.Errorf("error reading file: %w", err)
 	}
-	if err := w.Close(); err != nil {
-		return "", raw, err
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("error reading file: %w", err)
 	}
-	objectsDir := filepath.Join(".git", "objects", hexStr[:2])
-	if err := os.MkdirAll(objectsDir, 0755); err != nil {
-		return "", raw, err
+	if info.IsDir() {
+		return "", fmt.Errorf("error reading file: %s is a directory", file)
 	}
+	return r.HashStream(objType, f, info.Size(), write)
+}
 
-	// fixed: write the file under the remaining hex chars (not the prefix again)
-	objectPath := filepath.Join(objectsDir, hexStr[2:])
-	if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-		if err := os.WriteFile(objectPath, buf.Bytes(), 0644); err != nil {
-			return "", raw, err
+// HashStream hashes the content read from rd as an object of objType and
+// optionally writes it to the object store. size is the exact content
+// length, or -1 if unknown, in which case a blob is first copied to a
+// temporary file to learn the length its header needs. Non-blob content is
+// read into memory and validated before it is hashed.
+func (r *Repository) HashStream(objType string, rd io.Reader, size int64, write bool) (string, error) {
+	if objType == "blob" && size < 0 {
+		tmp, err := os.CreateTemp("", "mygit-stream-")
+		if err != nil {
+			return "", err
 		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if size, err = io.Copy(tmp, rd); err != nil {
+			return "", fmt.Errorf("error reading input: %w", err)
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		rd = tmp
 	}
-	return hexStr, raw, nil
-}
 
-// HashObject computes the hash for a file and optionally writes it to objects
-func HashObject(file string, write bool) (string, error) {
-	data, err := os.ReadFile(file)
-	if err != nil {
-		return "", fmt.Errorf("error reading file: %w", err)
+	if objType != "blob" {
+		data, err := io.ReadAll(rd)
+		if err != nil {
+			return "", fmt.Errorf("error reading input: %w", err)
+		}
+		if err := ValidateObject(objType, data); err != nil {
+			return "", fmt.Errorf("corrupt %s: %w", objType, err)
+		}
+		if !write {
+			hexStr, _ := hashObject(objType, data)
+			return hexStr, nil
+		}
+		hexStr, _, err := r.WriteObject(objType, data)
+		if err != nil {
+			return "", fmt.Errorf("error writing %s object: %w", objType, err)
+		}
+		return hexStr, nil
 	}
 
-	// create header that must be included in the SHA computation
-	header := "blob " + strconv.Itoa(len(data)) + "\x00"
-
 	// compute SHA over header + data (before compression)
-	hasher := sha1.New()
-	_, _ = hasher.Write([]byte(header))
-	_, _ = hasher.Write(data)
-	sha1HashBytes := hasher.Sum(nil)
-	sha1HexString := hex.EncodeToString(sha1HashBytes)
+	if !write {
+		hasher := sha1.New()
+		io.WriteString(hasher, objectHeader(objType, size))
+		if _, err := io.CopyN(hasher, rd, size); err != nil {
+			return "", fmt.Errorf("error reading input: %w", err)
+		}
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}
 
-	// write compressed object only when -w was passed, reuse WriteObject
-	if write {
-		if _, _, err := WriteObject("blob", data); err != nil {
-			return "", fmt.Errorf("error writing blob object: %w", err)
+	if sw, ok := r.Objects.(StreamWriter); ok {
+		hexStr, err := sw.WriteStream(objType, size, rd)
+		if err != nil {
+			return "", fmt.Errorf("error writing %s object: %w", objType, err)
 		}
+		return hexStr, nil
 	}
 
-	return sha1HexString, nil
+	data, err := io.ReadAll(io.LimitReader(rd, size))
+	if err != nil {
+		return "", fmt.Errorf("error reading input: %w", err)
+	}
+	if int64(len(data)) != size {
+		return "", fmt.Errorf("error reading input: expected %d bytes, got %d", size, len(data))
+	}
+	hexStr, _, err := r.WriteObject(objType, data)
+	if err != nil {
+		return "", fmt.Errorf("error writing %s object: %w", objType, err)
+	}
+	return hexStr, nil
 }
 
-// CatFile prints the content of a Git object
-func CatFile(hash string) error {
-	content, err := ReadObject(hash)
+// CatFile pretty-prints a Git object: trees are listed like ls-tree,
+// blobs, commits and tags are printed as-is
+func (r *Repository) CatFile(hash string) error {
+	obj, err := r.ReadObject(hash)
 	if err != nil {
 		return err
 	}
-	fmt.Print(string(content))
+
+	if obj.Type == "tree" {
+		entries, err := ParseTreePayload(obj.Content)
+		if err != nil {
+			return fmt.Errorf("error parsing tree %s: %w", hash, err)
+		}
+		for _, entry := range entries {
+			fmt.Printf("%s %s %s\t%s\n", entry.Mode, entry.Type, entry.Hash, entry.Name)
+		}
+		return nil
+	}
+
+	os.Stdout.Write(obj.Content)
 	return nil
 }
 
@@ -123,12 +209,16 @@ type TreeEntry struct {
 }
 
 // ParseTree parses a tree object and returns its entries
-func ParseTree(hash string) ([]TreeEntry, error) {
-	payload, err := ReadObject(hash)
+func (r *Repository) ParseTree(hash string) ([]TreeEntry, error) {
+	payload, err := r.readTypedObject(hash, "tree")
 	if err != nil {
 		return nil, fmt.Errorf("error reading tree: %w", err)
 	}
+	return ParseTreePayload(payload)
+}
 
+// ParseTreePayload parses the raw content of a tree object
+func ParseTreePayload(payload []byte) ([]TreeEntry, error) {
 	var entries []TreeEntry
 	cursor := 0
 
@@ -136,7 +226,7 @@ func ParseTree(hash string) ([]TreeEntry, error) {
 		// parse mode (bytes until space)
 		spaceIdx := bytes.IndexByte(payload[cursor:], ' ')
 		if spaceIdx == -1 {
-			break
+			return nil, fmt.Errorf("malformed tree entry at byte %d: missing mode", cursor)
 		}
 
 		modeBytes := payload[cursor : cursor+spaceIdx]
@@ -145,7 +235,7 @@ func ParseTree(hash string) ([]TreeEntry, error) {
 
 		nullIdx := bytes.IndexByte(payload[cursor:], '\x00')
 		if nullIdx == -1 {
-			break
+			return nil, fmt.Errorf("malformed tree entry at byte %d: missing name", cursor)
 		}
 
 		name := string(payload[cursor : cursor+nullIdx])
@@ -153,7 +243,7 @@ func ParseTree(hash string) ([]TreeEntry, error) {
 
 		// reading 20 bytes raw sha of tree
 		if len(payload)-cursor < 20 {
-			break
+			return nil, fmt.Errorf("malformed tree entry %q: truncated hash", name)
 		}
 
 		shaRaw := payload[cursor : cursor+20]
@@ -162,10 +252,14 @@ func ParseTree(hash string) ([]TreeEntry, error) {
 
 		objType := "blob"
 		modeOut := modeStr
-		if modeStr == "40000" {
+		switch modeStr {
+		case "40000":
 			objType = "tree"
 			// Git typically prints tree mode as 040000
 			modeOut = "040000"
+		case "160000":
+			// gitlinks point at a commit in a submodule
+			objType = "commit"
 		}
 
 		entries = append(entries, TreeEntry{
@@ -180,8 +274,8 @@ func ParseTree(hash string) ([]TreeEntry, error) {
 }
 
 // LsTree lists the contents of a tree object
-func LsTree(hash string, nameOnly bool) error {
-	entries, err := ParseTree(hash)
+func (r *Repository) LsTree(hash string, nameOnly bool) error {
+	entries, err := r.ParseTree(hash)
 	if err != nil {
 		return err
 	}
@@ -197,213 +291,231 @@ func LsTree(hash string, nameOnly bool) error {
 	return nil
 }
 
-// WriteTreeEntry represents an entry for building a tree
-type WriteTreeEntry struct {
-	Mode   string
-	Name   string
-	ShaRaw [20]byte
-	IsTree bool
-}
-
 // WriteTree builds a tree object for the directory 'dir' (recursively),
-// writes any needed blob/tree objects to .git/objects and returns the
-// 40-char hex SHA of the created tree.
-func WriteTree(dir string) (string, error) {
-	entries, err := os.ReadDir(dir)
+// writes any needed blob/tree objects to the repository and returns the
+// 40-char hex SHA of the created tree. Like git, it leaves out empty
+// directories and ignored files, and records nested repositories as
+// gitlinks to their HEAD.
+func (r *Repository) WriteTree(dir string) (string, error) {
+	var cfg *config.Config
+	if !r.InMemory() {
+		var err error
+		if cfg, err = r.Config(); err != nil {
+			return "", err
+		}
+	}
+	// .gitignore patterns are relative to the work tree, so a directory
+	// below it is matched by its path from there
+	root, rel, err := r.workTreePath(dir)
 	if err != nil {
 		return "", err
 	}
-
-	var names []string
-	for _, e := range entries {
-		if e.Name() == ".git" {
-			continue
-		}
-		names = append(names, e.Name())
+	m, err := ignore.NewMatcher(root, r.GitDir, cfg)
+	if err != nil {
+		return "", err
 	}
+	hash, _, err := r.writeTreeDir(m, dir, rel)
+	return hash, err
+}
 
-	// Sort names for consistent tree ordering
-	for i := 0; i < len(names); i++ {
-		for j := i + 1; j < len(names); j++ {
-			if names[i] > names[j] {
-				names[i], names[j] = names[j], names[i]
-			}
-		}
+// workTreePath returns the directory ignore rules for dir are rooted at and
+// the slash-separated path of dir below it ("" or ending in "/"): the work
+// tree when dir is inside it, dir itself otherwise
+func (r *Repository) workTreePath(dir string) (root, rel string, err error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if r.WorkTree == "" {
+		return abs, "", nil
+	}
+	root, err = filepath.Abs(r.WorkTree)
+	if err != nil {
+		return "", "", err
+	}
+	rel, err = filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs, "", nil
 	}
+	if rel == "." {
+		return root, "", nil
+	}
+	return root, filepath.ToSlash(rel) + "/", nil
+}
 
-	var payload bytes.Buffer
+// writeTreeDir writes the tree for dir, whose slash-separated path below
+// the top directory is rel, and reports whether it has entries
+func (r *Repository) writeTreeDir(m *ignore.Matcher, dir, rel string) (string, bool, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false, err
+	}
 
-	for _, name := range names {
-		full := filepath.Join(dir, name)
-		info, err := os.Lstat(full)
+	builder, err := r.NewTreeBuilder("")
+	if err != nil {
+		return "", false, err
+	}
+	count := 0
+	for _, e := range dirEntries {
+		if e.Name() == ".git" || m.Ignored(rel+e.Name(), e.IsDir()) {
+			continue
+		}
+		full := filepath.Join(dir, e.Name())
+		mode, hash, ok, err := r.writeTreeEntry(m, full, rel+e.Name())
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
-
-		var mode string
-		var shaRaw [20]byte
-
-		if info.IsDir() {
-			// create subtree and get its hex SHA
-			subHex, err := WriteTree(full)
-			if err != nil {
-				return "", err
-			}
-			shaBytes, err := hex.DecodeString(subHex)
-			if err != nil {
-				return "", err
-			}
-			copy(shaRaw[:], shaBytes)
-			mode = "40000"
-		} else if info.Mode()&os.ModeSymlink != 0 {
-			linkTarget, err := os.Readlink(full)
-			if err != nil {
-				return "", err
-			}
-			_, raw, err := WriteObject("blob", []byte(linkTarget))
-			if err != nil {
-				return "", err
-			}
-			shaRaw = raw
-			mode = "120000"
-		} else {
-			// regular file -> create blob object
-			data, err := os.ReadFile(full)
-			if err != nil {
-				return "", err
-			}
-			_, raw, err := WriteObject("blob", data)
-			if err != nil {
-				return "", err
-			}
-			shaRaw = raw
-
-			// determine file mode (executable or not)
-			if info.Mode()&0111 != 0 {
-				mode = "100755"
-			} else {
-				mode = "100644"
-			}
+		if !ok {
+			continue
 		}
-
-		// append entry: "<mode> <name>\x00<20_byte_sha>"
-		payload.WriteString(mode)
-		payload.WriteByte(' ')
-		payload.WriteString(name)
-		payload.WriteByte(0)
-		payload.Write(shaRaw[:])
+		if err := builder.Insert(e.Name(), mode, hash); err != nil {
+			return "", false, err
+		}
+		count++
 	}
 
-	// write tree object using WriteObject helper
-	treeHex, _, err := WriteObject("tree", payload.Bytes())
+	treeHex, err := builder.Write()
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
-	return treeHex, nil
+	return treeHex, count > 0, nil
 }
 
-// CommitTree creates a commit object
-func CommitTree(treeSha string, parentSha string, message string) (string, error) {
-	var payload bytes.Buffer
+// writeTreeEntry stores the object for one path and returns its mode and
+// hash; ok is false for paths that are left out of the tree
+func (r *Repository) writeTreeEntry(m *ignore.Matcher, full, rel string) (mode, hash string, ok bool, err error) {
+	info, err := os.Lstat(full)
+	if err != nil {
+		return "", "", false, err
+	}
 
-	// tree line
-	payload.WriteString("tree " + treeSha + "\n")
+	switch {
+	case info.IsDir() && isNestedRepository(full):
+		// a nested repository becomes a gitlink to its checked out commit
+		sub, err := OpenRepository(full)
+		if err != nil {
+			return "", "", false, err
+		}
+		if hash, err = sub.Refs.Resolve("HEAD"); err != nil {
+			return "", "", false, fmt.Errorf("'%s' does not have a commit checked out", full)
+		}
+		mode = "160000"
+	case info.IsDir():
+		// create subtree and get its hex SHA
+		var nonEmpty bool
+		if hash, nonEmpty, err = r.writeTreeDir(m, full, rel+"/"); err != nil || !nonEmpty {
+			return "", "", false, err
+		}
+		mode = "40000"
+	case info.Mode()&os.ModeSymlink != 0:
+		linkTarget, err := os.Readlink(full)
+		if err != nil {
+			return "", "", false, err
+		}
+		if hash, _, err = r.WriteObject("blob", []byte(linkTarget)); err != nil {
+			return "", "", false, err
+		}
+		mode = "120000"
+	default:
+		// regular file -> create blob object
+		data, err := os.ReadFile(full)
+		if err != nil {
+			return "", "", false, err
+		}
+		if hash, _, err = r.WriteObject("blob", data); err != nil {
+			return "", "", false, err
+		}
 
-	// optional parent line
-	if parentSha != "" {
-		payload.WriteString("parent " + parentSha + "\n")
+		// determine file mode (executable or not)
+		if info.Mode()&0111 != 0 {
+			mode = "100755"
+		} else {
+			mode = "100644"
+		}
 	}
+	return mode, hash, true, nil
+}
 
-	// author / committer (hardcoded name/email allowed)
-	// Import time package for this
-	now := time.Now()
-	ts := now.Unix()
-	_, offset := now.Zone()
-	sign := '+'
-	if offset < 0 {
-		sign = '-'
-		offset = -offset
-	}
-	h := offset / 3600
-	m := (offset % 3600) / 60
-	tz := fmt.Sprintf("%c%02d%02d", sign, h, m)
+// isNestedRepository reports whether dir is the work tree of another
+// repository, i.e. contains a .git directory or a .git file
+func isNestedRepository(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, ".git"))
+	return err == nil
+}
 
-	author := "Ronit Rameja <ronitrameja28@gmail.com>"
-	payload.WriteString(fmt.Sprintf("author %s %d %s\n", author, ts, tz))
-	payload.WriteString(fmt.Sprintf("committer %s %d %s\n", author, ts, tz))
+// CommitTree creates a commit object for a tree with zero (root commit), one
+// or several (merge) parents. The message is stored exactly as given.
+func (r *Repository) CommitTree(treeSha string, parents []string, message string) (string, error) {
+	commit := &Commit{
+		Tree:    treeSha,
+		Parents: parents,
+		Message: message,
+	}
 
-	// blank line then commit message
-	payload.WriteByte('\n')
-	payload.WriteString(message)
-	payload.WriteByte('\n')
+	var err error
+	if commit.Author, err = r.AuthorIdent(); err != nil {
+		return "", err
+	}
+	if commit.Committer, err = r.CommitterIdent(); err != nil {
+		return "", err
+	}
 
 	// write commit object
-	commitHex, _, err := WriteObject("commit", payload.Bytes())
+	commitHex, err := r.WriteCommit(commit)
 	if err != nil {
 		return "", fmt.Errorf("error writing commit object: %w", err)
 	}
 	return commitHex, nil
 }
 
-// CheckoutTree recursively checks out a tree object to the filesystem
-func CheckoutTree(treeHash string, basePath string) error {
-	payload, err := ReadObject(treeHash)
+// CheckoutTree recursively checks out a tree object to the filesystem. A
+// missing or unreadable object is an error, as is an entry name that could
+// escape the directory or write into the repository (".", "..", ".git" or a
+// name containing "/").
+func (r *Repository) CheckoutTree(treeHash string, basePath string) error {
+	entries, err := r.ParseTree(treeHash)
 	if err != nil {
-		// ### CHANGE THIS ### - Skip missing objects (likely deltas that weren't resolved)
-		fmt.Printf("Warning: Skipping missing object %s in path %s\n", treeHash, basePath)
-		return nil
+		return fmt.Errorf("checkout %s: %w", basePath, err)
 	}
 
-	cursor := 0
-	for cursor < len(payload) {
-		// Parse mode
-		spaceIdx := bytes.IndexByte(payload[cursor:], ' ')
-		if spaceIdx == -1 {
-			break
-		}
-		modeBytes := payload[cursor : cursor+spaceIdx]
-		mode := string(modeBytes)
-		cursor += spaceIdx + 1
-
-		// Parse name
-		nullIdx := bytes.IndexByte(payload[cursor:], '\x00')
-		if nullIdx == -1 {
-			break
-		}
-		name := string(payload[cursor : cursor+nullIdx])
-		cursor += nullIdx + 1
-
-		// Parse hash
-		if len(payload)-cursor < 20 {
-			break
+	for _, entry := range entries {
+		if !isSafeEntryName(entry.Name) {
+			return fmt.Errorf("checkout %s: invalid path %q in tree %s", basePath, entry.Name, treeHash)
 		}
-		shaRaw := payload[cursor : cursor+20]
-		shaHex := hex.EncodeToString(shaRaw)
-		cursor += 20
+		fullPath := filepath.Join(basePath, entry.Name)
 
-		// Create the file/directory
-		fullPath := filepath.Join(basePath, name)
-
-		if mode == "40000" {
+		switch entry.Mode {
+		case "040000":
 			// Directory - create it and recurse
 			if err := os.MkdirAll(fullPath, 0755); err != nil {
 				return err
 			}
-			if err := CheckoutTree(shaHex, fullPath); err != nil {
+			if err := r.CheckoutTree(entry.Hash, fullPath); err != nil {
 				return err
 			}
-		} else {
+		case "160000":
+			// Gitlink - the submodule is not cloned, so leave an empty directory
+			if err := os.MkdirAll(fullPath, 0755); err != nil {
+				return err
+			}
+		default:
 			// File - read blob and write to filesystem
-			content, err := ReadObject(shaHex)
+			content, err := r.readTypedObject(entry.Hash, "blob")
 			if err != nil {
-				// ### CHANGE THIS ### - Skip missing blob objects
-				fmt.Printf("Warning: Skipping missing blob %s for file %s\n", shaHex, fullPath)
+				return fmt.Errorf("checkout %s: %w", fullPath, err)
+			}
+
+			// Symlinks store their target path as the blob content
+			if entry.Mode == "120000" {
+				if err := os.Symlink(string(content), fullPath); err != nil {
+					return err
+				}
 				continue
 			}
 
 			// Determine file permissions
 			var perm os.FileMode = 0644
-			if mode == "100755" {
+			if entry.Mode == "100755" {
 				perm = 0755
 			}
 
@@ -415,3 +527,10 @@ func CheckoutTree(treeHash string, basePath string) error {
 
 	return nil
 }
+
+// isSafeEntryName reports whether a tree entry name can be checked out: a
+// single path component other than ".", ".." and ".git"
+func isSafeEntryName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.EqualFold(name, ".git") &&
+		!strings.ContainsAny(name, "/\x00")
+}
@@ -0,0 +1,158 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/pack"
+)
+
+// storePack is a packfile opened by a FileStore. users counts the callers
+// reading from it; a pack whose .idx has gone, or that Close dropped, is
+// retired and only closed once the last of them releases it.
+type storePack struct {
+	*pack.Packfile
+	users   int
+	retired bool
+}
+
+// acquire marks packs as in use; the caller must release them. s.packsMu
+// must be held.
+func acquire(packs []*storePack) []*storePack {
+	for _, p := range packs {
+		p.users++
+	}
+	return packs
+}
+
+// releasePacks ends a use of packs, closing those retired in the meantime
+func (s *FileStore) releasePacks(packs []*storePack) {
+	s.packsMu.Lock()
+	defer s.packsMu.Unlock()
+	for _, p := range packs {
+		p.users--
+		if p.retired && p.users == 0 {
+			p.Close()
+		}
+	}
+}
+
+// retire drops p from the store and closes it unless it is in use.
+// s.packsMu must be held.
+func (s *FileStore) retire(idxPath string, p *storePack) error {
+	delete(s.packs, idxPath)
+	p.retired = true
+	if p.users == 0 {
+		return p.Close()
+	}
+	return nil
+}
+
+// loadPacks returns the packfiles found by the last scan of objects/pack,
+// scanning it the first time. The caller must release them.
+func (s *FileStore) loadPacks() ([]*storePack, error) {
+	s.packsMu.Lock()
+	if s.packList != nil {
+		defer s.packsMu.Unlock()
+		return acquire(s.packList), nil
+	}
+	s.packsMu.Unlock()
+	return s.rescanPacks()
+}
+
+// rescanPacks reads objects/pack again: .idx files that have appeared are
+// opened and packs whose .idx has gone are retired. Packs still present
+// stay open. The caller must release the returned packs.
+func (s *FileStore) rescanPacks() ([]*storePack, error) {
+	packDir := filepath.Join(s.Dir, "pack")
+	entries, err := os.ReadDir(packDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	s.packsMu.Lock()
+	defer s.packsMu.Unlock()
+
+	present := make(map[string]bool)
+	packs := []*storePack{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".idx") {
+			continue
+		}
+		idxPath := filepath.Join(packDir, e.Name())
+		present[idxPath] = true
+		p, ok := s.packs[idxPath]
+		if !ok {
+			opened, err := pack.OpenPackfile(idxPath)
+			if err != nil {
+				return nil, err
+			}
+			p = &storePack{Packfile: opened}
+			s.packs[idxPath] = p
+		}
+		packs = append(packs, p)
+	}
+	for idxPath, p := range s.packs {
+		if !present[idxPath] {
+			s.retire(idxPath, p)
+		}
+	}
+	s.packList = packs
+	return acquire(packs), nil
+}
+
+// packsHaving returns the known packs, rescanning objects/pack first when
+// none of them contains hash, since it may be in a pack written since. The
+// caller must release them.
+func (s *FileStore) packsHaving(hash string) ([]*storePack, error) {
+	packs, err := s.loadPacks()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packs {
+		if p.Has(hash) {
+			return packs, nil
+		}
+	}
+	s.releasePacks(packs)
+	return s.rescanPacks()
+}
+
+// Close closes every packfile that is not being read; the others are
+// closed as soon as their readers finish. The store stays usable and
+// reopens packs when it next needs them.
+func (s *FileStore) Close() error {
+	s.packsMu.Lock()
+	defer s.packsMu.Unlock()
+
+	var firstErr error
+	for idxPath, p := range s.packs {
+		if err := s.retire(idxPath, p); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.packList = nil
+	return firstErr
+}
+
+// readPackedObject looks an object up in every pack of the store and
+// returns its type and resolved content
+func (s *FileStore) readPackedObject(hash string) (string, []byte, error) {
+	packs, err := s.packsHaving(hash)
+	if err != nil {
+		return "", nil, err
+	}
+	defer s.releasePacks(packs)
+
+	for _, p := range packs {
+		objType, content, err := p.ReadObject(hash)
+		if errors.Is(err, pack.ErrObjectNotFound) {
+			continue
+		}
+		return objType, content, err
+	}
+	return "", nil, fmt.Errorf("object %s: %w", hash, ErrObjectNotFound)
+}
@@ -0,0 +1,243 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/master-wayne7/go-git/internal/config"
+	"github.com/master-wayne7/go-git/internal/refs"
+)
+
+// ErrNotRepository is returned when no git directory can be found
+var ErrNotRepository = errors.New("not a git repository (or any of the parent directories): .git")
+
+// Repository is a git repository identified by its git directory and, for
+// non-bare repositories, its working tree. All paths are absolute so a
+// Repository can be used without changing the process working directory.
+type Repository struct {
+	// GitDir is the repository's .git directory (or the bare repository root)
+	GitDir string
+	// WorkTree is the root of the working tree, empty for bare repositories
+	WorkTree string
+	// Objects is where the repository's objects are read from and written to
+	Objects ObjectStore
+	// Refs manages HEAD, branches, tags and packed-refs; nil for in-memory repositories
+	Refs *refs.Store
+}
+
+// InitRepository creates an empty repository with a working tree at path,
+// leaving an existing HEAD untouched
+func InitRepository(path string) (*Repository, error) {
+	workTree, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	repo := newRepository(filepath.Join(workTree, ".git"), workTree)
+
+	dirs := []string{
+		repo.GitDir,
+		repo.Path("objects"),
+		repo.Path("objects", "pack"),
+		repo.Path("refs", "heads"),
+		repo.Path("refs", "tags"),
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := os.Stat(repo.Path("HEAD")); os.IsNotExist(err) {
+		if err := repo.Refs.SetSymbolic("HEAD", "refs/heads/main", ""); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := os.Stat(repo.Path("config")); os.IsNotExist(err) {
+		if err := writeDefaultConfig(repo.Path("config")); err != nil {
+			return nil, err
+		}
+	}
+	return repo, nil
+}
+
+// writeDefaultConfig writes the [core] settings of a new non-bare repository
+func writeDefaultConfig(path string) error {
+	f, err := config.OpenFile(path)
+	if err != nil {
+		return err
+	}
+	settings := [][2]string{
+		{"core.repositoryformatversion", "0"},
+		{"core.filemode", "true"},
+		{"core.bare", "false"},
+		{"core.logallrefupdates", "true"},
+	}
+	for _, kv := range settings {
+		if err := f.Set(kv[0], kv[1], false); err != nil {
+			return err
+		}
+	}
+	return f.Save()
+}
+
+// OpenRepository opens the repository at path, which may be either a working
+// tree containing .git or a git directory itself
+func OpenRepository(path string) (*Repository, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dotGit := filepath.Join(abs, ".git")
+	info, err := os.Stat(dotGit)
+	switch {
+	case err == nil && info.IsDir():
+		return newRepository(dotGit, abs), nil
+	case err == nil:
+		// a .git file points at the real git directory ("gitdir: <path>")
+		gitDir, err := readGitFile(dotGit)
+		if err != nil {
+			return nil, err
+		}
+		return newRepository(gitDir, abs), nil
+	}
+
+	if isGitDir(abs) {
+		return newRepository(abs, ""), nil
+	}
+	return nil, fmt.Errorf("%s: %w", path, ErrNotRepository)
+}
+
+// DiscoverRepository finds the repository containing path. GIT_DIR and
+// GIT_WORK_TREE take precedence; otherwise parent directories are searched
+// until a .git directory, .git file or bare repository is found.
+func DiscoverRepository(path string) (*Repository, error) {
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		gitDir, err := filepath.Abs(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		if !isGitDir(gitDir) {
+			return nil, fmt.Errorf("%s: %w", gitDir, ErrNotRepository)
+		}
+		workTree := os.Getenv("GIT_WORK_TREE")
+		if workTree == "" {
+			workTree = path
+		}
+		if workTree, err = filepath.Abs(workTree); err != nil {
+			return nil, err
+		}
+		return newRepository(gitDir, workTree), nil
+	}
+
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if repo, err := OpenRepository(dir); err == nil {
+			return repo, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, ErrNotRepository
+		}
+		dir = parent
+	}
+}
+
+// NewMemoryRepository returns a repository whose objects live only in memory.
+// It has no git directory, so it is suited to building and inspecting
+// objects in tests and ephemeral jobs; workTree may be empty.
+func NewMemoryRepository(workTree string) *Repository {
+	return &Repository{
+		WorkTree: workTree,
+		Objects:  NewMemoryStore(),
+	}
+}
+
+// newRepository builds an on-disk Repository from absolute paths. Like git's
+// core.logAllRefUpdates default, only repositories with a working tree
+// start new reflogs.
+func newRepository(gitDir, workTree string) *Repository {
+	refStore := refs.NewStore(gitDir)
+	refStore.LogAllRefUpdates = workTree != ""
+	repo := &Repository{
+		GitDir:   gitDir,
+		WorkTree: workTree,
+		Objects:  NewFileStore(filepath.Join(gitDir, "objects")),
+		Refs:     refStore,
+	}
+	refStore.Ident = repo.reflogIdent
+	return repo
+}
+
+// reflogIdent is the identity recorded in reflog entries: the committer
+// when one is configured, otherwise git's user@host fallback
+func (r *Repository) reflogIdent() (string, time.Time) {
+	sig, err := r.CommitterIdent()
+	if err != nil {
+		return refs.DefaultIdent()
+	}
+	return fmt.Sprintf("%s <%s>", sig.Name, sig.Email), sig.When
+}
+
+// Close releases what the repository holds open, such as the packfiles of
+// its object store. Services handling many repositories should close each
+// one when done with it.
+func (r *Repository) Close() error {
+	if c, ok := r.Objects.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Path joins elem onto the git directory
+func (r *Repository) Path(elem ...string) string {
+	return filepath.Join(append([]string{r.GitDir}, elem...)...)
+}
+
+// IsBare reports whether the repository has no working tree
+func (r *Repository) IsBare() bool {
+	return r.WorkTree == ""
+}
+
+// InMemory reports whether the repository has no git directory on disk
+func (r *Repository) InMemory() bool {
+	return r.GitDir == ""
+}
+
+// isGitDir reports whether dir looks like a git directory
+func isGitDir(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// readGitFile resolves a .git file of the form "gitdir: <path>"
+func readGitFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir: ") {
+		return "", fmt.Errorf("invalid gitfile format: %s", path)
+	}
+	gitDir := strings.TrimPrefix(line, "gitdir: ")
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	if !isGitDir(gitDir) {
+		return "", fmt.Errorf("%s: %w", gitDir, ErrNotRepository)
+	}
+	return filepath.Clean(gitDir), nil
+}
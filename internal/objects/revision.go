@@ -0,0 +1,420 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/master-wayne7/go-git/internal/refs"
+)
+
+// minAbbrev is the shortest abbreviated object name that is accepted, and
+// the length that abbreviations start from
+const minAbbrev = 4
+
+var (
+	// ErrUnknownRevision is returned when a revision names no object
+	ErrUnknownRevision = errors.New("unknown revision")
+	// ErrAmbiguousRevision is returned when an abbreviated object name
+	// matches more than one object
+	ErrAmbiguousRevision = errors.New("ambiguous")
+)
+
+// refRules are the places a short ref name is looked up, in git's order
+var refRules = []string{"%s", "refs/%s", "refs/tags/%s", "refs/heads/%s", "refs/remotes/%s", "refs/remotes/%s/HEAD"}
+
+// ResolveRevision turns a revision expression into an object name. It
+// understands full and abbreviated object names, HEAD, "@", branch, tag and
+// remote-tracking names, the <rev>~<n>, <rev>^<n>, <rev>^{<type>} and
+// <rev>^{} suffixes, <ref>@{<n>} reflog entries, <branch>@{upstream} and
+// <rev>:<path> tree lookups.
+func (r *Repository) ResolveRevision(rev string) (string, error) {
+	if rev == "" {
+		return "", fmt.Errorf("empty revision: %w", ErrUnknownRevision)
+	}
+	if strings.HasPrefix(rev, ":") {
+		return "", fmt.Errorf("%s: index lookups are not supported: %w", rev, ErrUnknownRevision)
+	}
+
+	// The first colon outside braces separates a tree-ish from a path
+	depth := 0
+	for i, c := range rev {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ':':
+			if depth == 0 {
+				return r.resolveTreePath(rev[:i], rev[i+1:])
+			}
+		}
+	}
+	return r.resolveExpression(rev)
+}
+
+// resolveTreePath resolves "<tree-ish>:<path>" to the object at path
+func (r *Repository) resolveTreePath(treeish, path string) (string, error) {
+	hash, err := r.resolveExpression(treeish)
+	if err != nil {
+		return "", err
+	}
+	if hash, err = r.PeelTo(hash, "tree"); err != nil {
+		return "", err
+	}
+
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		entries, err := r.ParseTree(hash)
+		if err != nil {
+			return "", fmt.Errorf("%s:%s: %w", treeish, path, err)
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.Name == name {
+				hash, found = entry.Hash, true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("path '%s' does not exist in '%s': %w", path, treeish, ErrUnknownRevision)
+		}
+	}
+	return hash, nil
+}
+
+// resolveExpression applies the ~, ^ and ^{...} suffixes of rev, innermost
+// first, to the object named by its base
+func (r *Repository) resolveExpression(rev string) (string, error) {
+	// <rev>^{<type>}, <rev>^{}
+	if strings.HasSuffix(rev, "}") {
+		if i := strings.LastIndex(rev, "^{"); i >= 0 {
+			hash, err := r.resolveExpression(rev[:i])
+			if err != nil {
+				return "", err
+			}
+			objType := rev[i+2 : len(rev)-1]
+			switch objType {
+			case "":
+				return r.PeelTag(hash)
+			case "object":
+				if !r.Objects.Has(hash) {
+					return "", fmt.Errorf("%s: %w", rev, ErrUnknownRevision)
+				}
+				return hash, nil
+			case "commit", "tree", "blob", "tag":
+				return r.PeelTo(hash, objType)
+			}
+			return "", fmt.Errorf("%s: unsupported peel type %q: %w", rev, objType, ErrUnknownRevision)
+		}
+	}
+
+	// <rev>~<n>, <rev>^<n>, with n defaulting to 1
+	digits := len(rev)
+	for digits > 0 && rev[digits-1] >= '0' && rev[digits-1] <= '9' {
+		digits--
+	}
+	if digits > 0 && (rev[digits-1] == '~' || rev[digits-1] == '^') {
+		n := 1
+		if digits < len(rev) {
+			var err error
+			if n, err = strconv.Atoi(rev[digits:]); err != nil {
+				return "", fmt.Errorf("%s: %w", rev, ErrUnknownRevision)
+			}
+		}
+		hash, err := r.resolveExpression(rev[:digits-1])
+		if err != nil {
+			return "", err
+		}
+		if hash, err = r.PeelTo(hash, "commit"); err != nil {
+			return "", err
+		}
+		if rev[digits-1] == '^' {
+			if n == 0 {
+				return hash, nil
+			}
+			return r.nthParent(hash, n, rev)
+		}
+		for ; n > 0; n-- {
+			if hash, err = r.nthParent(hash, 1, rev); err != nil {
+				return "", err
+			}
+		}
+		return hash, nil
+	}
+
+	return r.resolveBase(rev)
+}
+
+// resolveBase resolves a revision without suffixes: an object name, a ref
+// name, or a ref with an @{...} selector
+func (r *Repository) resolveBase(rev string) (string, error) {
+	if rev == "@" {
+		rev = "HEAD"
+	}
+	// object names may be spelled in either case
+	if hash := strings.ToLower(rev); len(hash) == 40 && isHex(hash) {
+		return hash, nil
+	}
+
+	if i := strings.Index(rev, "@{"); i >= 0 && strings.HasSuffix(rev, "}") {
+		return r.resolveAtSelector(rev[:i], rev[i+2:len(rev)-1])
+	}
+
+	if name, ok := r.dwimRef(rev); ok {
+		return r.Refs.Resolve(name)
+	}
+
+	if prefix := strings.ToLower(rev); len(prefix) >= minAbbrev && isHex(prefix) {
+		matches, err := r.findPrefix(prefix)
+		if err != nil {
+			return "", err
+		}
+		switch len(matches) {
+		case 0:
+		case 1:
+			return matches[0], nil
+		default:
+			return "", fmt.Errorf("short object ID %s is %w", rev, ErrAmbiguousRevision)
+		}
+	}
+	return "", fmt.Errorf("%s: %w", rev, ErrUnknownRevision)
+}
+
+// resolveAtSelector resolves <ref>@{<n>} and <branch>@{upstream}. An empty
+// ref means the current branch.
+func (r *Repository) resolveAtSelector(base, selector string) (string, error) {
+	switch strings.ToLower(selector) {
+	case "u", "upstream":
+		name, err := r.upstreamOf(base)
+		if err != nil {
+			return "", err
+		}
+		return r.Refs.Resolve(name)
+	}
+
+	n, err := strconv.Atoi(selector)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("%s@{%s}: unsupported reflog selector: %w", base, selector, ErrUnknownRevision)
+	}
+	name, err := r.reflogRef(base)
+	if err != nil {
+		return "", err
+	}
+	entries, err := r.Refs.ReadReflog(name)
+	if err != nil {
+		return "", err
+	}
+	if n >= len(entries) {
+		return "", fmt.Errorf("log for '%s' only has %d entries: %w", base, len(entries), ErrUnknownRevision)
+	}
+	return entries[len(entries)-1-n].New, nil
+}
+
+// reflogRef returns the full name of the ref whose reflog <base>@{n} reads
+func (r *Repository) reflogRef(base string) (string, error) {
+	if base == "" {
+		return r.currentBranch()
+	}
+	if base == "@" {
+		return "HEAD", nil
+	}
+	name, ok := r.dwimRef(base)
+	if !ok {
+		return "", fmt.Errorf("%s: %w", base, ErrUnknownRevision)
+	}
+	return name, nil
+}
+
+// SymbolicFullName returns the full ref name a revision refers to, such as
+// refs/heads/main for "HEAD" or refs/remotes/origin/main for "main@{u}",
+// or "" when the revision is not a ref
+func (r *Repository) SymbolicFullName(rev string) (string, error) {
+	if r.Refs == nil {
+		return "", nil
+	}
+	if rev == "@" {
+		rev = "HEAD"
+	}
+	if i := strings.Index(rev, "@{"); i >= 0 && strings.HasSuffix(rev, "}") {
+		switch strings.ToLower(rev[i+2 : len(rev)-1]) {
+		case "u", "upstream":
+			return r.upstreamOf(rev[:i])
+		}
+		return "", nil
+	}
+	name, ok := r.dwimRef(rev)
+	if !ok {
+		return "", nil
+	}
+	return r.Refs.Follow(name)
+}
+
+// dwimRef expands a short ref name using git's lookup rules, returning the
+// first candidate that resolves to an object
+func (r *Repository) dwimRef(name string) (string, bool) {
+	if r.Refs == nil {
+		return "", false
+	}
+	for _, rule := range refRules {
+		full := fmt.Sprintf(rule, name)
+		if refs.ValidateName(full) != nil {
+			continue
+		}
+		if _, err := r.Refs.Resolve(full); err == nil {
+			return full, true
+		}
+	}
+	return "", false
+}
+
+// currentBranch returns the full name of the branch HEAD points at
+func (r *Repository) currentBranch() (string, error) {
+	if r.Refs == nil {
+		return "", fmt.Errorf("HEAD: %w", ErrUnknownRevision)
+	}
+	name, err := r.Refs.Follow("HEAD")
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(name, "refs/heads/") {
+		return "", fmt.Errorf("HEAD does not point to a branch: %w", ErrUnknownRevision)
+	}
+	return name, nil
+}
+
+// upstreamOf returns the remote-tracking ref configured as the upstream of
+// a branch through branch.<name>.remote and branch.<name>.merge
+func (r *Repository) upstreamOf(branch string) (string, error) {
+	if r.Refs == nil {
+		return "", fmt.Errorf("%s@{upstream}: %w", branch, ErrUnknownRevision)
+	}
+	var full string
+	var err error
+	switch branch {
+	case "", "HEAD", "@":
+		if full, err = r.currentBranch(); err != nil {
+			return "", err
+		}
+	default:
+		full = "refs/heads/" + strings.TrimPrefix(branch, "refs/heads/")
+		if _, err := r.Refs.Resolve(full); err != nil {
+			return "", fmt.Errorf("no such branch: '%s': %w", branch, ErrUnknownRevision)
+		}
+	}
+
+	short := strings.TrimPrefix(full, "refs/heads/")
+	remote, merge, err := r.branchConfig(short)
+	if err != nil {
+		return "", err
+	}
+	if remote == "" || merge == "" {
+		return "", fmt.Errorf("no upstream configured for branch '%s': %w", short, ErrUnknownRevision)
+	}
+	if remote == "." {
+		return merge, nil
+	}
+	return "refs/remotes/" + remote + "/" + strings.TrimPrefix(merge, "refs/heads/"), nil
+}
+
+// branchConfig reads branch.<name>.remote and branch.<name>.merge
+func (r *Repository) branchConfig(branch string) (remote, merge string, err error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return "", "", err
+	}
+	remote, _ = cfg.Get("branch." + branch + ".remote")
+	merge, _ = cfg.Get("branch." + branch + ".merge")
+	return remote, merge, nil
+}
+
+// nthParent returns the n-th (1-based) parent of a commit
+func (r *Repository) nthParent(hash string, n int, rev string) (string, error) {
+	commit, err := r.ReadCommit(hash)
+	if err != nil {
+		return "", err
+	}
+	if n > len(commit.Parents) {
+		return "", fmt.Errorf("%s: commit %s has no parent %d: %w", rev, hash, n, ErrUnknownRevision)
+	}
+	return commit.Parents[n-1], nil
+}
+
+// PeelTo dereferences tags, and commits to their trees, until it reaches an
+// object of type objType
+func (r *Repository) PeelTo(hash, objType string) (string, error) {
+	for depth := 0; depth <= 100; depth++ {
+		obj, err := r.ReadObject(hash)
+		if err != nil {
+			return "", err
+		}
+		if obj.Type == objType {
+			return hash, nil
+		}
+		switch {
+		case obj.Type == "tag":
+			hash, err = r.PeelTag(hash)
+			if err != nil {
+				return "", err
+			}
+		case obj.Type == "commit" && objType == "tree":
+			commit, err := ParseCommit(obj.Content)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", hash, err)
+			}
+			hash = commit.Tree
+		default:
+			return "", fmt.Errorf("object %s is a %s, not a %s", hash, obj.Type, objType)
+		}
+	}
+	return "", fmt.Errorf("object %s: too many levels of indirection", hash)
+}
+
+// findPrefix lists the objects whose names start with prefix
+func (r *Repository) findPrefix(prefix string) ([]string, error) {
+	if pf, ok := r.Objects.(PrefixFinder); ok {
+		return pf.FindPrefix(prefix)
+	}
+	var matches []string
+	err := r.Objects.Iterate(func(hash string) error {
+		if strings.HasPrefix(hash, prefix) {
+			matches = append(matches, hash)
+		}
+		return nil
+	})
+	sort.Strings(matches)
+	return matches, err
+}
+
+// ShortHash returns the shortest unambiguous abbreviation of hash that is
+// at least minLen characters long
+func (r *Repository) ShortHash(hash string, minLen int) (string, error) {
+	if minLen < minAbbrev {
+		minLen = minAbbrev
+	}
+	if minLen >= len(hash) {
+		return hash, nil
+	}
+	matches, err := r.findPrefix(hash[:minLen])
+	if err != nil {
+		return "", err
+	}
+	for n := minLen; n < len(hash); n++ {
+		prefix := hash[:n]
+		unique := true
+		for _, m := range matches {
+			if m != hash && strings.HasPrefix(m, prefix) {
+				unique = false
+				break
+			}
+		}
+		if unique {
+			return prefix, nil
+		}
+	}
+	return hash, nil
+}
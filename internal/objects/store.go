@@ -0,0 +1,407 @@
+package objects
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/master-wayne7/go-git/internal/pack"
+)
+
+// ErrObjectNotFound is returned when an object is not present in a store
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectStore is a content-addressed store of git objects keyed by their
+// 40-char hex SHA
+type ObjectStore interface {
+	// Has reports whether the object exists in the store
+	Has(hash string) bool
+	// Read returns the object's type ("blob", "tree", "commit", "tag") and content
+	Read(hash string) (string, []byte, error)
+	// Write stores an object and returns its hex SHA; writing an existing object is a no-op
+	Write(objType string, content []byte) (string, error)
+	// Iterate calls fn once for every object in the store, stopping at the first error
+	Iterate(fn func(hash string) error) error
+}
+
+// PackWriter is implemented by stores that can keep a received packfile as is
+// instead of storing each of its objects individually
+type PackWriter interface {
+	WritePack(packData []byte) error
+}
+
+// StreamWriter is implemented by stores that can write an object of known
+// size straight from a reader, without holding its content in memory
+type StreamWriter interface {
+	WriteStream(objType string, size int64, r io.Reader) (string, error)
+}
+
+// PrefixFinder is implemented by stores that can list the objects whose
+// names start with a hex prefix without iterating over every object
+type PrefixFinder interface {
+	FindPrefix(prefix string) ([]string, error)
+}
+
+// hashObject computes the git object SHA of "<type> <size>\0<content>"
+func hashObject(objType string, content []byte) (string, [20]byte) {
+	hasher := sha1.New()
+	_, _ = hasher.Write([]byte(objectHeader(objType, int64(len(content)))))
+	_, _ = hasher.Write(content)
+
+	var raw [20]byte
+	copy(raw[:], hasher.Sum(nil))
+	return hex.EncodeToString(raw[:]), raw
+}
+
+// ComputeHash returns the hex SHA git assigns to an object of objType with
+// the given content
+func ComputeHash(objType string, content []byte) string {
+	hexStr, _ := hashObject(objType, content)
+	return hexStr
+}
+
+// objectHeader returns the "<type> <size>\0" prefix of an object
+func objectHeader(objType string, size int64) string {
+	return objType + " " + strconv.FormatInt(size, 10) + "\x00"
+}
+
+// parseObjectHeader parses the "<type> <size>" header of a loose object
+func parseObjectHeader(header []byte) (string, int64, error) {
+	sp := bytes.IndexByte(header, ' ')
+	if sp == -1 {
+		return "", 0, fmt.Errorf("malformed header %q", header)
+	}
+	objType := string(header[:sp])
+	if !isObjectType(objType) {
+		return "", 0, fmt.Errorf("unknown object type %q", objType)
+	}
+	size, err := strconv.ParseInt(string(header[sp+1:]), 10, 64)
+	if err != nil || size < 0 {
+		return "", 0, fmt.Errorf("malformed size in header %q", header)
+	}
+	return objType, size, nil
+}
+
+// isObjectType reports whether objType is one of the four git object types
+func isObjectType(objType string) bool {
+	switch objType {
+	case "blob", "tree", "commit", "tag":
+		return true
+	}
+	return false
+}
+
+// FileStore stores objects on disk under an objects directory, using the
+// loose xx/yyyy layout for writes and reading both loose and packed objects
+type FileStore struct {
+	Dir string
+
+	packsMu sync.Mutex
+	// packs caches opened packfiles by .idx path so every index is loaded once
+	packs map[string]*storePack
+	// packList holds the packs found by the last scan of objects/pack, nil
+	// until the first one; the directory is only read again when an object
+	// is not found in them
+	packList []*storePack
+}
+
+// NewFileStore returns a store for the given objects directory
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{
+		Dir:   dir,
+		packs: make(map[string]*storePack),
+	}
+}
+
+// loosePath returns the path of the loose object file for hash
+func (s *FileStore) loosePath(hash string) string {
+	return filepath.Join(s.Dir, hash[:2], hash[2:])
+}
+
+// Has reports whether the object exists loose or in any pack
+func (s *FileStore) Has(hash string) bool {
+	if len(hash) != 40 || !isHex(hash) {
+		return false
+	}
+	if _, err := os.Stat(s.loosePath(hash)); err == nil {
+		return true
+	}
+	packs, err := s.packsHaving(hash)
+	if err != nil {
+		return false
+	}
+	defer s.releasePacks(packs)
+	for _, p := range packs {
+		if p.Has(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// Read reads a loose object, falling back to the packfiles when no loose
+// object exists
+func (s *FileStore) Read(hash string) (string, []byte, error) {
+	if len(hash) != 40 || !isHex(hash) {
+		return "", nil, fmt.Errorf("invalid object name %q: %w", hash, ErrObjectNotFound)
+	}
+	data, err := os.ReadFile(s.loosePath(hash))
+	if os.IsNotExist(err) {
+		return s.readPackedObject(hash)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s: %w", hash, err)
+	}
+	var out bytes.Buffer
+	_, err = io.Copy(&out, zr)
+	zr.Close()
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s: %w", hash, err)
+	}
+
+	outBytes := out.Bytes()
+	i := bytes.IndexByte(outBytes, '\x00')
+	if i == -1 {
+		return "", nil, fmt.Errorf("object %s: missing header", hash)
+	}
+	objType, size, err := parseObjectHeader(outBytes[:i])
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s: %w", hash, err)
+	}
+	content := outBytes[i+1:]
+	if size != int64(len(content)) {
+		return "", nil, fmt.Errorf("object %s: size mismatch: header says %d, got %d", hash, size, len(content))
+	}
+	return objType, content, nil
+}
+
+// Write compresses the object and writes it as a loose file unless it
+// already exists loose or in a known pack. The pack directory is not
+// rescanned, so a write never costs more than a stat. The write is atomic,
+// see WriteStream.
+func (s *FileStore) Write(objType string, content []byte) (string, error) {
+	hexStr, _ := hashObject(objType, content)
+	if s.hasKnown(hexStr) {
+		return hexStr, nil
+	}
+	if _, err := s.WriteStream(objType, int64(len(content)), bytes.NewReader(content)); err != nil {
+		return "", err
+	}
+	return hexStr, nil
+}
+
+// hasKnown reports whether the object exists loose or in one of the packs
+// already found, without reading objects/pack again
+func (s *FileStore) hasKnown(hash string) bool {
+	if _, err := os.Stat(s.loosePath(hash)); err == nil {
+		return true
+	}
+	packs, err := s.loadPacks()
+	if err != nil {
+		return false
+	}
+	defer s.releasePacks(packs)
+	for _, p := range packs {
+		if p.Has(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteStream compresses size bytes from r into a temporary file while
+// hashing them, then moves the file into place under its hash.
+//
+// The temporary file is fsynced and made read-only before it is renamed, so
+// a crash never leaves a truncated object at its final path. Concurrent
+// writers of the same object each rename an identical, complete file, so
+// readers always see either no object or a whole one.
+func (s *FileStore) WriteStream(objType string, size int64, r io.Reader) (string, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(s.Dir, "tmp_obj_")
+	if err != nil {
+		return "", err
+	}
+	// Removing the temporary file fails harmlessly once it has been renamed
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha1.New()
+	zw := zlib.NewWriter(tmp)
+	w := io.MultiWriter(hasher, zw)
+	if _, err := io.WriteString(w, objectHeader(objType, size)); err != nil {
+		return "", err
+	}
+	if n, err := io.CopyN(w, r, size); err != nil {
+		return "", fmt.Errorf("short read: expected %d bytes, got %d: %w", size, n, err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmp.Chmod(0444); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	hexStr := hex.EncodeToString(hasher.Sum(nil))
+	objectPath := s.loosePath(hexStr)
+	if _, err := os.Stat(objectPath); err == nil {
+		return hexStr, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), objectPath); err != nil {
+		// another writer may have won the race with an identical object
+		if _, statErr := os.Stat(objectPath); statErr == nil {
+			return hexStr, nil
+		}
+		return "", err
+	}
+	syncDir(filepath.Dir(objectPath))
+	return hexStr, nil
+}
+
+// syncDir fsyncs a directory so a rename into it survives a crash. Errors
+// are ignored because not every platform supports syncing directories.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// Iterate visits every loose object and then every packed object that is not
+// also stored loose
+func (s *FileStore) Iterate(fn func(hash string) error) error {
+	seen := make(map[string]bool)
+
+	dirs, err := os.ReadDir(s.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() || len(d.Name()) != 2 || !isHex(d.Name()) {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(s.Dir, d.Name()))
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			hash := d.Name() + f.Name()
+			if len(hash) != 40 || !isHex(hash) {
+				continue
+			}
+			seen[hash] = true
+			if err := fn(hash); err != nil {
+				return err
+			}
+		}
+	}
+
+	packs, err := s.rescanPacks()
+	if err != nil {
+		return err
+	}
+	defer s.releasePacks(packs)
+	for _, p := range packs {
+		for i := 0; i < p.Index.Count(); i++ {
+			hash := p.Index.Hash(i)
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			if err := fn(hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FindPrefix lists the loose and packed objects whose names start with
+// prefix, looking only at the matching loose fan-out directory and using
+// binary search in the pack indexes
+func (s *FileStore) FindPrefix(prefix string) ([]string, error) {
+	if len(prefix) < 2 || !isHex(prefix) {
+		return nil, fmt.Errorf("invalid object name prefix %q", prefix)
+	}
+	seen := make(map[string]bool)
+	var matches []string
+
+	files, err := os.ReadDir(filepath.Join(s.Dir, prefix[:2]))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, f := range files {
+		hash := prefix[:2] + f.Name()
+		if len(hash) == 40 && isHex(hash) && strings.HasPrefix(hash, prefix) && !seen[hash] {
+			seen[hash] = true
+			matches = append(matches, hash)
+		}
+	}
+
+	packs, err := s.rescanPacks()
+	if err != nil {
+		return nil, err
+	}
+	defer s.releasePacks(packs)
+	for _, p := range packs {
+		for _, hash := range p.Index.FindPrefix(prefix) {
+			if !seen[hash] {
+				seen[hash] = true
+				matches = append(matches, hash)
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// WritePack stores a received packfile and its index under objects/pack
+func (s *FileStore) WritePack(packData []byte) error {
+	if _, err := pack.IndexPack(filepath.Join(s.Dir, "pack"), packData); err != nil {
+		return err
+	}
+	packs, err := s.rescanPacks()
+	if err != nil {
+		return err
+	}
+	s.releasePacks(packs)
+	return nil
+}
+
+// isHex reports whether s consists only of lowercase hex digits
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
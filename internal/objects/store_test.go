@@ -0,0 +1,302 @@
+package objects
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// Objects named as git names them
+const (
+	helloBlob  = "ce013625030ba8dba906f756967f9e9ca394464a" // "hello\n"
+	worldBlob  = "cc628ccd10742baea8241c5924df992b5c019f71" // "world\n"
+	emptyTree  = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	missingObj = "0123456789012345678901234567890123456789"
+)
+
+// packFixture is a pack written by git, with the objects it holds
+var packFixture = struct {
+	name   string
+	commit string
+	blob   string
+}{"ofs", "f62acb7f014b8c25ea2f4f148e94eff92bfae8d3", "b5d51034e90c8a4e2c494a8cde2a46ca3d5e7f3e"}
+
+// TestObjectStores runs the same operations against every ObjectStore
+func TestObjectStores(t *testing.T) {
+	stores := []struct {
+		name  string
+		store func(t *testing.T) ObjectStore
+	}{
+		{"memory", func(t *testing.T) ObjectStore { return NewMemoryStore() }},
+		{"file", func(t *testing.T) ObjectStore { return NewFileStore(t.TempDir()) }},
+	}
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			s := st.store(t)
+			writes := []struct {
+				objType, content, want string
+			}{
+				{"blob", "hello\n", helloBlob},
+				{"blob", "world\n", worldBlob},
+				{"tree", "", emptyTree},
+				{"blob", "hello\n", helloBlob},
+			}
+			for _, w := range writes {
+				got, err := s.Write(w.objType, []byte(w.content))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != w.want {
+					t.Errorf("Write(%s, %q) = %s, want %s", w.objType, w.content, got, w.want)
+				}
+				if !s.Has(got) {
+					t.Errorf("Has(%s) = false after writing it", got)
+				}
+				objType, content, err := s.Read(got)
+				if err != nil || objType != w.objType || string(content) != w.content {
+					t.Errorf("Read(%s) = %s, %q, %v", got, objType, content, err)
+				}
+			}
+
+			for _, hash := range []string{missingObj, "not-a-hash"} {
+				if s.Has(hash) {
+					t.Errorf("Has(%q) = true", hash)
+				}
+				if _, _, err := s.Read(hash); !errors.Is(err, ErrObjectNotFound) {
+					t.Errorf("Read(%q): err = %v, want ErrObjectNotFound", hash, err)
+				}
+			}
+
+			var listed []string
+			if err := s.Iterate(func(hash string) error {
+				listed = append(listed, hash)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(listed)
+			want := []string{emptyTree, worldBlob, helloBlob}
+			sort.Strings(want)
+			if len(listed) != len(want) {
+				t.Fatalf("Iterate listed %v, want %v", listed, want)
+			}
+			for i := range want {
+				if listed[i] != want[i] {
+					t.Fatalf("Iterate listed %v, want %v", listed, want)
+				}
+			}
+		})
+	}
+}
+
+// copyPackFixture copies the pack fixture and its index into objects/pack
+// of dir and returns the path of the index
+func copyPackFixture(t *testing.T, dir string) string {
+	t.Helper()
+	packDir := filepath.Join(dir, "pack")
+	if err := os.MkdirAll(packDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range []string{".pack", ".idx"} {
+		data, err := os.ReadFile(filepath.Join("..", "pack", "testdata", packFixture.name+ext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(packDir, "pack-fixture"+ext), data, 0444); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(packDir, "pack-fixture.idx")
+}
+
+// TestFileStorePacks checks the pack fallback, the rescan after a miss and
+// the eviction of packs whose index has gone
+func TestFileStorePacks(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFileStore(dir)
+	defer s.Close()
+
+	// the first lookup scans an empty pack directory
+	if s.Has(packFixture.commit) {
+		t.Fatal("Has found a packed object before the pack existed")
+	}
+	idxPath := copyPackFixture(t, dir)
+	if !s.Has(packFixture.commit) {
+		t.Fatal("Has did not rescan objects/pack after a miss")
+	}
+	objType, content, err := s.Read(packFixture.blob)
+	if err != nil || objType != "blob" || ComputeHash(objType, content) != packFixture.blob {
+		t.Fatalf("Read(%s) = %s, %v", packFixture.blob, objType, err)
+	}
+	if got, err := s.Write(objType, content); err != nil || got != packFixture.blob {
+		t.Fatalf("Write of a packed object = %s, %v", got, err)
+	}
+	if _, err := os.Stat(s.loosePath(packFixture.blob)); !os.IsNotExist(err) {
+		t.Error("Write stored a loose copy of a packed object")
+	}
+
+	matches, err := s.FindPrefix(packFixture.commit[:6])
+	if err != nil || len(matches) != 1 || matches[0] != packFixture.commit {
+		t.Errorf("FindPrefix = %v, %v", matches, err)
+	}
+
+	// a reader holding the pack keeps it open while it is evicted
+	held, err := s.loadPacks()
+	if err != nil || len(held) != 1 {
+		t.Fatalf("loadPacks = %d packs, %v", len(held), err)
+	}
+	if err := os.Remove(idxPath); err != nil {
+		t.Fatal(err)
+	}
+	// only a miss reads objects/pack again
+	if s.Has(missingObj) {
+		t.Fatal("Has found a missing object")
+	}
+	if s.Has(packFixture.commit) {
+		t.Error("Has found an object of a removed pack")
+	}
+	if !held[0].retired {
+		t.Error("the removed pack was not retired")
+	}
+	if _, _, err := held[0].ReadObject(packFixture.blob); err != nil {
+		t.Errorf("reading a retired pack still in use: %v", err)
+	}
+	s.releasePacks(held)
+	if _, _, err := held[0].ReadObject(packFixture.blob); err == nil {
+		t.Error("a retired pack stayed open after its last reader released it")
+	}
+}
+
+func TestFileStoreClose(t *testing.T) {
+	dir := t.TempDir()
+	copyPackFixture(t, dir)
+	s := NewFileStore(dir)
+	if !s.Has(packFixture.commit) {
+		t.Fatal("packed object not found")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.packs) != 0 || s.packList != nil {
+		t.Error("Close kept packs")
+	}
+	// the store reopens its packs when it needs them again
+	if _, _, err := s.Read(packFixture.commit); err != nil {
+		t.Errorf("Read after Close: %v", err)
+	}
+	s.Close()
+}
+
+// TestMemoryRepositoryCommit builds a tree from a directory and commits it
+// in an in-memory repository, and checks the names against git's
+func TestMemoryRepositoryCommit(t *testing.T) {
+	dir := t.TempDir()
+	files := []struct {
+		path, content string
+		perm          os.FileMode
+	}{
+		{"a.txt", "hello\n", 0644},
+		{"sub/b.txt", "world\n", 0644},
+		{"run.sh", "#!/bin/sh\n", 0755},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f.content), f.perm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "A U Thor")
+	t.Setenv("GIT_AUTHOR_EMAIL", "author@example.com")
+	t.Setenv("GIT_AUTHOR_DATE", "1600000000 +0000")
+	t.Setenv("GIT_COMMITTER_NAME", "C O Mitter")
+	t.Setenv("GIT_COMMITTER_EMAIL", "committer@example.com")
+	t.Setenv("GIT_COMMITTER_DATE", "1600000000 +0200")
+
+	repo := NewMemoryRepository(dir)
+	tree, err := repo.WriteTree(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "dac1ebac0ccd4d726123e7976a80d3116d28b2f6"; tree != want {
+		t.Fatalf("WriteTree() = %s, want %s", tree, want)
+	}
+
+	commits := []struct {
+		message string
+		parents []string
+		want    string
+	}{
+		{"initial\n", nil, "5307d21f94ae74a021e0cc653de2448f0ace80e8"},
+		{"second\n", []string{"5307d21f94ae74a021e0cc653de2448f0ace80e8"}, "edb982a3c1823c2f94463581a591bbf2e427ffc2"},
+	}
+	for _, c := range commits {
+		got, err := repo.CommitTree(tree, c.parents, c.message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("CommitTree(%q) = %s, want %s", c.message, got, c.want)
+		}
+		if !repo.Objects.Has(got) {
+			t.Errorf("commit %s was not stored", got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); !os.IsNotExist(err) {
+		t.Error("the in-memory repository wrote a .git directory")
+	}
+}
+
+// TestWriteTreeSubdirectory checks that a directory below the work tree is
+// matched against the work tree's .gitignore by its full path
+func TestWriteTreeSubdirectory(t *testing.T) {
+	writeFiles := func(dir string, files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	workTree := t.TempDir()
+	writeFiles(workTree, map[string]string{
+		".gitignore":        "/sub/skip.txt\n/keep.txt\n",
+		"sub/keep.txt":      "keep\n",
+		"sub/skip.txt":      "skip\n",
+		"sub/.gitignore":    "/deep/skip.txt\n",
+		"sub/deep/a.txt":    "a\n",
+		"sub/deep/skip.txt": "skip\n",
+	})
+	wantDir := t.TempDir()
+	writeFiles(wantDir, map[string]string{
+		"keep.txt":   "keep\n",
+		".gitignore": "/deep/skip.txt\n",
+		"deep/a.txt": "a\n",
+	})
+
+	want, err := NewMemoryRepository(wantDir).WriteTree(wantDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewMemoryRepository(workTree)
+	for _, dir := range []string{filepath.Join(workTree, "sub"), filepath.Join(workTree, "sub", ".")} {
+		got, err := repo.WriteTree(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("WriteTree(%s) = %s, want %s", dir, got, want)
+		}
+	}
+}
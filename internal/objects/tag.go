@@ -0,0 +1,149 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/master-wayne7/go-git/internal/refs"
+)
+
+// Tag is a parsed annotated tag object. Old-style PGP signatures are part of
+// the message, exactly as git stores them.
+type Tag struct {
+	Object string
+	// Type is the type of the tagged object
+	Type string
+	Name string
+	// Tagger is nil for the few historical tags written without one
+	Tagger       *Signature
+	ExtraHeaders []Header
+	Message      string
+}
+
+// ParseTag parses the content of a tag object
+func ParseTag(content []byte) (*Tag, error) {
+	headers, message, err := parseHeaders(content)
+	if err != nil {
+		return nil, fmt.Errorf("tag: %w", err)
+	}
+
+	t := &Tag{Message: message}
+	for _, h := range headers {
+		switch h.Key {
+		case "object":
+			if t.Object != "" || !isObjectName(h.Value) {
+				return nil, fmt.Errorf("tag: invalid object line")
+			}
+			t.Object = h.Value
+		case "type":
+			if !isObjectType(h.Value) {
+				return nil, fmt.Errorf("tag: invalid type %q", h.Value)
+			}
+			t.Type = h.Value
+		case "tag":
+			t.Name = h.Value
+		case "tagger":
+			sig, err := ParseSignature(h.Value)
+			if err != nil {
+				return nil, fmt.Errorf("tag: tagger: %w", err)
+			}
+			t.Tagger = &sig
+		default:
+			t.ExtraHeaders = append(t.ExtraHeaders, h)
+		}
+	}
+	if t.Object == "" || t.Type == "" || t.Name == "" {
+		return nil, fmt.Errorf("tag: missing object, type or tag line")
+	}
+	return t, nil
+}
+
+// Encode serializes the tag into the content of a tag object
+func (t *Tag) Encode() []byte {
+	var buf bytes.Buffer
+	writeHeader(&buf, "object", t.Object)
+	writeHeader(&buf, "type", t.Type)
+	writeHeader(&buf, "tag", t.Name)
+	if t.Tagger != nil {
+		writeHeader(&buf, "tagger", t.Tagger.String())
+	}
+	for _, h := range t.ExtraHeaders {
+		writeHeader(&buf, h.Key, h.Value)
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(t.Message)
+	return buf.Bytes()
+}
+
+// ReadTag reads and parses a tag object
+func (r *Repository) ReadTag(hash string) (*Tag, error) {
+	content, err := r.readTypedObject(hash, "tag")
+	if err != nil {
+		return nil, err
+	}
+	t, err := ParseTag(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", hash, err)
+	}
+	return t, nil
+}
+
+// WriteTag stores a tag object and returns its hash
+func (r *Repository) WriteTag(t *Tag) (string, error) {
+	hash, _, err := r.WriteObject("tag", t.Encode())
+	return hash, err
+}
+
+// CreateTag writes an annotated tag called name for object, tagged by the
+// committer identity, and returns the tag object's hash
+func (r *Repository) CreateTag(name, object, message string) (string, error) {
+	obj, err := r.ReadObject(object)
+	if err != nil {
+		return "", err
+	}
+	tagger, err := r.CommitterIdent()
+	if err != nil {
+		return "", err
+	}
+	return r.WriteTag(&Tag{
+		Object:  object,
+		Type:    obj.Type,
+		Name:    name,
+		Tagger:  &tagger,
+		Message: message,
+	})
+}
+
+// MakeTag checks raw tag object content as strictly as `git mktag` does and
+// writes it: the tagger line is required, no other headers are allowed, the
+// name must be a valid ref name and the tagged object must exist with the
+// stated type
+func (r *Repository) MakeTag(content []byte) (string, error) {
+	if err := ValidateObject("tag", content); err != nil {
+		return "", err
+	}
+	tag, err := ParseTag(content)
+	if err != nil {
+		return "", err
+	}
+	if tag.Tagger == nil {
+		return "", fmt.Errorf("tag: missing tagger line")
+	}
+	if len(tag.ExtraHeaders) > 0 {
+		return "", fmt.Errorf("tag: extra header %q after tagger", tag.ExtraHeaders[0].Key)
+	}
+	if err := refs.CheckRefFormat("refs/tags/"+tag.Name, false); err != nil {
+		return "", fmt.Errorf("tag: invalid tag name %q", tag.Name)
+	}
+
+	obj, err := r.ReadObject(tag.Object)
+	if err != nil {
+		return "", fmt.Errorf("could not read tagged object '%s'", tag.Object)
+	}
+	if obj.Type != tag.Type {
+		return "", fmt.Errorf("object '%s' tagged as '%s', but is a '%s' type", tag.Object, tag.Type, obj.Type)
+	}
+	// the input is stored as is, not re-encoded
+	hash, _, err := r.WriteObject("tag", content)
+	return hash, err
+}
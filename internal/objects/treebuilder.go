@@ -0,0 +1,219 @@
+package objects
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TreeBuilder edits a tree in memory by path and writes the result. Only
+// the directories that were changed are rewritten; untouched subtrees keep
+// their existing hash and are never even read.
+type TreeBuilder struct {
+	repo *Repository
+	root *treeNode
+}
+
+// treeNode is one directory of a TreeBuilder. Its entries are read from the
+// stored tree on first use, and hash is cleared once the directory changes.
+type treeNode struct {
+	hash    string
+	entries map[string]*treeNodeEntry
+}
+
+// treeNodeEntry is a blob, symlink or gitlink (mode and hash) or a
+// directory (dir, whose node holds the hash)
+type treeNodeEntry struct {
+	mode string
+	hash string
+	dir  *treeNode
+}
+
+// NewTreeBuilder starts from the tree base, or from an empty tree when base
+// is ""
+func (r *Repository) NewTreeBuilder(base string) (*TreeBuilder, error) {
+	b := &TreeBuilder{repo: r, root: &treeNode{hash: base}}
+	if base == "" {
+		b.root.entries = make(map[string]*treeNodeEntry)
+	} else if _, err := b.load(b.root); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// load returns the entries of n, reading its tree if needed
+func (b *TreeBuilder) load(n *treeNode) (map[string]*treeNodeEntry, error) {
+	if n.entries != nil {
+		return n.entries, nil
+	}
+	entries, err := b.repo.ParseTree(n.hash)
+	if err != nil {
+		return nil, err
+	}
+	n.entries = make(map[string]*treeNodeEntry, len(entries))
+	for _, e := range entries {
+		entry := &treeNodeEntry{mode: e.Mode, hash: e.Hash}
+		if e.Type == "tree" {
+			entry.mode = "40000"
+			entry.dir = &treeNode{hash: e.Hash}
+		}
+		n.entries[e.Name] = entry
+	}
+	return n.entries, nil
+}
+
+// Insert adds or replaces the entry at path, creating missing parent
+// directories (and replacing files that are in their way). mode is one of
+// 100644, 100755, 120000, 160000 or 40000 (also written 040000).
+func (b *TreeBuilder) Insert(path, mode, hash string) error {
+	names, err := splitTreePath(path)
+	if err != nil {
+		return err
+	}
+	if mode == "040000" {
+		mode = "40000"
+	}
+	switch mode {
+	case "100644", "100755", "120000", "160000", "40000":
+	default:
+		return fmt.Errorf("%s: invalid mode %s", path, mode)
+	}
+	if !isObjectName(hash) {
+		return fmt.Errorf("%s: invalid object name %q", path, hash)
+	}
+
+	node := b.root
+	for _, name := range names[:len(names)-1] {
+		entries, err := b.load(node)
+		if err != nil {
+			return err
+		}
+		node.hash = ""
+		e := entries[name]
+		if e == nil || e.dir == nil {
+			e = &treeNodeEntry{mode: "40000", dir: &treeNode{entries: make(map[string]*treeNodeEntry)}}
+			entries[name] = e
+		}
+		node = e.dir
+	}
+
+	entries, err := b.load(node)
+	if err != nil {
+		return err
+	}
+	node.hash = ""
+	entry := &treeNodeEntry{mode: mode, hash: hash}
+	if mode == "40000" {
+		entry.dir = &treeNode{hash: hash}
+	}
+	entries[names[len(names)-1]] = entry
+	return nil
+}
+
+// Remove deletes the entry at path, along with any directories left empty,
+// and reports whether it existed
+func (b *TreeBuilder) Remove(path string) (bool, error) {
+	names, err := splitTreePath(path)
+	if err != nil {
+		return false, err
+	}
+
+	// parents[i] is the directory holding names[i]
+	parents := []*treeNode{b.root}
+	for _, name := range names[:len(names)-1] {
+		entries, err := b.load(parents[len(parents)-1])
+		if err != nil {
+			return false, err
+		}
+		e := entries[name]
+		if e == nil || e.dir == nil {
+			return false, nil
+		}
+		parents = append(parents, e.dir)
+	}
+	entries, err := b.load(parents[len(parents)-1])
+	if err != nil {
+		return false, err
+	}
+	if entries[names[len(names)-1]] == nil {
+		return false, nil
+	}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		parent := parents[i]
+		parent.hash = ""
+		delete(parent.entries, names[i])
+		if len(parent.entries) > 0 || i == 0 {
+			// every directory above this one changed too
+			for _, p := range parents[:i] {
+				p.hash = ""
+			}
+			break
+		}
+	}
+	return true, nil
+}
+
+// Write stores every changed directory, children first, and returns the
+// hash of the root tree
+func (b *TreeBuilder) Write() (string, error) {
+	return b.write(b.root)
+}
+
+// write stores the tree of n unless it is unchanged
+func (b *TreeBuilder) write(n *treeNode) (string, error) {
+	if n.hash != "" {
+		return n.hash, nil
+	}
+
+	names := make([]string, 0, len(n.entries))
+	for name := range n.entries {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := n.entries[names[i]], n.entries[names[j]]
+		return compareTreeEntries(names[i], a.dir != nil, names[j], b.dir != nil) < 0
+	})
+
+	var payload bytes.Buffer
+	for _, name := range names {
+		e := n.entries[name]
+		hash := e.hash
+		if e.dir != nil {
+			var err error
+			if hash, err = b.write(e.dir); err != nil {
+				return "", err
+			}
+		}
+		raw, err := hex.DecodeString(hash)
+		if err != nil {
+			return "", err
+		}
+		payload.WriteString(e.mode)
+		payload.WriteByte(' ')
+		payload.WriteString(name)
+		payload.WriteByte(0)
+		payload.Write(raw)
+	}
+
+	hash, _, err := b.repo.WriteObject("tree", payload.Bytes())
+	if err != nil {
+		return "", err
+	}
+	n.hash = hash
+	return hash, nil
+}
+
+// splitTreePath splits a slash-separated path into its components,
+// rejecting empty, ".", ".." and ".git" components
+func splitTreePath(path string) ([]string, error) {
+	names := strings.Split(path, "/")
+	for _, name := range names {
+		if name == "" || name == "." || name == ".." || name == ".git" {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,145 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// identRe matches "Name <email> <unix-timestamp> <+hhmm|-hhmm>"
+var identRe = regexp.MustCompile(`^[^<>\n]*<[^<>\n]*> [0-9]+ [+-][0-9]{4}$`)
+
+// ValidateObject checks that content is a well-formed object of objType.
+// Blobs are always valid; trees, commits and tags must follow git's format.
+func ValidateObject(objType string, content []byte) error {
+	switch objType {
+	case "blob":
+		return nil
+	case "tree":
+		return validateTree(content)
+	case "commit":
+		return validateCommit(content)
+	case "tag":
+		return validateTag(content)
+	}
+	return fmt.Errorf("invalid object type %q", objType)
+}
+
+// validateTree checks entry syntax, modes, names and git's entry ordering
+func validateTree(content []byte) error {
+	entries, err := ParseTreePayload(content)
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		switch entry.Mode {
+		case "100644", "100755", "120000", "040000", "160000":
+		default:
+			return fmt.Errorf("tree entry %q has invalid mode %s", entry.Name, entry.Mode)
+		}
+		if entry.Name == "" || entry.Name == "." || entry.Name == ".." || entry.Name == ".git" || strings.Contains(entry.Name, "/") {
+			return fmt.Errorf("tree has invalid entry name %q", entry.Name)
+		}
+		if i > 0 {
+			prev := entries[i-1]
+			if prev.Name == entry.Name {
+				return fmt.Errorf("tree has duplicate entry %q", entry.Name)
+			}
+			if compareTreeEntries(prev.Name, prev.Type == "tree", entry.Name, entry.Type == "tree") > 0 {
+				return fmt.Errorf("tree entries not sorted: %q before %q", prev.Name, entry.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// compareTreeEntries orders tree entries the way git does: names are
+// compared bytewise with directories treated as if they ended in "/"
+func compareTreeEntries(a string, aIsTree bool, b string, bIsTree bool) int {
+	if aIsTree {
+		a += "/"
+	}
+	if bIsTree {
+		b += "/"
+	}
+	return strings.Compare(a, b)
+}
+
+// validateCommit checks the tree, parent, author and committer headers
+func validateCommit(content []byte) error {
+	headers, err := headerLines(content)
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	if i >= len(headers) || !validHashHeader(headers[i], "tree") {
+		return fmt.Errorf("commit: missing or invalid tree line")
+	}
+	i++
+	for i < len(headers) && strings.HasPrefix(headers[i], "parent ") {
+		if !validHashHeader(headers[i], "parent") {
+			return fmt.Errorf("commit: invalid parent line %q", headers[i])
+		}
+		i++
+	}
+	if i >= len(headers) || !validIdentHeader(headers[i], "author") {
+		return fmt.Errorf("commit: missing or invalid author line")
+	}
+	i++
+	if i >= len(headers) || !validIdentHeader(headers[i], "committer") {
+		return fmt.Errorf("commit: missing or invalid committer line")
+	}
+	return nil
+}
+
+// validateTag checks the object, type, tag and optional tagger headers
+func validateTag(content []byte) error {
+	headers, err := headerLines(content)
+	if err != nil {
+		return err
+	}
+
+	if len(headers) < 3 || !validHashHeader(headers[0], "object") {
+		return fmt.Errorf("tag: missing or invalid object line")
+	}
+	objType, ok := strings.CutPrefix(headers[1], "type ")
+	if !ok || !isObjectType(objType) {
+		return fmt.Errorf("tag: missing or invalid type line")
+	}
+	name, ok := strings.CutPrefix(headers[2], "tag ")
+	if !ok || name == "" {
+		return fmt.Errorf("tag: missing or invalid tag line")
+	}
+	if len(headers) > 3 && strings.HasPrefix(headers[3], "tagger ") && !validIdentHeader(headers[3], "tagger") {
+		return fmt.Errorf("tag: invalid tagger line")
+	}
+	return nil
+}
+
+// headerLines returns the header lines of a commit or tag, i.e. everything
+// before the first blank line
+func headerLines(content []byte) ([]string, error) {
+	end := bytes.Index(content, []byte("\n\n"))
+	if end == -1 {
+		if !bytes.HasSuffix(content, []byte("\n")) {
+			return nil, fmt.Errorf("unterminated header")
+		}
+		end = len(content) - 1
+	}
+	return strings.Split(string(content[:end]), "\n"), nil
+}
+
+// validHashHeader checks a "<key> <40-hex>" header line
+func validHashHeader(line, key string) bool {
+	hash, ok := strings.CutPrefix(line, key+" ")
+	return ok && len(hash) == 40 && isHex(hash)
+}
+
+// validIdentHeader checks a "<key> Name <email> <timestamp> <tz>" header line
+func validIdentHeader(line, key string) bool {
+	ident, ok := strings.CutPrefix(line, key+" ")
+	return ok && identRe.MatchString(ident)
+}
@@ -0,0 +1,94 @@
+package pack
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ApplyDelta reconstructs a target object by applying a git delta instruction
+// stream to the base object's content
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	cursor := 0
+
+	// the delta starts with the expected base size and the resulting target size
+	srcSize, n := readDeltaSize(delta[cursor:])
+	if n == 0 {
+		return nil, errors.New("delta: truncated source size")
+	}
+	cursor += n
+	if srcSize != uint64(len(base)) {
+		return nil, fmt.Errorf("delta: base size mismatch: expected %d, got %d", srcSize, len(base))
+	}
+
+	targetSize, n := readDeltaSize(delta[cursor:])
+	if n == 0 {
+		return nil, errors.New("delta: truncated target size")
+	}
+	cursor += n
+
+	target := make([]byte, 0, targetSize)
+	for cursor < len(delta) {
+		cmd := delta[cursor]
+		cursor++
+
+		if cmd&0x80 != 0 {
+			// copy instruction: bits 0-3 select offset bytes, bits 4-6 select size bytes
+			var offset, size uint64
+			for i := uint(0); i < 4; i++ {
+				if cmd&(1<<i) != 0 {
+					if cursor >= len(delta) {
+						return nil, errors.New("delta: truncated copy offset")
+					}
+					offset |= uint64(delta[cursor]) << (8 * i)
+					cursor++
+				}
+			}
+			for i := uint(0); i < 3; i++ {
+				if cmd&(0x10<<i) != 0 {
+					if cursor >= len(delta) {
+						return nil, errors.New("delta: truncated copy size")
+					}
+					size |= uint64(delta[cursor]) << (8 * i)
+					cursor++
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, fmt.Errorf("delta: copy out of range: offset %d, size %d, base %d", offset, size, len(base))
+			}
+			target = append(target, base[offset:offset+size]...)
+		} else if cmd != 0 {
+			// insert instruction: the next cmd bytes are literal data
+			size := int(cmd)
+			if cursor+size > len(delta) {
+				return nil, errors.New("delta: truncated insert data")
+			}
+			target = append(target, delta[cursor:cursor+size]...)
+			cursor += size
+		} else {
+			return nil, errors.New("delta: reserved instruction 0")
+		}
+	}
+
+	if uint64(len(target)) != targetSize {
+		return nil, fmt.Errorf("delta: target size mismatch: expected %d, got %d", targetSize, len(target))
+	}
+	return target, nil
+}
+
+// readDeltaSize reads a little-endian base-128 size from the delta header,
+// returning the value and the number of bytes consumed (0 if truncated)
+func readDeltaSize(data []byte) (uint64, int) {
+	var size uint64
+	var shift uint
+	for i, b := range data {
+		size |= uint64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			return size, i + 1
+		}
+	}
+	return 0, 0
+}
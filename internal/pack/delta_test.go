@@ -0,0 +1,142 @@
+package pack
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// verifyEntry is one line of `git verify-pack -v`
+type verifyEntry struct {
+	hash    string
+	objType string
+	offset  int64
+	base    string
+}
+
+// readFixture loads testdata/<name>.pack and the verify-pack listing that
+// git printed for it
+func readFixture(t *testing.T, name string) ([]byte, []verifyEntry) {
+	t.Helper()
+	packData, err := os.ReadFile(filepath.Join("testdata", name+".pack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listing, err := os.ReadFile(filepath.Join("testdata", name+".verify"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []verifyEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(listing)), "\n") {
+		fields := strings.Fields(line)
+		offset, _ := strconv.ParseInt(fields[4], 10, 64)
+		e := verifyEntry{hash: fields[0], objType: fields[1], offset: offset}
+		if len(fields) == 7 {
+			e.base = fields[6]
+		}
+		entries = append(entries, e)
+	}
+	return packData, entries
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("0123456789abcdef")
+	large := bytes.Repeat([]byte("x"), 0x10000)
+	tests := []struct {
+		name    string
+		base    []byte
+		delta   []byte
+		want    string
+		wantErr string
+	}{
+		{"insert only", nil, []byte{16, 3, 3, 'x', 'y', 'z'}, "xyz", ""},
+		{"copy all", nil, []byte{16, 16, 0x90, 16}, "0123456789abcdef", ""},
+		{"copy with offset", nil, []byte{16, 4, 0x91, 10, 4}, "abcd", ""},
+		{"copy and insert", nil, []byte{16, 6, 0x90, 3, 3, '-', '-', '-'}, "012---", ""},
+		{"base size mismatch", nil, []byte{15, 0}, "", "base size mismatch"},
+		{"copy out of range", nil, []byte{16, 4, 0x91, 14, 4}, "", "copy out of range"},
+		{"truncated insert", nil, []byte{16, 3, 3, 'x'}, "", "truncated insert data"},
+		{"truncated copy offset", nil, []byte{16, 4, 0x81}, "", "truncated copy offset"},
+		{"reserved instruction", nil, []byte{16, 1, 0}, "", "reserved instruction"},
+		{"target size mismatch", nil, []byte{16, 5, 1, 'x'}, "", "target size mismatch"},
+		{"truncated header", nil, []byte{0x80}, "", "truncated source size"},
+		{"copy size 0 means 64 KiB", large, []byte{0x80, 0x80, 4, 0x80, 0x80, 4, 0x80}, string(large), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.base == nil {
+				tt.base = base
+			}
+			got, err := ApplyDelta(tt.base, tt.delta)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestApplyDeltaGit applies every delta of packs written by git to its base
+// and checks that the result hashes to the name git gave it
+func TestApplyDeltaGit(t *testing.T) {
+	for _, name := range []string{"ofs", "ref"} {
+		t.Run(name, func(t *testing.T) {
+			packData, entries := readFixture(t, name)
+			objects, err := ParsePackfile(packData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content := make(map[string][]byte)
+			for _, obj := range objects {
+				content[obj.Hash] = obj.Content
+			}
+
+			deltas := 0
+			for _, e := range entries {
+				if e.base == "" {
+					continue
+				}
+				deltas++
+				raw, err := parsePackObject(bytes.NewReader(packData[e.offset:len(packData)-20]), e.offset)
+				if err != nil {
+					t.Fatalf("%s: %v", e.hash, err)
+				}
+				if !isDelta(raw.Type) {
+					t.Fatalf("%s: type %d is not a delta", e.hash, raw.Type)
+				}
+				target, err := ApplyDelta(content[e.base], raw.Content)
+				if err != nil {
+					t.Fatalf("%s: %v", e.hash, err)
+				}
+				if got := hashObject(typeCode(e.objType), target); got != e.hash {
+					t.Errorf("delta at %d gives %s, want %s", e.offset, got, e.hash)
+				}
+			}
+			if deltas == 0 {
+				t.Fatal("fixture has no deltas")
+			}
+		})
+	}
+}
+
+// typeCode is the pack type of a type name
+func typeCode(name string) int {
+	for code := objCommit; code <= objTag; code++ {
+		if typeName(code) == name {
+			return code
+		}
+	}
+	return 0
+}
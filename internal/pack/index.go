@@ -0,0 +1,221 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// idxMagic is the signature of a version 2 pack index ("\377tOc")
+var idxMagic = []byte{0xff, 't', 'O', 'c'}
+
+// buildIndex generates a version 2 .idx for the given resolved pack objects:
+// a 256-entry fanout table, the sorted object names, their CRC32s, 32-bit
+// offsets (with the MSB pointing into a 64-bit table for large packs), the
+// pack checksum and finally the checksum of the index itself
+func buildIndex(packObjects []*PackObject, packChecksum []byte) ([]byte, error) {
+	type entry struct {
+		sha    []byte
+		crc    uint32
+		offset int64
+	}
+
+	entries := make([]entry, 0, len(packObjects))
+	for _, obj := range packObjects {
+		sha, err := hex.DecodeString(obj.Hash)
+		if err != nil || len(sha) != 20 {
+			return nil, fmt.Errorf("invalid object hash %q at offset %d", obj.Hash, obj.Offset)
+		}
+		entries = append(entries, entry{sha: sha, crc: obj.CRC32, offset: obj.Offset})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].sha, entries[j].sha) < 0
+	})
+	for i := 1; i < len(entries); i++ {
+		if bytes.Equal(entries[i-1].sha, entries[i].sha) {
+			return nil, fmt.Errorf("duplicate object %x in pack", entries[i].sha)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(idxMagic)
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+
+	// fanout[i] is the number of objects whose first byte is <= i
+	var fanout [256]uint32
+	for _, e := range entries {
+		fanout[e.sha[0]]++
+	}
+	for i := 1; i < 256; i++ {
+		fanout[i] += fanout[i-1]
+	}
+	binary.Write(&buf, binary.BigEndian, fanout)
+
+	for _, e := range entries {
+		buf.Write(e.sha)
+	}
+	for _, e := range entries {
+		binary.Write(&buf, binary.BigEndian, e.crc)
+	}
+
+	var largeOffsets []uint64
+	for _, e := range entries {
+		if e.offset < 0x80000000 {
+			binary.Write(&buf, binary.BigEndian, uint32(e.offset))
+			continue
+		}
+		binary.Write(&buf, binary.BigEndian, uint32(0x80000000|len(largeOffsets)))
+		largeOffsets = append(largeOffsets, uint64(e.offset))
+	}
+	for _, off := range largeOffsets {
+		binary.Write(&buf, binary.BigEndian, off)
+	}
+
+	buf.Write(packChecksum)
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+
+	return buf.Bytes(), nil
+}
+
+// Index is a parsed version 2 pack index
+type Index struct {
+	fanout       [256]uint32
+	names        []byte
+	crcs         []byte
+	offsets      []byte
+	largeOffsets []byte
+
+	// PackChecksum is the trailing SHA-1 of the pack this index describes
+	PackChecksum []byte
+}
+
+// ReadIndex loads and validates a version 2 .idx file
+func ReadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIndex(data)
+}
+
+// ParseIndex parses the contents of a version 2 .idx file
+func ParseIndex(data []byte) (*Index, error) {
+	headerLen := 8 + 256*4
+	if len(data) < headerLen+40 {
+		return nil, fmt.Errorf("pack index too short")
+	}
+	if !bytes.Equal(data[:4], idxMagic) {
+		return nil, fmt.Errorf("unsupported pack index: missing v2 signature")
+	}
+	if version := binary.BigEndian.Uint32(data[4:8]); version != 2 {
+		return nil, fmt.Errorf("unsupported pack index version: %d", version)
+	}
+
+	sum := sha1.Sum(data[:len(data)-20])
+	if !bytes.Equal(sum[:], data[len(data)-20:]) {
+		return nil, fmt.Errorf("pack index checksum mismatch")
+	}
+
+	idx := &Index{}
+	for i := range idx.fanout {
+		idx.fanout[i] = binary.BigEndian.Uint32(data[8+i*4:])
+		// each entry counts the names up to its first byte, so the table
+		// never decreases and ends with the object count
+		if i > 0 && idx.fanout[i] < idx.fanout[i-1] {
+			return nil, fmt.Errorf("pack index fanout decreases at %02x", i)
+		}
+	}
+
+	count := int(idx.fanout[255])
+	cursor := headerLen
+	if len(data) < cursor+count*28+40 {
+		return nil, fmt.Errorf("pack index truncated: %d objects", count)
+	}
+	idx.names = data[cursor : cursor+count*20]
+	cursor += count * 20
+	idx.crcs = data[cursor : cursor+count*4]
+	cursor += count * 4
+	idx.offsets = data[cursor : cursor+count*4]
+	cursor += count * 4
+	idx.largeOffsets = data[cursor : len(data)-40]
+	idx.PackChecksum = data[len(data)-40 : len(data)-20]
+
+	return idx, nil
+}
+
+// Count returns the number of objects in the index
+func (idx *Index) Count() int {
+	return int(idx.fanout[255])
+}
+
+// Hash returns the hex SHA of the i-th object in sorted order
+func (idx *Index) Hash(i int) string {
+	return hex.EncodeToString(idx.names[i*20 : i*20+20])
+}
+
+// Offset returns the pack offset of the i-th object in sorted order
+func (idx *Index) Offset(i int) int64 {
+	off := binary.BigEndian.Uint32(idx.offsets[i*4:])
+	if off&0x80000000 == 0 {
+		return int64(off)
+	}
+	large := int(off&0x7fffffff) * 8
+	if large+8 > len(idx.largeOffsets) {
+		return -1
+	}
+	return int64(binary.BigEndian.Uint64(idx.largeOffsets[large:]))
+}
+
+// CRC32 returns the checksum of the i-th object's raw pack entry
+func (idx *Index) CRC32(i int) uint32 {
+	return binary.BigEndian.Uint32(idx.crcs[i*4:])
+}
+
+// Lookup finds the pack offset of an object by its raw SHA, using the fanout
+// table to narrow the binary search to objects sharing the first byte
+func (idx *Index) Lookup(sha []byte) (int64, bool) {
+	if len(sha) != 20 {
+		return 0, false
+	}
+	lo := 0
+	if sha[0] > 0 {
+		lo = int(idx.fanout[sha[0]-1])
+	}
+	hi := int(idx.fanout[sha[0]])
+
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch bytes.Compare(idx.names[mid*20:mid*20+20], sha) {
+		case 0:
+			return idx.Offset(mid), true
+		case -1:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, false
+}
+
+// FindPrefix returns the hex SHAs of all objects whose name starts with the
+// given lowercase hex prefix, in sorted order
+func (idx *Index) FindPrefix(prefix string) []string {
+	count := idx.Count()
+	i := sort.Search(count, func(i int) bool { return idx.Hash(i) >= prefix })
+
+	var matches []string
+	for ; i < count; i++ {
+		hash := idx.Hash(i)
+		if !strings.HasPrefix(hash, prefix) {
+			break
+		}
+		matches = append(matches, hash)
+	}
+	return matches
+}
@@ -0,0 +1,158 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBuildIndexGit checks that the .idx written for a pack is byte for
+// byte the one git index-pack wrote
+func TestBuildIndexGit(t *testing.T) {
+	for _, name := range []string{"ofs", "ref"} {
+		t.Run(name, func(t *testing.T) {
+			packData, _ := readFixture(t, name)
+			want, err := os.ReadFile(filepath.Join("testdata", name+".idx"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			objects, err := ParsePackfile(packData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := buildIndex(objects, packData[len(packData)-20:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("index differs from git's (%d bytes, want %d)", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestParseIndexGit(t *testing.T) {
+	for _, name := range []string{"ofs", "ref"} {
+		t.Run(name, func(t *testing.T) {
+			packData, entries := readFixture(t, name)
+			idx, err := ReadIndex(filepath.Join("testdata", name+".idx"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idx.Count() != len(entries) {
+				t.Fatalf("Count() = %d, want %d", idx.Count(), len(entries))
+			}
+			if !bytes.Equal(idx.PackChecksum, packData[len(packData)-20:]) {
+				t.Errorf("PackChecksum = %x", idx.PackChecksum)
+			}
+			for i := 1; i < idx.Count(); i++ {
+				if idx.Hash(i-1) >= idx.Hash(i) {
+					t.Fatalf("names out of order at %d", i)
+				}
+			}
+			for _, e := range entries {
+				sha, _ := hex.DecodeString(e.hash)
+				offset, ok := idx.Lookup(sha)
+				if !ok || offset != e.offset {
+					t.Errorf("Lookup(%s) = %d, %v, want %d", e.hash, offset, ok, e.offset)
+				}
+				if got := idx.FindPrefix(e.hash[:7]); len(got) != 1 || got[0] != e.hash {
+					t.Errorf("FindPrefix(%s) = %v", e.hash[:7], got)
+				}
+			}
+			missing := make([]byte, 20)
+			if _, ok := idx.Lookup(missing); ok {
+				t.Error("Lookup found the null object")
+			}
+		})
+	}
+}
+
+// TestIndexLargeOffsets round-trips offsets past 2 GiB, which go to the
+// 64-bit offset table
+func TestIndexLargeOffsets(t *testing.T) {
+	tests := []struct {
+		name    string
+		offsets []int64
+	}{
+		{"small", []int64{12, 300, 0x7fffffff}},
+		{"large", []int64{0x80000000, 1 << 40}},
+		{"mixed", []int64{12, 0x80000000, 4096, 5 << 32}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []*PackObject
+			for i, off := range tt.offsets {
+				sum := sha1.Sum([]byte(fmt.Sprint(i)))
+				objects = append(objects, &PackObject{Hash: hex.EncodeToString(sum[:]), Offset: off, CRC32: uint32(i)})
+			}
+			data, err := buildIndex(objects, make([]byte, 20))
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx, err := ParseIndex(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, obj := range objects {
+				sha, _ := hex.DecodeString(obj.Hash)
+				if offset, ok := idx.Lookup(sha); !ok || offset != obj.Offset {
+					t.Errorf("Lookup(%s) = %d, %v, want %d", obj.Hash, offset, ok, obj.Offset)
+				}
+			}
+		})
+	}
+}
+
+func TestParseIndexErrors(t *testing.T) {
+	valid, err := os.ReadFile(filepath.Join("testdata", "ofs.idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := func(fn func([]byte)) []byte {
+		data := bytes.Clone(valid)
+		fn(data)
+		return data
+	}
+	// resigned is modified with the trailing checksum recomputed, so the
+	// damage is only found by reading the tables
+	resigned := func(fn func([]byte)) []byte {
+		return modified(func(d []byte) {
+			fn(d)
+			sum := sha1.Sum(d[:len(d)-20])
+			copy(d[len(d)-20:], sum[:])
+		})
+	}
+	fanout := func(d []byte, i int, n uint32) { binary.BigEndian.PutUint32(d[8+i*4:], n) }
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"too short", valid[:100], "too short"},
+		{"version 1", modified(func(d []byte) { d[0] = 0 }), "missing v2 signature"},
+		{"version 3", modified(func(d []byte) { d[7] = 3 }), "version: 3"},
+		{"corrupt", modified(func(d []byte) { d[len(d)/2] ^= 1 }), "checksum mismatch"},
+		{"fanout decreases", resigned(func(d []byte) { fanout(d, 0x10, 1<<20) }), "fanout decreases at 11"},
+		{"fanout past count", resigned(func(d []byte) { fanout(d, 0xfe, 1<<20) }), "fanout decreases at ff"},
+		{"count past data", resigned(func(d []byte) {
+			for i := 0xf0; i < 256; i++ {
+				fanout(d, i, 1<<20)
+			}
+		}), "truncated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseIndex(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,11 +3,25 @@ package pack
 import (
 	"bytes"
 	"compress/zlib"
+	"crypto/sha1"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
 
-	"github.com/master-wayne7/go-git/internal/objects"
+// Pack object types as encoded in the object header
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
 )
 
 // PackObject represents a parsed pack object
@@ -16,119 +30,171 @@ type PackObject struct {
 	Size    int64
 	Content []byte
 	Hash    string
+
+	// Offset is the position of the object header within the packfile
+	Offset int64
+	// BaseOffset is the absolute pack offset of the base object (OFS_DELTA only)
+	BaseOffset int64
+	// BaseHash is the hex SHA of the base object (REF_DELTA only)
+	BaseHash string
+	// CRC32 is the checksum of the raw, still compressed entry
+	CRC32 uint32
+}
+
+// IndexPack stores the received packfile under packDir as pack-<sha>.pack and
+// writes the matching version 2 .idx, like git index-pack. Delta objects are
+// resolved so every object can be named in the index. It returns the pack's
+// hex checksum.
+func IndexPack(packDir string, packData []byte) (string, error) {
+	packObjects, err := ParsePackfile(packData)
+	if err != nil {
+		return "", err
+	}
+
+	checksum := packData[len(packData)-20:]
+	idxData, err := buildIndex(packObjects, checksum)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(packDir, 0755); err != nil {
+		return "", err
+	}
+
+	// Write the .idx last so readers never see an index without its pack
+	name := "pack-" + hex.EncodeToString(checksum)
+	if err := writeFileAtomic(filepath.Join(packDir, name+".pack"), packData); err != nil {
+		return "", fmt.Errorf("failed to write pack: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(packDir, name+".idx"), idxData); err != nil {
+		return "", fmt.Errorf("failed to write pack index: %w", err)
+	}
+
+	return hex.EncodeToString(checksum), nil
 }
 
-// UnpackPackfile unpacks the received packfile and writes objects to .git/objects
-func UnpackPackfile(packData []byte) error {
-	if len(packData) < 12 {
-		return fmt.Errorf("packfile too short")
+// writeFileAtomic writes a read-only file through a fsynced temporary file
+// and a rename, so a crash never leaves a truncated pack or index behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "tmp_pack_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
 	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0444); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
-	// ### CHANGE THIS ### - Debug: Print packfile info
-	fmt.Printf("Packfile length: %d\n", len(packData))
-	fmt.Printf("First 32 bytes: %q\n", string(packData[:min(32, len(packData))]))
-	fmt.Printf("First 32 bytes (hex): %x\n", packData[:min(32, len(packData))])
+// ParsePackfile parses every object in a packfile, verifies the trailing
+// checksum and resolves delta objects to their full type and content
+func ParsePackfile(packData []byte) ([]*PackObject, error) {
+	if len(packData) < 32 {
+		return nil, fmt.Errorf("packfile too short")
+	}
 
-	reader := bytes.NewReader(packData)
+	reader := bytes.NewReader(packData[:len(packData)-20])
 
 	// Verify pack signature
 	signature := make([]byte, 4)
 	if _, err := reader.Read(signature); err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("Pack signature: %q\n", string(signature))
 	if string(signature) != "PACK" {
-		return fmt.Errorf("invalid pack signature: got %q", string(signature))
+		return nil, fmt.Errorf("invalid pack signature: got %q", string(signature))
 	}
 
 	// Read version
 	var version uint32
 	if err := binary.Read(reader, binary.BigEndian, &version); err != nil {
-		return err
+		return nil, err
 	}
 	if version != 2 {
-		return fmt.Errorf("unsupported pack version: %d", version)
+		return nil, fmt.Errorf("unsupported pack version: %d", version)
 	}
 
 	// Read number of objects
 	var numObjects uint32
 	if err := binary.Read(reader, binary.BigEndian, &numObjects); err != nil {
-		return err
+		return nil, err
 	}
 
-	// Parse objects
-	objects := make([]*PackObject, 0, numObjects)
+	// Parse objects, remembering the raw entry bytes' CRC32 for the index
+	packObjects := make([]*PackObject, 0, numObjects)
 	for i := uint32(0); i < numObjects; i++ {
-		fmt.Printf("Parsing object %d/%d, remaining bytes: %d\n", i+1, numObjects, reader.Len())
-		obj, err := parsePackObject(reader)
+		offset := int64(len(packData) - 20 - reader.Len())
+		obj, err := parsePackObject(reader, offset)
 		if err != nil {
-			return fmt.Errorf("failed to parse object %d: %w", i, err)
+			return nil, fmt.Errorf("failed to parse object %d: %w", i, err)
 		}
-		fmt.Printf("Object %d: type=%d, size=%d\n", i+1, obj.Type, obj.Size)
-		objects = append(objects, obj)
+		end := int64(len(packData) - 20 - reader.Len())
+		obj.CRC32 = crc32.ChecksumIEEE(packData[offset:end])
+		packObjects = append(packObjects, obj)
+	}
+
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("packfile has %d trailing bytes after %d objects", reader.Len(), numObjects)
+	}
+
+	sum := sha1.Sum(packData[:len(packData)-20])
+	if !bytes.Equal(sum[:], packData[len(packData)-20:]) {
+		return nil, fmt.Errorf("packfile checksum mismatch")
 	}
 
-	// Resolve delta objects and write all objects
-	return writePackObjects(objects)
+	// Resolve delta objects so every object has a type, content and hash
+	if err := resolveDeltas(packObjects); err != nil {
+		return nil, err
+	}
+	return packObjects, nil
 }
 
-// parsePackObject parses a single object from the packfile
-func parsePackObject(reader *bytes.Reader) (*PackObject, error) {
+// parsePackObject parses a single object from the packfile. offset is the
+// position of the object header, used to locate OFS_DELTA bases.
+func parsePackObject(reader *bytes.Reader, offset int64) (*PackObject, error) {
 	// Read type and size from variable-length encoding
 	objType, size, err := readPackObjectHeader(reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
-	fmt.Printf("  Object header: type=%d, size=%d\n", objType, size)
-
-	// ### CHANGE THIS ### - Handle delta objects differently
-	if objType == 6 || objType == 7 { // OFS_DELTA or REF_DELTA
-		fmt.Printf("  Skipping delta object (type %d) for now\n", objType)
-		// For delta objects, we need to read additional data first
-
-		if objType == 6 { // OFS_DELTA - has offset
-			// Read the offset (variable length)
-			var offset int64
-			for {
-				b, err := reader.ReadByte()
-				if err != nil {
-					return nil, fmt.Errorf("failed to read delta offset: %w", err)
-				}
-				offset = (offset << 7) | int64(b&0x7f)
-				if b&0x80 == 0 {
-					break
-				}
-			}
-			fmt.Printf("  Delta offset: %d\n", offset)
-		} else if objType == 7 { // REF_DELTA - has 20-byte SHA
-			sha := make([]byte, 20)
-			if _, err := reader.Read(sha); err != nil {
-				return nil, fmt.Errorf("failed to read delta sha: %w", err)
-			}
-			fmt.Printf("  Delta base SHA: %x\n", sha)
-		}
+	obj := &PackObject{
+		Type:   objType,
+		Size:   size,
+		Offset: offset,
+	}
 
-		// Now read the compressed delta data
-		zlibReader, err := zlib.NewReader(reader)
+	switch objType {
+	case objOfsDelta:
+		// The base is stored as a negative offset relative to this object
+		relative, err := readOfsDeltaOffset(reader)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create zlib reader for delta: %w", err)
+			return nil, fmt.Errorf("failed to read delta offset: %w", err)
 		}
-
-		// Read and discard the delta data for now
-		if _, err := io.Copy(io.Discard, zlibReader); err != nil {
-			return nil, fmt.Errorf("failed to read delta data: %w", err)
+		if relative <= 0 || relative > offset {
+			return nil, fmt.Errorf("invalid delta base offset %d at %d", relative, offset)
 		}
-		zlibReader.Close()
-
-		return &PackObject{
-			Type:    objType,
-			Size:    size,
-			Content: nil, // Will be resolved later
-		}, nil
+		obj.BaseOffset = offset - relative
+	case objRefDelta:
+		sha := make([]byte, 20)
+		if _, err := io.ReadFull(reader, sha); err != nil {
+			return nil, fmt.Errorf("failed to read delta sha: %w", err)
+		}
+		obj.BaseHash = hex.EncodeToString(sha)
 	}
 
-	// Read compressed data for regular objects
+	// Read compressed data; for delta objects this is the delta instruction stream
 	zlibReader, err := zlib.NewReader(reader)
 	if err != nil {
 		// Print some context for debugging
@@ -146,15 +212,16 @@ func parsePackObject(reader *bytes.Reader) (*PackObject, error) {
 	}
 	zlibReader.Close()
 
-	return &PackObject{
-		Type:    objType,
-		Size:    size,
-		Content: content.Bytes(),
-	}, nil
+	if int64(content.Len()) != size {
+		return nil, fmt.Errorf("size mismatch: header says %d, inflated %d", size, content.Len())
+	}
+	obj.Content = content.Bytes()
+
+	return obj, nil
 }
 
 // readPackObjectHeader reads the variable-length object header
-func readPackObjectHeader(reader *bytes.Reader) (int, int64, error) {
+func readPackObjectHeader(reader io.ByteReader) (int, int64, error) {
 	var b byte
 	var err error
 	var size int64
@@ -182,38 +249,132 @@ func readPackObjectHeader(reader *bytes.Reader) (int, int64, error) {
 	return objType, size, nil
 }
 
-// writePackObjects writes pack objects to .git/objects, resolving deltas if needed
-func writePackObjects(packObjects []*PackObject) error {
-	// For simplicity, we'll assume no delta objects for now
-	// In a full implementation, you'd need to resolve OFS_DELTA and REF_DELTA objects
+// readOfsDeltaOffset reads the big-endian base-128 offset of an OFS_DELTA.
+// Each continuation byte adds one before shifting, so encodings are unique.
+func readOfsDeltaOffset(reader io.ByteReader) (int64, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	offset := int64(b & 0x7f)
+	for b&0x80 != 0 {
+		if b, err = reader.ReadByte(); err != nil {
+			return 0, err
+		}
+		offset = ((offset + 1) << 7) | int64(b&0x7f)
+	}
+	return offset, nil
+}
+
+// resolveDeltas replaces every delta object with its reconstructed content and
+// type. Bases may themselves be deltas, so chains of any depth are followed.
+func resolveDeltas(packObjects []*PackObject) error {
+	byOffset := make(map[int64]*PackObject, len(packObjects))
+	byHash := make(map[string]*PackObject, len(packObjects))
 
+	var pending []*PackObject
 	for _, obj := range packObjects {
-		var objTypeStr string
-		switch obj.Type {
-		case 1: // commit
-			objTypeStr = "commit"
-		case 2: // tree
-			objTypeStr = "tree"
-		case 3: // blob
-			objTypeStr = "blob"
-		case 4: // tag
-			objTypeStr = "tag"
-		default:
-			// Skip delta objects for now
+		byOffset[obj.Offset] = obj
+		if isDelta(obj.Type) {
+			pending = append(pending, obj)
 			continue
 		}
+		obj.Hash = hashObject(obj.Type, obj.Content)
+		byHash[obj.Hash] = obj
+	}
 
-		// Write object to .git/objects
-		hexSha, _, err := objects.WriteObject(objTypeStr, obj.Content)
-		if err != nil {
-			return fmt.Errorf("failed to write %s object: %w", objTypeStr, err)
+	// REF_DELTA bases are only known by hash once they are resolved, so keep
+	// sweeping until every delta is resolved or no further progress is made
+	for len(pending) > 0 {
+		var unresolved []*PackObject
+		for _, obj := range pending {
+			ok, err := resolveDelta(obj, byOffset, byHash)
+			if err != nil {
+				return fmt.Errorf("failed to resolve delta at offset %d: %w", obj.Offset, err)
+			}
+			if !ok {
+				unresolved = append(unresolved, obj)
+			}
+		}
+		if len(unresolved) == len(pending) {
+			return fmt.Errorf("failed to resolve %d delta objects: base %s not found in pack", len(unresolved), unresolved[0].BaseHash)
 		}
-		obj.Hash = hexSha
+		pending = unresolved
 	}
 
 	return nil
 }
 
+// resolveDelta resolves a single delta object, resolving its base chain first.
+// It returns false if a REF_DELTA base in the chain is not available yet.
+func resolveDelta(obj *PackObject, byOffset map[int64]*PackObject, byHash map[string]*PackObject) (bool, error) {
+	if !isDelta(obj.Type) {
+		return true, nil
+	}
+
+	var base *PackObject
+	if obj.Type == objOfsDelta {
+		base = byOffset[obj.BaseOffset]
+		if base == nil {
+			return false, fmt.Errorf("no object at base offset %d", obj.BaseOffset)
+		}
+	} else {
+		base = byHash[obj.BaseHash]
+		if base == nil {
+			return false, nil
+		}
+	}
+
+	if ok, err := resolveDelta(base, byOffset, byHash); !ok || err != nil {
+		return ok, err
+	}
+
+	content, err := ApplyDelta(base.Content, obj.Content)
+	if err != nil {
+		return false, err
+	}
+
+	obj.Type = base.Type
+	obj.Content = content
+	obj.Size = int64(len(content))
+	obj.Hash = hashObject(obj.Type, obj.Content)
+	byHash[obj.Hash] = obj
+	return true, nil
+}
+
+// TypeName returns the git object type name of a resolved object
+func (obj *PackObject) TypeName() string {
+	return typeName(obj.Type)
+}
+
+// isDelta reports whether a pack type is one of the delta encodings
+func isDelta(objType int) bool {
+	return objType == objOfsDelta || objType == objRefDelta
+}
+
+// typeName maps a pack object type to its git object type name
+func typeName(objType int) string {
+	switch objType {
+	case objCommit:
+		return "commit"
+	case objTree:
+		return "tree"
+	case objBlob:
+		return "blob"
+	case objTag:
+		return "tag"
+	}
+	return ""
+}
+
+// hashObject computes the git object SHA for the given type and content
+func hashObject(objType int, content []byte) string {
+	hasher := sha1.New()
+	hasher.Write([]byte(typeName(objType) + " " + strconv.Itoa(len(content)) + "\x00"))
+	hasher.Write(content)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
 // Helper function for min
 func min(a, b int) int {
 	if a < b {
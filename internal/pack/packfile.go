@@ -0,0 +1,198 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrObjectNotFound is returned when an object is not present in a pack
+var ErrObjectNotFound = errors.New("object not found in pack")
+
+// maxDeltaDepth bounds delta chain resolution so a corrupt pack with a
+// cyclic chain fails instead of recursing forever
+const maxDeltaDepth = 10000
+
+// maxCachedBases bounds the number of resolved delta bases kept in memory
+const maxCachedBases = 256
+
+// cachedObject is a resolved object kept to speed up delta chains
+type cachedObject struct {
+	objType int
+	content []byte
+}
+
+// Packfile provides random access to the objects of a .pack through its .idx
+type Packfile struct {
+	Index *Index
+	Path  string
+
+	file *os.File
+	size int64
+
+	mu    sync.Mutex
+	cache map[int64]cachedObject
+}
+
+// OpenPackfile opens the pack described by the .idx file at idxPath
+func OpenPackfile(idxPath string) (*Packfile, error) {
+	idx, err := ReadIndex(idxPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", idxPath, err)
+	}
+
+	packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+	file, err := os.Open(packPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// The pack's trailing checksum must match the one recorded in the index
+	trailer := make([]byte, 20)
+	if _, err := file.ReadAt(trailer, info.Size()-20); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read pack trailer: %w", err)
+	}
+	if !bytes.Equal(trailer, idx.PackChecksum) {
+		file.Close()
+		return nil, fmt.Errorf("pack %s does not match its index", packPath)
+	}
+
+	return &Packfile{
+		Index: idx,
+		Path:  packPath,
+		file:  file,
+		size:  info.Size(),
+		cache: make(map[int64]cachedObject),
+	}, nil
+}
+
+// Close releases the underlying pack file
+func (p *Packfile) Close() error {
+	return p.file.Close()
+}
+
+// Has reports whether the pack contains the object with the given hex SHA
+func (p *Packfile) Has(hash string) bool {
+	sha, err := hex.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+	_, ok := p.Index.Lookup(sha)
+	return ok
+}
+
+// ReadObject returns the type name and fully resolved content of an object
+func (p *Packfile) ReadObject(hash string) (string, []byte, error) {
+	sha, err := hex.DecodeString(hash)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid object name %q", hash)
+	}
+	offset, ok := p.Index.Lookup(sha)
+	if !ok {
+		return "", nil, ErrObjectNotFound
+	}
+
+	objType, content, err := p.readAt(offset, 0)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s from %s: %w", hash, p.Path, err)
+	}
+	return typeName(objType), content, nil
+}
+
+// readAt inflates the entry at offset, following delta chains to its base
+func (p *Packfile) readAt(offset int64, depth int) (int, []byte, error) {
+	if depth > maxDeltaDepth {
+		return 0, nil, fmt.Errorf("delta chain too deep at offset %d", offset)
+	}
+	if offset < 12 || offset >= p.size-20 {
+		return 0, nil, fmt.Errorf("offset %d out of range", offset)
+	}
+
+	p.mu.Lock()
+	cached, ok := p.cache[offset]
+	p.mu.Unlock()
+	if ok {
+		return cached.objType, cached.content, nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(p.file, offset, p.size-20-offset))
+	objType, size, err := readPackObjectHeader(reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	var baseOffset int64
+	switch objType {
+	case objOfsDelta:
+		relative, err := readOfsDeltaOffset(reader)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to read delta offset: %w", err)
+		}
+		if relative <= 0 || relative > offset {
+			return 0, nil, fmt.Errorf("invalid delta base offset %d at %d", relative, offset)
+		}
+		baseOffset = offset - relative
+	case objRefDelta:
+		sha := make([]byte, 20)
+		if _, err := io.ReadFull(reader, sha); err != nil {
+			return 0, nil, fmt.Errorf("failed to read delta sha: %w", err)
+		}
+		var found bool
+		if baseOffset, found = p.Index.Lookup(sha); !found {
+			return 0, nil, fmt.Errorf("delta base %x not found in pack", sha)
+		}
+	case objCommit, objTree, objBlob, objTag:
+	default:
+		return 0, nil, fmt.Errorf("unknown object type %d at offset %d", objType, offset)
+	}
+
+	zlibReader, err := zlib.NewReader(reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create zlib reader: %w", err)
+	}
+	content := bytes.NewBuffer(make([]byte, 0, size))
+	if _, err := io.Copy(content, zlibReader); err != nil {
+		return 0, nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	zlibReader.Close()
+	if int64(content.Len()) != size {
+		return 0, nil, fmt.Errorf("size mismatch at offset %d: header says %d, inflated %d", offset, size, content.Len())
+	}
+
+	data := content.Bytes()
+	if isDelta(objType) {
+		baseType, base, err := p.readAt(baseOffset, depth+1)
+		if err != nil {
+			return 0, nil, err
+		}
+		if data, err = ApplyDelta(base, data); err != nil {
+			return 0, nil, err
+		}
+		objType = baseType
+	}
+
+	// Only objects that serve as delta bases are worth caching
+	if depth > 0 {
+		p.mu.Lock()
+		if len(p.cache) >= maxCachedBases {
+			p.cache = make(map[int64]cachedObject)
+		}
+		p.cache[offset] = cachedObject{objType: objType, content: data}
+		p.mu.Unlock()
+	}
+
+	return objType, data, nil
+}
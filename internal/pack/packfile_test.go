@@ -0,0 +1,65 @@
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openFixture copies testdata/<name>.pack and .idx to a temporary directory,
+// letting edit change the pack first, and opens it
+func openFixture(t *testing.T, name string, edit func([]byte)) *Packfile {
+	t.Helper()
+	dir := t.TempDir()
+	packData, _ := readFixture(t, name)
+	if edit != nil {
+		edit(packData)
+	}
+	idxData, err := os.ReadFile(filepath.Join("testdata", name+".idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".pack"), packData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".idx"), idxData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := OpenPackfile(filepath.Join(dir, name+".idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// TestPackfileReadObject reads every object of packs written by git through
+// their index and checks it against the name git gave it
+func TestPackfileReadObject(t *testing.T) {
+	for _, name := range []string{"ofs", "ref"} {
+		t.Run(name, func(t *testing.T) {
+			_, entries := readFixture(t, name)
+			p := openFixture(t, name, nil)
+			for _, e := range entries {
+				objType, content, err := p.ReadObject(e.hash)
+				if err != nil {
+					t.Fatalf("%s: %v", e.hash, err)
+				}
+				if objType != e.objType || hashObject(typeCode(objType), content) != e.hash {
+					t.Errorf("ReadObject(%s) gives a %s hashing to %s", e.hash, objType, hashObject(typeCode(objType), content))
+				}
+			}
+		})
+	}
+}
+
+func TestPackfileBadDeltaOffset(t *testing.T) {
+	// the blob delta at 1481 names its base 891 bytes back in two bytes;
+	// make them say 16511, before the start of the pack
+	p := openFixture(t, "ofs", func(d []byte) { d[1482], d[1483] = 0xff, 0x7f })
+	_, _, err := p.ReadObject("8b14fe2d271bb39c37d6179103d87af193bc1f9e")
+	if err == nil || !strings.Contains(err.Error(), "invalid delta base offset 16511 at 1481") {
+		t.Fatalf("err = %v, want an invalid delta base offset", err)
+	}
+}
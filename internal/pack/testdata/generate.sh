@@ -0,0 +1,27 @@
+#!/bin/sh
+# Regenerates the pack fixtures with git: ofs.pack holds OFS_DELTA entries,
+# ref.pack the same objects with REF_DELTA entries, and each *.verify is
+# the matching `git verify-pack -v` listing.
+set -e
+out=$(cd "$(dirname "$0")" && pwd)
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+cd "$tmp"
+git init -q
+export GIT_AUTHOR_NAME=A GIT_AUTHOR_EMAIL=a@example.com GIT_AUTHOR_DATE='1600000000 +0000'
+export GIT_COMMITTER_NAME=A GIT_COMMITTER_EMAIL=a@example.com GIT_COMMITTER_DATE='1600000000 +0000'
+seq 1 300 >numbers
+for i in 1 2 3 4; do
+	sed -i "s/^$((i * 50))\$/changed $i/" numbers
+	seq 1 $((i * 40)) | tr 0-9 a-j >letters
+	git add . && git commit -q -m "commit $i"
+done
+git rev-list --objects --all | cut -d' ' -f1 >objects
+for kind in ofs ref; do
+	flag=--delta-base-offset
+	[ $kind = ref ] && flag=
+	name=$(git pack-objects -q --window=10 --depth=10 $flag pack <objects)
+	mv pack-$name.pack "$out/$kind.pack"
+	mv pack-$name.idx "$out/$kind.idx"
+	git verify-pack -v "$out/$kind.idx" | grep -E '^[0-9a-f]{40} ' >"$out/$kind.verify"
+done
@@ -0,0 +1,73 @@
+package refs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked is returned when another process holds the lock for a ref
+var ErrLocked = errors.New("lock file exists")
+
+// lockFile is an exclusively created "<path>.lock" file. Content written to
+// it replaces the target path on commit, or is discarded on rollback.
+type lockFile struct {
+	path string
+	file *os.File
+}
+
+// lock takes the lock for path, failing if another writer holds it
+func lock(path string) (*lockFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+".lock", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return nil, fmt.Errorf("unable to create '%s.lock': %w", path, ErrLocked)
+	} else if err != nil {
+		return nil, err
+	}
+	return &lockFile{path: path, file: f}, nil
+}
+
+// lockWait is lock for short critical sections: while another writer holds
+// the lock it retries until timeout has passed
+func lockWait(path string, timeout time.Duration) (*lockFile, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		lf, err := lock(path)
+		if !errors.Is(err, ErrLocked) || time.Now().After(deadline) {
+			return lf, err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// commit writes data to the lock file and atomically renames it into place
+func (l *lockFile) commit(data []byte) error {
+	if _, err := l.file.Write(data); err != nil {
+		l.rollback()
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		l.rollback()
+		return err
+	}
+	if err := l.file.Close(); err != nil {
+		os.Remove(l.path + ".lock")
+		return err
+	}
+	if err := os.Rename(l.path+".lock", l.path); err != nil {
+		os.Remove(l.path + ".lock")
+		return err
+	}
+	return nil
+}
+
+// rollback releases the lock without touching the target
+func (l *lockFile) rollback() {
+	l.file.Close()
+	os.Remove(l.path + ".lock")
+}
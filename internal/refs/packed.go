@@ -0,0 +1,198 @@
+package refs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// packedRefsHeader starts the line listing the traits of a packed-refs file
+const packedRefsHeader = "# pack-refs with:"
+
+// packedTraits are the promises a packed-refs header makes about peeled
+// lines: with peeled every annotated tag under refs/tags/ has one, with
+// fullyPeeled every annotated tag does. Refs without a promise may still
+// be tags.
+type packedTraits struct {
+	peeled      bool
+	fullyPeeled bool
+}
+
+// readPackedRefs parses packed-refs, attaching "^<hash>" peeled lines to the
+// ref that precedes them
+func (s *Store) readPackedRefs() (map[string]*Ref, error) {
+	refs, _, err := s.readPackedFile()
+	return refs, err
+}
+
+// readPackedFile parses packed-refs like readPackedRefs and also returns the
+// traits its header declares. A missing file has no refs to peel and so
+// keeps every promise.
+func (s *Store) readPackedFile() (map[string]*Ref, packedTraits, error) {
+	refs := make(map[string]*Ref)
+	data, err := os.ReadFile(filepath.Join(s.GitDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return refs, packedTraits{peeled: true, fullyPeeled: true}, nil
+	} else if err != nil {
+		return nil, packedTraits{}, err
+	}
+
+	var traits packedTraits
+	var last *Ref
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case i == 0 && strings.HasPrefix(line, packedRefsHeader):
+			// each trait is followed by a space, the last one too
+			list := strings.TrimPrefix(line, packedRefsHeader) + " "
+			traits.peeled = strings.Contains(list, " peeled ")
+			traits.fullyPeeled = strings.Contains(list, " fully-peeled ")
+		case line == "" || line[0] == '#':
+			continue
+		case line[0] == '^':
+			if last == nil || !isHash(line[1:]) {
+				return nil, packedTraits{}, fmt.Errorf("packed-refs line %d: unexpected peeled line", i+1)
+			}
+			last.Peeled = line[1:]
+		default:
+			hash, name, ok := strings.Cut(line, " ")
+			if !ok || !isHash(hash) || name == "" {
+				return nil, packedTraits{}, fmt.Errorf("packed-refs line %d: malformed entry", i+1)
+			}
+			last = &Ref{Name: name, Hash: hash}
+			refs[name] = last
+		}
+	}
+	return refs, traits, nil
+}
+
+// formatPackedRefs serializes refs in sorted order with their peeled values,
+// under a header declaring traits
+func formatPackedRefs(refs map[string]*Ref, traits packedTraits) []byte {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString(packedRefsHeader)
+	if traits.peeled {
+		buf.WriteString(" peeled")
+	}
+	if traits.fullyPeeled {
+		buf.WriteString(" fully-peeled")
+	}
+	buf.WriteString(" sorted \n")
+	for _, name := range names {
+		ref := refs[name]
+		buf.WriteString(ref.Hash + " " + name + "\n")
+		if ref.Peeled != "" {
+			buf.WriteString("^" + ref.Peeled + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// removePacked drops a ref from packed-refs, rewriting it under its lock
+func (s *Store) removePacked(name string) error {
+	path := filepath.Join(s.GitDir, "packed-refs")
+	lf, err := lock(path)
+	if err != nil {
+		return err
+	}
+
+	// dropping an entry keeps whatever the file promised about the others
+	refs, traits, err := s.readPackedFile()
+	if err != nil {
+		lf.rollback()
+		return err
+	}
+	if _, ok := refs[name]; !ok {
+		lf.rollback()
+		return nil
+	}
+	delete(refs, name)
+	return lf.commit(formatPackedRefs(refs, traits))
+}
+
+// PackRefs moves loose refs into packed-refs and deletes the loose files.
+// Tags are always packed, other refs only with all. peel returns the object
+// an annotated tag ultimately points at (or "" for other objects) and may be
+// nil to skip peeling. With peel every entry ends up peeled, including
+// those already packed without a peeled line; without it the file no
+// longer promises any.
+func (s *Store) PackRefs(all bool, peel func(hash string) (string, error)) error {
+	path := filepath.Join(s.GitDir, "packed-refs")
+	lf, err := lock(path)
+	if err != nil {
+		return err
+	}
+
+	refs, traits, err := s.readPackedFile()
+	if err != nil {
+		lf.rollback()
+		return err
+	}
+	if peel == nil {
+		traits = packedTraits{}
+	} else if !traits.fullyPeeled {
+		for _, ref := range refs {
+			if ref.Peeled != "" {
+				continue
+			}
+			if ref.Peeled, err = peel(ref.Hash); err != nil {
+				lf.rollback()
+				return fmt.Errorf("failed to peel %s: %w", ref.Name, err)
+			}
+		}
+		traits = packedTraits{peeled: true, fullyPeeled: true}
+	}
+	loose, err := s.List("refs/")
+	if err != nil {
+		lf.rollback()
+		return err
+	}
+
+	var packed []*Ref
+	for _, ref := range loose {
+		if ref.IsSymbolic() || (!all && !strings.HasPrefix(ref.Name, "refs/tags/")) {
+			continue
+		}
+		if _, err := os.Stat(s.path(ref.Name)); err != nil {
+			continue // already packed only
+		}
+		entry := &Ref{Name: ref.Name, Hash: ref.Hash}
+		if peel != nil {
+			if entry.Peeled, err = peel(ref.Hash); err != nil {
+				lf.rollback()
+				return fmt.Errorf("failed to peel %s: %w", ref.Name, err)
+			}
+		}
+		refs[ref.Name] = entry
+		packed = append(packed, entry)
+	}
+	if err := lf.commit(formatPackedRefs(refs, traits)); err != nil {
+		return err
+	}
+
+	// Remove the loose copies, leaving any that changed in the meantime
+	for _, ref := range packed {
+		refLock, err := lock(s.path(ref.Name))
+		if err != nil {
+			if errors.Is(err, ErrLocked) {
+				continue
+			}
+			return err
+		}
+		if current, err := s.readLoose(ref.Name); err == nil && current.Hash == ref.Hash {
+			os.Remove(s.path(ref.Name))
+		}
+		refLock.rollback()
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package refs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReflogEntry is one line of a reflog: the ref moved from Old to New at When
+type ReflogEntry struct {
+	Old string
+	New string
+	// Committer is the "Name <email>" identity that made the update
+	Committer string
+	When      time.Time
+	Message   string
+}
+
+// String formats the entry the way git stores it in .git/logs
+func (e *ReflogEntry) String() string {
+	line := fmt.Sprintf("%s %s %s %d %s", e.Old, e.New, e.Committer, e.When.Unix(), e.When.Format("-0700"))
+	if e.Message != "" {
+		line += "\t" + e.Message
+	}
+	return line + "\n"
+}
+
+// logPath returns the reflog file of a ref
+func (s *Store) logPath(name string) string {
+	return filepath.Join(s.GitDir, "logs", filepath.FromSlash(name))
+}
+
+// HasReflog reports whether a reflog exists for the ref
+func (s *Store) HasReflog(name string) bool {
+	info, err := os.Stat(s.logPath(name))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// shouldAutocreateReflog mirrors core.logAllRefUpdates=true: HEAD, branches,
+// remote-tracking branches and notes get a reflog on their first update
+func shouldAutocreateReflog(name string) bool {
+	if name == "HEAD" {
+		return true
+	}
+	for _, prefix := range []string{"refs/heads/", "refs/remotes/", "refs/notes/"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reflogLockTimeout is how long an append waits for a reflog that is being
+// expired
+const reflogLockTimeout = time.Second
+
+// appendReflog records an update of name if the ref keeps a reflog
+func (s *Store) appendReflog(name, oldHash, newHash, msg string) error {
+	if !s.HasReflog(name) && !(s.LogAllRefUpdates && shouldAutocreateReflog(name)) {
+		return nil
+	}
+	if oldHash == "" {
+		oldHash = ZeroHash
+	}
+
+	ident := s.Ident
+	if ident == nil {
+		ident = DefaultIdent
+	}
+	committer, when := ident()
+	entry := &ReflogEntry{
+		Old:       oldHash,
+		New:       newHash,
+		Committer: committer,
+		When:      when,
+		Message:   normalizeReflogMessage(msg),
+	}
+
+	// hold the log's lock so an ExpireReflog rewriting it cannot drop the entry
+	path := s.logPath(name)
+	lf, err := lockWait(path, reflogLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lf.rollback()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(entry.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// normalizeReflogMessage collapses runs of whitespace, including newlines,
+// into single spaces so a message always fits on one reflog line
+func normalizeReflogMessage(msg string) string {
+	return strings.Join(strings.Fields(msg), " ")
+}
+
+// DefaultIdent returns the identity used for reflog entries when the store
+// has no Ident: GIT_COMMITTER_NAME and GIT_COMMITTER_EMAIL if set, otherwise
+// the login name at the host name, stamped with the current time
+func DefaultIdent() (string, time.Time) {
+	name := os.Getenv("GIT_COMMITTER_NAME")
+	email := os.Getenv("GIT_COMMITTER_EMAIL")
+	if name == "" || email == "" {
+		login := "unknown"
+		if u, err := user.Current(); err == nil {
+			login = u.Username
+		}
+		host, err := os.Hostname()
+		if err != nil || host == "" {
+			host = "localhost"
+		}
+		if name == "" {
+			name = login
+		}
+		if email == "" {
+			email = login + "@" + host
+		}
+	}
+	return fmt.Sprintf("%s <%s>", name, email), time.Now()
+}
+
+// ReadReflog returns the reflog entries of a ref, oldest first. A ref
+// without a reflog has no entries.
+func (s *Store) ReadReflog(name string) ([]*ReflogEntry, error) {
+	f, err := os.Open(s.logPath(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*ReflogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		entry, err := parseReflogLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s reflog line %d: %w", name, lineNo, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// parseReflogLine parses "<old> <new> Name <email> <unix> <tz>\t<message>"
+func parseReflogLine(line string) (*ReflogEntry, error) {
+	head, msg, _ := strings.Cut(line, "\t")
+	if len(head) < 82 || head[40] != ' ' || head[81] != ' ' || !isHash(head[:40]) || !isHash(head[41:81]) {
+		return nil, errors.New("malformed object names")
+	}
+
+	ident := head[82:]
+	end := strings.LastIndexByte(ident, '>')
+	if end < 0 || !strings.Contains(ident[:end], "<") {
+		return nil, errors.New("malformed identity")
+	}
+	fields := strings.Fields(ident[end+1:])
+	if len(fields) != 2 {
+		return nil, errors.New("malformed timestamp")
+	}
+	when, err := parseTimestamp(fields[0], fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReflogEntry{
+		Old:       head[:40],
+		New:       head[41:81],
+		Committer: ident[:end+1],
+		When:      when,
+		Message:   msg,
+	}, nil
+}
+
+// parseTimestamp parses a unix timestamp and a "+hhmm" zone offset
+func parseTimestamp(unix, zone string) (time.Time, error) {
+	secs, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed timestamp %q", unix)
+	}
+	if len(zone) != 5 || (zone[0] != '+' && zone[0] != '-') {
+		return time.Time{}, fmt.Errorf("malformed timezone %q", zone)
+	}
+	hours, err1 := strconv.Atoi(zone[1:3])
+	minutes, err2 := strconv.Atoi(zone[3:5])
+	if err1 != nil || err2 != nil {
+		return time.Time{}, fmt.Errorf("malformed timezone %q", zone)
+	}
+	offset := hours*3600 + minutes*60
+	if zone[0] == '-' {
+		offset = -offset
+	}
+	return time.Unix(secs, 0).In(time.FixedZone("", offset)), nil
+}
+
+// ExpireReflog rewrites a ref's reflog under its lock, keeping only the
+// entries for which keep returns true, and returns how many were removed
+func (s *Store) ExpireReflog(name string, keep func(*ReflogEntry) bool) (int, error) {
+	path := s.logPath(name)
+	lf, err := lock(path)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := s.ReadReflog(name)
+	if err != nil {
+		lf.rollback()
+		return 0, err
+	}
+	var kept strings.Builder
+	removed := 0
+	for _, entry := range entries {
+		if keep(entry) {
+			kept.WriteString(entry.String())
+		} else {
+			removed++
+		}
+	}
+	if removed == 0 {
+		lf.rollback()
+		return 0, nil
+	}
+	return removed, lf.commit([]byte(kept.String()))
+}
+
+// deleteReflog removes the reflog of a deleted ref
+func (s *Store) deleteReflog(name string) error {
+	if err := os.Remove(s.logPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Reflogs returns the names of all refs that have a reflog, sorted
+func (s *Store) Reflogs() ([]string, error) {
+	logsDir := filepath.Join(s.GitDir, "logs")
+	var names []string
+	err := filepath.WalkDir(logsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(d.Name(), ".lock") {
+			return err
+		}
+		rel, err := filepath.Rel(logsDir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,81 @@
+package refs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckRefFormat validates a ref name using the rules of git check-ref-format.
+// Unless allowOneLevel is set the name must contain at least one slash.
+func CheckRefFormat(name string, allowOneLevel bool) error {
+	if name == "" {
+		return fmt.Errorf("invalid ref name: empty")
+	}
+	if name == "@" {
+		return fmt.Errorf("invalid ref name %q: cannot be the single character @", name)
+	}
+	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return fmt.Errorf("invalid ref name %q: cannot begin or end with a slash", name)
+	}
+	if strings.HasSuffix(name, ".") {
+		return fmt.Errorf("invalid ref name %q: cannot end with a dot", name)
+	}
+	if strings.Contains(name, "..") {
+		return fmt.Errorf("invalid ref name %q: cannot contain ..", name)
+	}
+	if strings.Contains(name, "@{") {
+		return fmt.Errorf("invalid ref name %q: cannot contain @{", name)
+	}
+
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f {
+			return fmt.Errorf("invalid ref name %q: cannot contain control characters", name)
+		}
+		switch c {
+		case ' ', '~', '^', ':', '?', '*', '[', '\\':
+			return fmt.Errorf("invalid ref name %q: cannot contain %q", name, c)
+		}
+	}
+
+	components := strings.Split(name, "/")
+	if len(components) < 2 && !allowOneLevel {
+		return fmt.Errorf("invalid ref name %q: must contain at least one /", name)
+	}
+	for _, component := range components {
+		if component == "" {
+			return fmt.Errorf("invalid ref name %q: cannot contain //", name)
+		}
+		if strings.HasPrefix(component, ".") {
+			return fmt.Errorf("invalid ref name %q: component cannot begin with a dot", name)
+		}
+		if strings.HasSuffix(component, ".lock") {
+			return fmt.Errorf("invalid ref name %q: component cannot end with .lock", name)
+		}
+	}
+	return nil
+}
+
+// ValidateName checks that name may be stored as a ref: either a well-formed
+// name under refs/ or an all-caps pseudo ref such as HEAD or ORIG_HEAD
+func ValidateName(name string) error {
+	if isPseudoRef(name) {
+		return nil
+	}
+	if !strings.HasPrefix(name, "refs/") {
+		return fmt.Errorf("invalid ref name %q: must be HEAD or start with refs/", name)
+	}
+	return CheckRefFormat(name, false)
+}
+
+// isPseudoRef reports whether name is an all-caps top-level ref like HEAD
+func isPseudoRef(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,339 @@
+package refs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ZeroHash is the all-zero object name; as an expected old value it means
+// "the ref must not exist yet"
+const ZeroHash = "0000000000000000000000000000000000000000"
+
+// maxSymrefDepth bounds symbolic ref chains, like git's SYMREF_MAXDEPTH
+const maxSymrefDepth = 5
+
+var (
+	// ErrNotFound is returned when a ref does not exist
+	ErrNotFound = errors.New("ref not found")
+	// ErrStale is returned when a compare-and-swap update finds an unexpected old value
+	ErrStale = errors.New("ref changed concurrently")
+)
+
+// Ref is a single reference. Direct refs have Hash set, symbolic refs have
+// Target set to the name of the ref they point at.
+type Ref struct {
+	Name   string
+	Hash   string
+	Target string
+	// Peeled is the object an annotated tag ultimately points at, when known
+	Peeled string
+}
+
+// IsSymbolic reports whether the ref points at another ref
+func (r *Ref) IsSymbolic() bool {
+	return r.Target != ""
+}
+
+// Store reads and writes the refs of a git directory: loose files under
+// refs/, top-level refs such as HEAD, and the packed-refs file
+type Store struct {
+	GitDir string
+	// LogAllRefUpdates creates reflogs for HEAD and branches on their first
+	// update; refs that already have a reflog are always logged
+	LogAllRefUpdates bool
+	// Ident returns the "Name <email>" identity and time recorded in reflog
+	// entries; DefaultIdent is used when it is nil
+	Ident func() (string, time.Time)
+}
+
+// NewStore returns the ref store of the given git directory
+func NewStore(gitDir string) *Store {
+	return &Store{GitDir: gitDir}
+}
+
+// path returns the loose file path of a ref
+func (s *Store) path(name string) string {
+	return filepath.Join(s.GitDir, filepath.FromSlash(name))
+}
+
+// Read reads a single ref without following symbolic refs, looking at the
+// loose file first and then at packed-refs
+func (s *Store) Read(name string) (*Ref, error) {
+	ref, err := s.readLoose(name)
+	if !errors.Is(err, ErrNotFound) {
+		return ref, err
+	}
+
+	packed, err := s.readPackedRefs()
+	if err != nil {
+		return nil, err
+	}
+	if ref, ok := packed[name]; ok {
+		return ref, nil
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+}
+
+// readLoose parses the loose file of a ref
+func (s *Store) readLoose(name string) (*Ref, error) {
+	data, err := os.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	} else if err != nil {
+		// a directory at the ref's path means only refs below it exist
+		if info, statErr := os.Stat(s.path(name)); statErr == nil && info.IsDir() {
+			return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+		return nil, err
+	}
+
+	content := strings.TrimRight(string(data), "\r\n")
+	if target, ok := strings.CutPrefix(content, "ref: "); ok {
+		return &Ref{Name: name, Target: strings.TrimSpace(target)}, nil
+	}
+	if !isHash(content) {
+		return nil, fmt.Errorf("%s: invalid ref content %q", name, content)
+	}
+	return &Ref{Name: name, Hash: content}, nil
+}
+
+// Follow resolves symbolic refs starting at name and returns the name of the
+// direct ref at the end of the chain, whether or not that ref exists yet
+// (e.g. refs/heads/main for HEAD on an unborn branch)
+func (s *Store) Follow(name string) (string, error) {
+	for depth := 0; depth <= maxSymrefDepth; depth++ {
+		ref, err := s.Read(name)
+		if errors.Is(err, ErrNotFound) {
+			return name, nil
+		} else if err != nil {
+			return "", err
+		}
+		if !ref.IsSymbolic() {
+			return name, nil
+		}
+		name = ref.Target
+	}
+	return "", fmt.Errorf("%s: symbolic ref nesting too deep", name)
+}
+
+// Resolve follows symbolic refs recursively and returns the object name the
+// ref ultimately points at
+func (s *Store) Resolve(name string) (string, error) {
+	final, err := s.Follow(name)
+	if err != nil {
+		return "", err
+	}
+	ref, err := s.Read(final)
+	if err != nil {
+		return "", err
+	}
+	return ref.Hash, nil
+}
+
+// List returns all refs whose names start with prefix, sorted by name.
+// Loose refs take precedence over packed-refs entries of the same name.
+func (s *Store) List(prefix string) ([]*Ref, error) {
+	packed, err := s.readPackedRefs()
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]*Ref, len(packed))
+	for name, ref := range packed {
+		all[name] = ref
+	}
+
+	refsDir := filepath.Join(s.GitDir, "refs")
+	err = filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(d.Name(), ".lock") {
+			return err
+		}
+		rel, err := filepath.Rel(s.GitDir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		ref, err := s.readLoose(name)
+		if err != nil {
+			return err
+		}
+		if p, ok := packed[name]; ok && p.Hash == ref.Hash {
+			ref.Peeled = p.Peeled
+		}
+		all[name] = ref
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var result []*Ref
+	for name, ref := range all {
+		if strings.HasPrefix(name, prefix) {
+			result = append(result, ref)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// currentHash returns the value of a direct ref, or "" if it does not exist
+func (s *Store) currentHash(name string) (string, error) {
+	ref, err := s.Read(name)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if ref.IsSymbolic() {
+		return "", fmt.Errorf("%s is a symbolic ref", name)
+	}
+	return ref.Hash, nil
+}
+
+// checkOld implements compare-and-swap: oldHash "" skips the check and
+// ZeroHash requires the ref not to exist
+func checkOld(name, current, oldHash string) error {
+	switch {
+	case oldHash == "":
+		return nil
+	case oldHash == ZeroHash && current != "":
+		return fmt.Errorf("cannot lock ref '%s': reference already exists: %w", name, ErrStale)
+	case oldHash != ZeroHash && current != oldHash:
+		if current == "" {
+			return fmt.Errorf("cannot lock ref '%s': unable to resolve reference: %w", name, ErrStale)
+		}
+		return fmt.Errorf("cannot lock ref '%s': is at %s but expected %s: %w", name, current, oldHash, ErrStale)
+	}
+	return nil
+}
+
+// Update points a ref at newHash, following symbolic refs so that updating
+// HEAD moves the branch it points to. The write happens under a .lock file;
+// if oldHash is not empty the update only succeeds when the ref currently
+// has that value (ZeroHash: the ref must not exist). The move is recorded
+// with msg in the reflogs of the ref and of HEAD when HEAD points at it.
+func (s *Store) Update(name, newHash, oldHash, msg string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if !isHash(newHash) {
+		return fmt.Errorf("%s: invalid object name %q", name, newHash)
+	}
+	final, err := s.Follow(name)
+	if err != nil {
+		return err
+	}
+	if err := ValidateName(final); err != nil {
+		return err
+	}
+
+	lf, err := lock(s.path(final))
+	if err != nil {
+		return err
+	}
+	current, err := s.currentHash(final)
+	if err != nil {
+		lf.rollback()
+		return err
+	}
+	if err := checkOld(final, current, oldHash); err != nil {
+		lf.rollback()
+		return err
+	}
+	if err := lf.commit([]byte(newHash + "\n")); err != nil {
+		return err
+	}
+
+	if err := s.appendReflog(final, current, newHash, msg); err != nil {
+		return err
+	}
+	if name != final {
+		return s.appendReflog(name, current, newHash, msg)
+	}
+	if head, err := s.Follow("HEAD"); err == nil && head == final && final != "HEAD" {
+		return s.appendReflog("HEAD", current, newHash, msg)
+	}
+	return nil
+}
+
+// SetSymbolic makes name a symbolic ref pointing at target. When msg is not
+// empty and the object name resolves differently afterwards, the change is
+// recorded in name's reflog.
+func (s *Store) SetSymbolic(name, target, msg string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if err := ValidateName(target); err != nil {
+		return err
+	}
+
+	lf, err := lock(s.path(name))
+	if err != nil {
+		return err
+	}
+	oldHash, _ := s.Resolve(name)
+	if err := lf.commit([]byte("ref: " + target + "\n")); err != nil {
+		return err
+	}
+
+	if msg == "" {
+		return nil
+	}
+	newHash, err := s.Resolve(target)
+	if err != nil || newHash == oldHash {
+		return nil
+	}
+	return s.appendReflog(name, oldHash, newHash, msg)
+}
+
+// Delete removes a ref, both its loose file and any packed-refs entry. The
+// ref itself is deleted even if it is symbolic, and its reflog goes with it.
+// oldHash works as in Update.
+func (s *Store) Delete(name, oldHash string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	lf, err := lock(s.path(name))
+	if err != nil {
+		return err
+	}
+	defer lf.rollback()
+
+	ref, err := s.Read(name)
+	if err != nil {
+		return err
+	}
+	if !ref.IsSymbolic() {
+		if err := checkOld(name, ref.Hash, oldHash); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(s.path(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := s.removePacked(name); err != nil {
+		return err
+	}
+	return s.deleteReflog(name)
+}
+
+// isHash reports whether s is a 40-char lowercase hex object name
+func isHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,228 @@
+// Package wildmatch implements git's wildcard matching, used for ref
+// patterns such as `tag -l 'v1.*'` and for ignore rules.
+package wildmatch
+
+import "unicode"
+
+// Flags change how a pattern is matched
+type Flags int
+
+const (
+	// Pathname makes "*", "?" and "[...]" stop at slashes, while "**"
+	// between slashes matches any number of directories
+	Pathname Flags = 1 << iota
+	// CaseFold matches ASCII letters case-insensitively
+	CaseFold
+)
+
+// Match reports whether text matches the wildcard pattern. "*" matches any
+// run of characters, "?" a single character, "[...]" a character class
+// (with "!" or "^" negation, ranges and [:class:] names) and "\" escapes
+// the next character.
+func Match(pattern, text string, flags Flags) bool {
+	return match(pattern, text, flags, true)
+}
+
+// match matches pattern against text; atStart reports whether pattern
+// begins right after a slash (or at the start of the whole pattern)
+func match(p, t string, flags Flags, atStart bool) bool {
+	pathname := flags&Pathname != 0
+	for len(p) > 0 {
+		c := p[0]
+		switch c {
+		case '\\':
+			if len(p) < 2 || len(t) == 0 || !sameByte(p[1], t[0], flags) {
+				return false
+			}
+			p, t = p[2:], t[1:]
+
+		case '?':
+			if len(t) == 0 || (pathname && t[0] == '/') {
+				return false
+			}
+			p, t = p[1:], t[1:]
+
+		case '*':
+			stars := 1
+			for stars < len(p) && p[stars] == '*' {
+				stars++
+			}
+			rest := p[stars:]
+			if pathname && stars >= 2 && atStart && (rest == "" || rest[0] == '/') {
+				// "**" as a whole path component
+				if rest == "" {
+					return true
+				}
+				// "**/" also matches zero directories
+				if match(rest[1:], t, flags, true) {
+					return true
+				}
+				for i := 0; i < len(t); i++ {
+					if t[i] == '/' && match(rest[1:], t[i+1:], flags, true) {
+						return true
+					}
+				}
+				return false
+			}
+			if rest == "" {
+				if pathname {
+					for i := 0; i < len(t); i++ {
+						if t[i] == '/' {
+							return false
+						}
+					}
+				}
+				return true
+			}
+			for i := 0; i <= len(t); i++ {
+				if match(rest, t[i:], flags, false) {
+					return true
+				}
+				if i < len(t) && pathname && t[i] == '/' {
+					return false
+				}
+			}
+			return false
+
+		case '[':
+			if len(t) == 0 || (pathname && t[0] == '/') {
+				return false
+			}
+			n, ok := matchClass(p, t[0], flags)
+			if n == 0 {
+				// an unterminated class is an error in git: nothing matches
+				return false
+			}
+			if !ok {
+				return false
+			}
+			p, t = p[n:], t[1:]
+
+		default:
+			if len(t) == 0 || !sameByte(c, t[0], flags) {
+				return false
+			}
+			p, t = p[1:], t[1:]
+		}
+		atStart = c == '/'
+	}
+	return len(t) == 0
+}
+
+// matchClass matches the bracket expression at the start of p against c. It
+// returns the length of the expression (0 when it is not terminated) and
+// whether c is in the class.
+func matchClass(p string, c byte, flags Flags) (int, bool) {
+	i := 1
+	negate := false
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		negate = true
+		i++
+	}
+
+	matched := false
+	first := true
+	for ; i < len(p); i++ {
+		if p[i] == ']' && !first {
+			return i + 1, matched != negate
+		}
+		first = false
+
+		lo := p[i]
+		switch {
+		case lo == '\\' && i+1 < len(p):
+			i++
+			lo = p[i]
+		case lo == '[' && i+1 < len(p) && p[i+1] == ':':
+			end := indexFrom(p, ":]", i+2)
+			if end < 0 {
+				break
+			}
+			if classContains(p[i+2:end], c, flags) {
+				matched = true
+			}
+			i = end + 1
+			continue
+		}
+
+		if i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']' {
+			hi := p[i+2]
+			i += 2
+			if hi == '\\' && i+1 < len(p) {
+				i++
+				hi = p[i]
+			}
+			if lo <= c && c <= hi {
+				matched = true
+			} else if flags&CaseFold != 0 {
+				folded := foldCase(c)
+				if lo <= folded && folded <= hi {
+					matched = true
+				}
+			}
+			continue
+		}
+		if sameByte(lo, c, flags) {
+			matched = true
+		}
+	}
+	return 0, false
+}
+
+// classContains reports whether c is in the named POSIX character class
+func classContains(name string, c byte, flags Flags) bool {
+	r := rune(c)
+	switch name {
+	case "alnum":
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	case "alpha":
+		return unicode.IsLetter(r)
+	case "blank":
+		return c == ' ' || c == '\t'
+	case "cntrl":
+		return unicode.IsControl(r)
+	case "digit":
+		return unicode.IsDigit(r)
+	case "graph":
+		return c > ' ' && c < 0x7f
+	case "lower":
+		return unicode.IsLower(r) || (flags&CaseFold != 0 && unicode.IsUpper(r))
+	case "print":
+		return c >= ' ' && c < 0x7f
+	case "punct":
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	case "space":
+		return unicode.IsSpace(r)
+	case "upper":
+		return unicode.IsUpper(r) || (flags&CaseFold != 0 && unicode.IsLower(r))
+	case "xdigit":
+		return unicode.IsDigit(r) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+	}
+	return false
+}
+
+// sameByte compares two bytes, folding ASCII case when asked to
+func sameByte(a, b byte, flags Flags) bool {
+	if flags&CaseFold != 0 {
+		return foldCase(a) == foldCase(b)
+	}
+	return a == b
+}
+
+// foldCase lowercases an ASCII letter
+func foldCase(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// indexFrom returns the index of sub in s at or after from, or -1
+func indexFrom(s, sub string, from int) int {
+	for i := from; i+len(sub) <= len(s); i++ {
+		if s[i:i+len(sub)] == sub {
+			return i
+		}
+	}
+	return -1
+}